@@ -0,0 +1,219 @@
+package atus
+
+import (
+	"atus/backend/category"
+	"atus/backend/release"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+type FileserverAllocationMethod string
+
+const (
+	FileserverAllocationMethodFill       = "FILL"
+	FileserverAllocationMethodMostFree   = "MOST_FREE"
+	FileserverAllocationMethodRandom     = "RANDOM"
+	FileserverAllocationMethodWeighted   = "WEIGHTED"
+	FileserverAllocationMethodRoundRobin = "ROUND_ROBIN"
+)
+
+// allocationRand is used by the RANDOM and WEIGHTED allocation methods, guarded by ATUS.allocationMutex
+var allocationRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+// fileserverCandidate is a fileserver that is able to receive a release
+type fileserverCandidate struct {
+	*Fileserver
+
+	// free disk space minus the space reserved by releases that are still downloading
+	effectiveFreeSpace int64
+}
+
+// getFileserverReservations returns the disk space that is reserved by releases that are assigned to
+// the fileserver but not yet fully downloaded and the number of releases that are currently downloading.
+// The release with the given uid is ignored.
+func (a *ATUS) getFileserverReservations(fs *Fileserver, ignoreReleaseUID string) (int64, int64) {
+
+	fs.m.RLock()
+	defer fs.m.RUnlock()
+
+	var reservedSpace, activeDownloads int64
+
+	a.pendingReleases.Range(func(_, value interface{}) bool {
+		r := value.(*Release)
+
+		if r.FileserverUID != fs.UID || r.UID == ignoreReleaseUID {
+			return true
+		}
+
+		if r.State != release.StateNew && r.State != release.StateDownloadInit && r.State != release.StateDownloading {
+			return true
+		}
+
+		activeDownloads++
+
+		// the part that is already downloaded is part of the servers statistics
+		reserved := r.Size
+		if lf, ok := fs.listCache[r.Hash]; ok {
+			reserved = int64(float64(r.Size) * (100 - lf.Done) / 100)
+		}
+
+		if reserved > 0 {
+			reservedSpace += reserved
+		}
+
+		return true
+	})
+
+	return reservedSpace, activeDownloads
+
+}
+
+// acceptsCategory returns true if the category is not pinned to other fileservers
+func (f *Fileserver) acceptsCategory(name category.Name) bool {
+	if len(f.Fileserver.Categories) == 0 {
+		return true
+	}
+
+	for _, c := range f.Fileserver.Categories {
+		if c == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getFileserverCandidates returns all fileservers that are able to receive the release
+func (a *ATUS) getFileserverCandidates(r *Release) []*fileserverCandidate {
+
+	var candidates, pinned []*fileserverCandidate
+
+	for _, s := range a.GetAllFileservers() {
+
+		// filter out disabled or unreachable servers
+		if !s.Fileserver.Enabled || s.Fileserver.Statistics == nil {
+			continue
+		}
+
+		// filter out servers that are pinned to other categories
+		if !s.acceptsCategory(category.Name(r.Category)) {
+			continue
+		}
+
+		// filter out servers under high load
+		if s.Fileserver.MaxServerLoad > 0 && len(s.Fileserver.Statistics.ServerLoad) > 0 && s.Fileserver.Statistics.ServerLoad[0] > s.Fileserver.MaxServerLoad {
+			continue
+		}
+
+		reservedSpace, activeDownloads := a.getFileserverReservations(s, r.UID)
+
+		// filter out servers that are already downloading too many releases
+		if s.Fileserver.MaxConcurrentDownloads > 0 && activeDownloads >= s.Fileserver.MaxConcurrentDownloads {
+			continue
+		}
+
+		// filter out full servers or servers with insufficient space
+		effectiveFreeSpace := s.Fileserver.Statistics.DiskFreeSpace - reservedSpace
+		if effectiveFreeSpace < s.Fileserver.MinFreeDiskSpace || effectiveFreeSpace < r.Size {
+			continue
+		}
+
+		c := &fileserverCandidate{
+			Fileserver:         s,
+			effectiveFreeSpace: effectiveFreeSpace,
+		}
+
+		candidates = append(candidates, c)
+
+		if len(s.Fileserver.Categories) > 0 {
+			pinned = append(pinned, c)
+		}
+	}
+
+	// servers the category is pinned to take precedence
+	if len(pinned) > 0 {
+		return pinned
+	}
+
+	return candidates
+
+}
+
+// GetFileserverForRelease selects a fileserver based on the allocation method
+func (a *ATUS) GetFileserverForRelease(method FileserverAllocationMethod, release *Release) *Fileserver {
+
+	candidates := a.getFileserverCandidates(release)
+
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	switch method {
+
+	case FileserverAllocationMethodRandom:
+		a.allocationMutex.Lock()
+		defer a.allocationMutex.Unlock()
+
+		return candidates[allocationRand.Intn(len(candidates))].Fileserver
+
+	case FileserverAllocationMethodWeighted:
+		var totalWeight int64
+		for _, c := range candidates {
+			totalWeight += getFileserverWeight(c.Fileserver)
+		}
+
+		a.allocationMutex.Lock()
+		n := allocationRand.Int63n(totalWeight)
+		a.allocationMutex.Unlock()
+
+		for _, c := range candidates {
+			n -= getFileserverWeight(c.Fileserver)
+			if n < 0 {
+				return c.Fileserver
+			}
+		}
+
+		return candidates[len(candidates)-1].Fileserver
+
+	case FileserverAllocationMethodRoundRobin:
+		sort.Slice(candidates, func(i, j int) bool {
+			return candidates[i].UID < candidates[j].UID
+		})
+
+		a.allocationMutex.Lock()
+		defer a.allocationMutex.Unlock()
+
+		next := candidates[0]
+		for _, c := range candidates {
+			if c.UID > a.lastAllocatedFileserverUID {
+				next = c
+				break
+			}
+		}
+
+		a.lastAllocatedFileserverUID = next.UID
+
+		return next.Fileserver
+
+	}
+
+	// sort by allocation method
+	sort.Slice(candidates, func(i, j int) bool {
+		if method == FileserverAllocationMethodFill {
+			return candidates[i].effectiveFreeSpace < candidates[j].effectiveFreeSpace
+		}
+
+		return candidates[i].effectiveFreeSpace > candidates[j].effectiveFreeSpace
+	})
+
+	return candidates[0].Fileserver
+
+}
+
+func getFileserverWeight(f *Fileserver) int64 {
+	if f.Fileserver.Weight < 1 {
+		return 1
+	}
+	return f.Fileserver.Weight
+}
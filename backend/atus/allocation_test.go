@@ -0,0 +1,246 @@
+package atus
+
+import (
+	"atus/backend/category"
+	"atus/backend/fileserver"
+	"atus/backend/helpers"
+	"atus/backend/release"
+	"testing"
+)
+
+func newTestFileserver(uid string, freeSpace int64, modify func(f *fileserver.Fileserver)) *Fileserver {
+
+	f := &fileserver.Fileserver{
+		Enabled:    true,
+		UID:        uid,
+		Weight:     1,
+		Statistics: &fileserver.Statistics{DiskFreeSpace: freeSpace},
+	}
+
+	if modify != nil {
+		modify(f)
+	}
+
+	return &Fileserver{
+		Fileserver: f,
+		listCache:  make(map[string]*fileserver.ListFile),
+	}
+
+}
+
+func newTestATUS(servers []*Fileserver, releases []*Release) *ATUS {
+
+	a := &ATUS{}
+
+	for _, s := range servers {
+		a.fileservers.Store(s.UID, s)
+	}
+
+	for _, r := range releases {
+		a.pendingReleases.Store(r.Hash, r)
+	}
+
+	return a
+
+}
+
+func TestGetFileserverReservations(t *testing.T) {
+
+	fs := newTestFileserver("fs1", 100*helpers.GiB, nil)
+	fs.listCache["h2"] = &fileserver.ListFile{Hash: "h2", Done: 75}
+
+	releases := []*Release{
+		{UID: "r1", Hash: "h1", FileserverUID: "fs1", State: release.StateNew, Size: 10 * helpers.GiB},
+		{UID: "r2", Hash: "h2", FileserverUID: "fs1", State: release.StateDownloading, Size: 8 * helpers.GiB},
+		{UID: "r3", Hash: "h3", FileserverUID: "fs1", State: release.StateDownloaded, Size: 5 * helpers.GiB},
+		{UID: "r4", Hash: "h4", FileserverUID: "fs2", State: release.StateDownloading, Size: 3 * helpers.GiB},
+	}
+
+	a := newTestATUS([]*Fileserver{fs}, releases)
+
+	tests := []struct {
+		name             string
+		ignoreUID        string
+		expectedReserved int64
+		expectedActive   int64
+	}{
+		{"all downloading releases", "", 12 * helpers.GiB, 2},
+		{"ignored release", "r1", 2 * helpers.GiB, 1},
+		{"ignored release of another server", "r4", 12 * helpers.GiB, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reserved, active := a.getFileserverReservations(fs, tt.ignoreUID)
+			if reserved != tt.expectedReserved || active != tt.expectedActive {
+				t.Errorf("expected %d bytes reserved by %d downloads, got %d by %d", tt.expectedReserved, tt.expectedActive, reserved, active)
+			}
+		})
+	}
+
+}
+
+func TestGetFileserverForRelease(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		method   FileserverAllocationMethod
+		servers  []*Fileserver
+		releases []*Release
+		category category.Name
+		expected string // empty if no server is able to receive the release
+	}{
+		{
+			name:   "fill picks the fullest server",
+			method: FileserverAllocationMethodFill,
+			servers: []*Fileserver{
+				newTestFileserver("fs1", 500*helpers.GiB, nil),
+				newTestFileserver("fs2", 100*helpers.GiB, nil),
+			},
+			expected: "fs2",
+		},
+		{
+			name:   "most free picks the emptiest server",
+			method: FileserverAllocationMethodMostFree,
+			servers: []*Fileserver{
+				newTestFileserver("fs1", 500*helpers.GiB, nil),
+				newTestFileserver("fs2", 100*helpers.GiB, nil),
+			},
+			expected: "fs1",
+		},
+		{
+			name:   "reserved space is not free",
+			method: FileserverAllocationMethodMostFree,
+			servers: []*Fileserver{
+				newTestFileserver("fs1", 500*helpers.GiB, nil),
+				newTestFileserver("fs2", 300*helpers.GiB, nil),
+			},
+			releases: []*Release{
+				{UID: "other", Hash: "other", FileserverUID: "fs1", State: release.StateDownloading, Size: 400 * helpers.GiB},
+			},
+			expected: "fs2",
+		},
+		{
+			name:   "disabled, unreachable and busy servers are skipped",
+			method: FileserverAllocationMethodMostFree,
+			servers: []*Fileserver{
+				newTestFileserver("fs1", 900*helpers.GiB, func(f *fileserver.Fileserver) { f.Enabled = false }),
+				newTestFileserver("fs2", 800*helpers.GiB, func(f *fileserver.Fileserver) { f.Statistics = nil }),
+				newTestFileserver("fs3", 700*helpers.GiB, func(f *fileserver.Fileserver) {
+					f.MaxServerLoad = 2
+					f.Statistics.ServerLoad = []float64{4, 3, 2}
+				}),
+				newTestFileserver("fs4", 600*helpers.GiB, func(f *fileserver.Fileserver) { f.MaxConcurrentDownloads = 1 }),
+				newTestFileserver("fs5", 100*helpers.GiB, nil),
+			},
+			releases: []*Release{
+				{UID: "other", Hash: "other", FileserverUID: "fs4", State: release.StateDownloading},
+			},
+			expected: "fs5",
+		},
+		{
+			name:   "min free disk space",
+			method: FileserverAllocationMethodFill,
+			servers: []*Fileserver{
+				newTestFileserver("fs1", 20*helpers.GiB, func(f *fileserver.Fileserver) { f.MinFreeDiskSpace = 25 * helpers.GiB }),
+			},
+			expected: "",
+		},
+		{
+			name:     "pinned servers take precedence",
+			method:   FileserverAllocationMethodMostFree,
+			category: category.TV,
+			servers: []*Fileserver{
+				newTestFileserver("fs1", 900*helpers.GiB, nil),
+				newTestFileserver("fs2", 100*helpers.GiB, func(f *fileserver.Fileserver) { f.Categories = []category.Name{category.TV} }),
+			},
+			expected: "fs2",
+		},
+		{
+			name:     "servers pinned to other categories are skipped",
+			method:   FileserverAllocationMethodFill,
+			category: category.Movie,
+			servers: []*Fileserver{
+				newTestFileserver("fs1", 900*helpers.GiB, nil),
+				newTestFileserver("fs2", 100*helpers.GiB, func(f *fileserver.Fileserver) { f.Categories = []category.Name{category.TV} }),
+			},
+			expected: "fs1",
+		},
+		{
+			name:   "random without candidates",
+			method: FileserverAllocationMethodRandom,
+			servers: []*Fileserver{
+				newTestFileserver("fs1", 900*helpers.GiB, func(f *fileserver.Fileserver) { f.Enabled = false }),
+			},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestATUS(tt.servers, tt.releases)
+
+			r := &Release{UID: "r", Hash: "r", Category: string(tt.category), Size: helpers.GiB}
+
+			var uid string
+			if fs := a.GetFileserverForRelease(tt.method, r); fs != nil {
+				uid = fs.UID
+			}
+
+			if uid != tt.expected {
+				t.Errorf("expected fileserver %q, got %q", tt.expected, uid)
+			}
+		})
+	}
+
+}
+
+func TestGetFileserverForReleaseRoundRobin(t *testing.T) {
+
+	a := newTestATUS([]*Fileserver{
+		newTestFileserver("fs2", 100*helpers.GiB, nil),
+		newTestFileserver("fs3", 100*helpers.GiB, func(f *fileserver.Fileserver) { f.Enabled = false }),
+		newTestFileserver("fs1", 100*helpers.GiB, nil),
+		newTestFileserver("fs4", 100*helpers.GiB, nil),
+	}, nil)
+
+	r := &Release{UID: "r", Hash: "r", Size: helpers.GiB}
+
+	for i, expected := range []string{"fs1", "fs2", "fs4", "fs1", "fs2"} {
+		if fs := a.GetFileserverForRelease(FileserverAllocationMethodRoundRobin, r); fs == nil || fs.UID != expected {
+			t.Fatalf("pick %d: expected %s, got %+v", i, expected, fs)
+		}
+	}
+
+}
+
+func TestGetFileserverForReleaseWeighted(t *testing.T) {
+
+	a := newTestATUS([]*Fileserver{
+		newTestFileserver("fs1", 100*helpers.GiB, func(f *fileserver.Fileserver) { f.Weight = 3 }),
+		newTestFileserver("fs2", 100*helpers.GiB, func(f *fileserver.Fileserver) { f.Weight = 0 }), // treated as 1
+		newTestFileserver("fs3", 100*helpers.GiB, func(f *fileserver.Fileserver) { f.Enabled = false }),
+	}, nil)
+
+	r := &Release{UID: "r", Hash: "r", Size: helpers.GiB}
+
+	const picks = 4000
+	counts := make(map[string]int)
+	for i := 0; i < picks; i++ {
+		fs := a.GetFileserverForRelease(FileserverAllocationMethodWeighted, r)
+		if fs == nil {
+			t.Fatal("expected a fileserver")
+		}
+		counts[fs.UID]++
+	}
+
+	if counts["fs3"] > 0 {
+		t.Errorf("disabled fileserver was picked %d times", counts["fs3"])
+	}
+
+	// fs1 should get 75% of the releases
+	if share := float64(counts["fs1"]) / picks; share < 0.7 || share > 0.8 {
+		t.Errorf("expected fs1 to receive 75%% of the releases, got %.1f%%", share*100)
+	}
+
+}
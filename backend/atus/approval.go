@@ -0,0 +1,147 @@
+package atus
+
+import (
+	"atus/backend/category"
+	"atus/backend/config"
+	"atus/backend/logger"
+	"atus/backend/release"
+	"atus/backend/sqlite"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// isApprovalRequired checks if the release has to be approved before it is uploaded
+// Approval is required globally (UPLOAD__APPROVAL_REQUIRED) or by the category of the release
+func (a *ATUS) isApprovalRequired(r *Release) bool {
+	if config.GetBool("UPLOAD__APPROVAL_REQUIRED") {
+		return true
+	}
+
+	if c := a.GetCategoryByName(category.Name(r.Category)); c != nil {
+		return c.ApprovalRequired
+	}
+
+	return false
+}
+
+// UploadPreview is the payload a destination receives for a release
+type UploadPreview struct {
+	DestinationUID  string `json:"destinationUID"`
+	DestinationName string `json:"destinationName"`
+	DryRun          bool   `json:"dryRun"`
+
+	// empty if the payload was built successfully
+	Error string `json:"error"`
+
+	// infohash, announce url and info.source of the rewritten torrent
+	Hash     string      `json:"hash"`
+	HashV2   string      `json:"hashV2"`
+	Announce string      `json:"announce"`
+	Source   string      `json:"source"`
+	Category string      `json:"category"`
+	Form     *UploadForm `json:"form"`
+}
+
+// GetUploadPreviews builds the payloads all enabled destinations that accept the release would receive
+func (a *ATUS) GetUploadPreviews(ctx context.Context, r *Release) ([]*UploadPreview, error) {
+
+	torrent, nfo, err := r.getUploadFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	if torrent == nil {
+		return nil, fmt.Errorf("release %s has no torrent file", r.Name)
+	}
+
+	previews := []*UploadPreview{}
+	for _, d := range a.GetAllDestinations() {
+		if !d.Enabled {
+			continue
+		}
+
+		preview := &UploadPreview{
+			DestinationUID:  d.UID,
+			DestinationName: d.Name,
+			DryRun:          d.DryRun,
+		}
+
+		if accepted, err := d.Accepts(category.Name(r.Category), r.Name, r.Size, r.GetExternalIDs()); !accepted {
+			preview.Error = "not accepted: " + err.Error()
+			previews = append(previews, preview)
+			continue
+		}
+
+		destTorrent, err := a.getDestinationTorrent(ctx, r, d, torrent)
+		if err != nil {
+			preview.Error = err.Error()
+			previews = append(previews, preview)
+			continue
+		}
+
+		p, form, err := d.PrepareUpload(r, destTorrent, nfo)
+		if err != nil {
+			preview.Error = err.Error()
+		} else {
+			preview.Hash = p.Hash
+			preview.HashV2 = p.HashV2
+			preview.Announce = p.Metainfo.Announce()
+			preview.Source = p.Metainfo.Source()
+			preview.Category = p.Category
+			preview.Form = form
+		}
+
+		previews = append(previews, preview)
+	}
+
+	return previews, nil
+
+}
+
+// ApproveRelease saves the name and category chosen by the operator and uploads the release
+func (a *ATUS) ApproveRelease(ctx context.Context, r *Release, name string, categoryName category.Name) error {
+
+	if r.State != release.StateAwaitingApproval {
+		return errors.New("release is not awaiting approval")
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("name must not be empty")
+	}
+
+	if a.GetCategoryByName(categoryName) == nil {
+		return fmt.Errorf("unknown category %s", categoryName)
+	}
+
+	if name != r.Name || string(categoryName) != r.Category {
+		if _, err := sqlite.Conn.Exec(`UPDATE releases SET name = ?, category = ? WHERE uid = ?`, name, categoryName, r.UID); err != nil {
+			return err
+		}
+
+		logger.Ref(logger.RefRelease, r.UID).Type(logger.TypeUpload).Infof("release changed on approval: %s (%s) -> %s (%s)", r.Name, r.Category, name, categoryName)
+
+		r.Name = name
+		r.Category = string(categoryName)
+	}
+
+	logger.Ref(logger.RefRelease, r.UID).Type(logger.TypeUpload).Infof("release %s approved", r.Name)
+
+	return a.UploadRelease(ctx, r, false)
+
+}
+
+// RejectRelease rejects a release that is awaiting approval. Rejected releases are not uploaded
+func (a *ATUS) RejectRelease(r *Release) error {
+
+	if r.State != release.StateAwaitingApproval {
+		return errors.New("release is not awaiting approval")
+	}
+
+	logger.Ref(logger.RefRelease, r.UID).Type(logger.TypeUpload).Infof("release %s rejected", r.Name)
+
+	return a.updatePendingReleaseState(r, release.StateRejected)
+
+}
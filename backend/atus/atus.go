@@ -10,6 +10,7 @@ import (
 	"atus/backend/scheduler"
 	"atus/backend/source"
 	"atus/backend/video"
+	"context"
 	"fmt"
 	"sync"
 	"time"
@@ -22,21 +23,53 @@ type ATUS struct {
 	sources         sync.Map
 	categories      sync.Map
 	fileservers     sync.Map
+	destinations    sync.Map
 	sampleQueue     chan *Release
 	releaseChan     chan *release.Release
 
+	// uids of the releases enrichReleaseAsync is looking up
+	enrichingReleases sync.Map
+
+	// re-creation of torrents by release uid, see recreatedTorrentsReady
+	recreateJobs sync.Map
+
+	// pending, running and failed sample jobs by release uid
+	sampleJobs      map[string]*SampleJob
+	sampleJobsMutex sync.Mutex
+
+	// converts the samples of a release, onNewSample
+	convertSamples func(ctx context.Context, r *Release, onProgress func(percent float64)) error
+
+	// used by the RANDOM, WEIGHTED and ROUND_ROBIN allocation methods
+	allocationMutex            sync.Mutex
+	lastAllocatedFileserverUID string
+
 	OnReleaseAdded         func(*release.Release)
 	OnReleaseStateUpdated  func(*Release, time.Time)
 	OnMetaFilesUpdated     func(*Release)
 	OnFileserversUpdated   func(*Fileserver)
 	OnDownloadStateChanged func(*fileserver.ListFile)
+	OnSampleJobUpdated     func(*SampleJob)
 }
 
 func New() (*ATUS, error) {
 
-	// -- check dependencies (ffmpeg, ffprobe, mp4dash)
-	if err := video.CheckDependencies(); err != nil {
-		return nil, err
+	// -- check dependencies of the sample packager, samples are not processed if they are missing
+	packager, err := video.ParsePackager(config.GetString("SAMPLES__PACKAGER"))
+	if err == nil {
+		err = video.CheckDependencies(packager)
+	} else {
+		// the settings show the dependency error, an unknown packager would otherwise show as "not checked"
+		video.SetDependencyError(err)
+	}
+
+	if err != nil {
+		logger.Type(logger.TypeSample).Warningf("sample processing is disabled: %s", err)
+	}
+
+	// thumbnails are served as jpeg if ffmpeg can't encode webp
+	if err := video.CheckWebP(); err != nil {
+		logger.Warningf("webp images are disabled: %s", err)
 	}
 
 	// -- init instance and create channels -------
@@ -47,14 +80,18 @@ func New() (*ATUS, error) {
 		// the server clearly can't handle the load if there are that many samples in the queue
 		// ToDo: warn the user if there are more then x pending samples
 		sampleQueue: make(chan *Release, 100),
+		sampleJobs:  make(map[string]*SampleJob),
 
 		OnReleaseAdded:         func(*release.Release) {},
 		OnReleaseStateUpdated:  func(*Release, time.Time) {},
 		OnMetaFilesUpdated:     func(*Release) {},
 		OnFileserversUpdated:   func(*Fileserver) {},
 		OnDownloadStateChanged: func(*fileserver.ListFile) {},
+		OnSampleJobUpdated:     func(*SampleJob) {},
 	}
 
+	a.convertSamples = a.onNewSample
+
 	// -- get categories --------------------------
 	allCategories, err := category.GetAll()
 	if err != nil {
@@ -97,6 +134,11 @@ func New() (*ATUS, error) {
 		}
 	}
 
+	// -- get destinations ------------------------
+	if err := a.loadDestinations(); err != nil {
+		return nil, fmt.Errorf("could not load destinations: %s", err)
+	}
+
 	// -- get sources -----------------------------
 	allSources, err := source.GetAll()
 	if err != nil {
@@ -126,14 +168,15 @@ func New() (*ATUS, error) {
 		}
 	}()
 
-	// sample queue channel
-	go func() {
-		for r := range a.sampleQueue {
-			if err := a.onNewSample(r); err != nil {
-				logger.Ref(logger.RefRelease, r.UID).Type(logger.TypeSample).Errorf("error saving sample meta file: %s", err)
-			}
-		}
-	}()
+	// sample workers, changes to the number of workers require a restart
+	workers := config.GetInt64("SAMPLES__WORKERS")
+	if workers < 1 {
+		workers = 1
+	}
+
+	for i := int64(0); i < workers; i++ {
+		go a.runSampleWorker()
+	}
 
 	// -- start pending release scheduler -----------------------------------------------------------
 	pendingReleaseScheduler := scheduler.New(config.Base.Schedulers.ProcessPendingReleasesInterval, a.processPendingReleasesTask)
@@ -0,0 +1,127 @@
+package atus
+
+import (
+	"atus/backend/bencode"
+	"atus/backend/category"
+	"atus/backend/description"
+	"atus/backend/release"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// getFileURL returns the public url of a meta file
+func (d *Destination) getFileURL(mf *release.MetaFile) string {
+	return d.getReleaseFileURL(mf.ReleaseUID, mf.FileName)
+}
+
+// getReleaseFileURL returns the public url of a file in the data folder of a release
+func (d *Destination) getReleaseFileURL(releaseUID, fileName string) string {
+	baseURL := strings.TrimRight(d.FileBaseURL, "/")
+	if baseURL == "" {
+		baseURL = "/api/data"
+	}
+
+	return baseURL + "/" + url.PathEscape(releaseUID) + "/" + url.PathEscape(fileName)
+}
+
+// getDescriptionData collects the data passed to the destinations description template
+func (d *Destination) getDescriptionData(r *Release, dict *bencode.Dict, nfo []byte) *description.Data {
+
+	data := &description.Data{
+		Name:        r.Name,
+		Pre:         r.Pre,
+		Category:    d.GetCategory(category.Name(r.Category)),
+		Size:        r.Size,
+		Attributes:  release.ParseName(r.Name),
+		ExternalIDs: r.GetExternalIDs(),
+		Metadata:    r.GetMetadata(),
+		Screenshots: []string{},
+		Images:      []string{},
+		Files:       []*description.File{},
+	}
+
+	if len(nfo) > 0 {
+		charset := description.CharsetAuto
+		if m := r.getNFO(); m != nil {
+			charset = getNFOCharset(m)
+		}
+		data.NFO = description.DecodeNFO(nfo, charset)
+	}
+
+	for _, mf := range r.GetMetaFiles() {
+		if mf.State != release.MetafileStateProcessed {
+			continue
+		}
+
+		switch mf.Type {
+		case release.MetafileTypeScreenImage, release.MetafileTypeScreenImageFromSample, release.MetafileTypeScreenImageFromVideo:
+			data.Screenshots = append(data.Screenshots, d.getFileURL(mf))
+
+		case release.MetafileTypeImage, release.MetafileTypeProofImage:
+			data.Images = append(data.Images, d.getFileURL(mf))
+
+		case release.MetafileTypeContactSheet:
+			if data.ContactSheet == "" {
+				data.ContactSheet = d.getFileURL(mf)
+			}
+
+		case release.MetafileTypeNFOImage:
+			data.NFOImage = d.getFileURL(mf)
+
+		case release.MetafileTypePoster:
+			data.Poster = d.getFileURL(mf)
+
+		case release.MetafileTypeSampleVideo:
+			if data.Sample != nil {
+				continue
+			}
+
+			s := &description.Sample{URL: d.getFileURL(mf)}
+			if hls := mf.Info["hlsManifest"]; hls != "" {
+				s.HLSURL = d.getReleaseFileURL(mf.ReleaseUID, hls)
+			}
+			s.Duration, _ = time.ParseDuration(mf.Info["duration"])
+			s.Width, _ = strconv.Atoi(mf.Info["width"])
+			s.Height, _ = strconv.Atoi(mf.Info["height"])
+			data.Sample = s
+		}
+	}
+
+	if dict != nil {
+		for _, f := range bencode.SortFiles(dict.GetContentFiles()) {
+			data.Files = append(data.Files, &description.File{
+				Path: strings.Join(f.Path, "/"),
+				Size: f.Length,
+			})
+		}
+	}
+
+	return data
+
+}
+
+// RenderDescription renders the destinations description template for a release
+func (d *Destination) RenderDescription(r *Release, dict *bencode.Dict, nfo []byte) (string, error) {
+	return description.Render(d.DescriptionTemplate, d.getDescriptionData(r, dict, nfo))
+}
+
+// PreviewDescription renders the destinations description template for an existing release
+func (d *Destination) PreviewDescription(r *Release) (string, error) {
+
+	torrent, nfo, err := r.getUploadFiles()
+	if err != nil {
+		return "", err
+	}
+
+	var dict *bencode.Dict
+	if torrent != nil {
+		if dict, err = bencode.BDecode(torrent); err != nil {
+			return "", err
+		}
+	}
+
+	return d.RenderDescription(r, dict, nfo)
+
+}
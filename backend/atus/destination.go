@@ -0,0 +1,103 @@
+package atus
+
+import (
+	"atus/backend/config"
+	"atus/backend/destination"
+	"atus/backend/logger"
+	"sort"
+)
+
+type Destination struct {
+	*destination.Destination
+}
+
+// GetAllDestinations returns all destinations sorted by name
+func (a *ATUS) GetAllDestinations() []*Destination {
+	var destinations []*Destination
+	a.destinations.Range(func(key, value interface{}) bool {
+		destinations = append(destinations, value.(*Destination))
+		return true
+	})
+
+	sort.Slice(destinations, func(i, j int) bool {
+		if destinations[i].Name == destinations[j].Name {
+			return destinations[i].UID < destinations[j].UID
+		}
+		return destinations[i].Name < destinations[j].Name
+	})
+
+	return destinations
+}
+
+// GetDestinationByUID returns a destination by its UID
+func (a *ATUS) GetDestinationByUID(uid string) *Destination {
+	if d, ok := a.destinations.Load(uid); ok {
+		return d.(*Destination)
+	}
+	return nil
+}
+
+// AddNewDestination adds a new destination
+func (a *ATUS) AddNewDestination(d *destination.Destination) error {
+	if err := d.Save(); err != nil {
+		return err
+	}
+
+	a.destinations.Store(d.UID, &Destination{
+		Destination: d,
+	})
+
+	logger.Ref(logger.RefDestination, d.UID).Type(logger.TypeUpload).Infof("destination %s added", d.Name)
+
+	return nil
+}
+
+// DeleteDestination deletes a destination
+func (a *ATUS) DeleteDestination(d *Destination) error {
+	a.destinations.Delete(d.UID)
+
+	logger.Ref(logger.RefDestination, d.UID).Type(logger.TypeUpload).Infof("destination %s deleted", d.Name)
+
+	return d.Destination.Delete()
+}
+
+// loadDestinations loads all destinations from the database
+// Installations that were configured before destinations existed have their upload settings
+// stored in the config. These settings are migrated to a destination on first start.
+func (a *ATUS) loadDestinations() error {
+
+	allDestinations, err := destination.GetAll()
+	if err != nil {
+		return err
+	}
+
+	if len(allDestinations) == 0 && config.GetString("UPLOAD__API_URL") != "" {
+		d := destination.New()
+		d.Name = "Default"
+		d.Enabled = true
+		d.APIURL = config.GetString("UPLOAD__API_URL")
+		d.APIAuthToken = config.GetString("API__AUTH_TOKEN")
+		d.TrackerAnnounceURL = config.GetString("UPLOAD__TRACKER_ANNOUNCE_URL")
+		d.UserAnnounceURL = config.GetString("UPLOAD__USER_ANNOUNCE_URL")
+		d.UserID = config.GetString("UPLOAD__USER_ID")
+		d.Comment = config.GetString("UPLOAD__COMMENT")
+		d.CreatedBy = config.GetString("UPLOAD__CREATED_BY")
+
+		if err := d.Save(); err != nil {
+			return err
+		}
+
+		logger.Ref(logger.RefDestination, d.UID).Type(logger.TypeUpload).Infof("migrated upload settings to destination %s", d.Name)
+
+		allDestinations = append(allDestinations, d)
+	}
+
+	for _, d := range allDestinations {
+		a.destinations.Store(d.UID, &Destination{
+			Destination: d,
+		})
+	}
+
+	return nil
+
+}
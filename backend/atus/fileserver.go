@@ -8,7 +8,8 @@ import (
 	"atus/backend/scheduler"
 	"context"
 	"errors"
-	"math/rand"
+	"fmt"
+	"os"
 	"path"
 	"sort"
 	"sync"
@@ -134,56 +135,6 @@ func (f *Fileserver) Disable(a *ATUS) error {
 	return f.Fileserver.Save()
 }
 
-type FileserverAllocationMethod string
-
-const (
-	FileserverAllocationMethodFill     = "FILL"
-	FileserverAllocationMethodMostFree = "MOST_FREE"
-	FileserverAllocationMethodRandom   = "RANDOM"
-)
-
-// GetFileserverForRelease selects a fileserver based on the allocation method
-func (a *ATUS) GetFileserverForRelease(method FileserverAllocationMethod, release *Release) *Fileserver {
-
-	servers := a.GetAllFileservers()
-
-	// filter out disabled, unreachable, full or servers with insufficient space
-	vi := 0
-	for _, s := range servers {
-		if !s.Fileserver.Enabled ||
-			s.Fileserver.Statistics == nil ||
-			s.Fileserver.Statistics.DiskFreeSpace < s.Fileserver.MinFreeDiskSpace ||
-			s.Fileserver.Statistics.DiskFreeSpace < release.Size {
-			continue
-		}
-		servers[vi] = s
-		vi++
-	}
-
-	servers = servers[:vi]
-
-	if len(servers) == 0 {
-		return nil
-	}
-
-	if method == FileserverAllocationMethodRandom {
-		rand.Seed(time.Now().UnixNano())
-		return servers[rand.Intn(len(servers))]
-	}
-
-	// sort by allocation method
-	sort.Slice(servers, func(i, j int) bool {
-		if method == FileserverAllocationMethodFill {
-			return servers[i].Fileserver.Statistics.DiskFreeSpace < servers[j].Fileserver.Statistics.DiskFreeSpace
-		}
-
-		return servers[i].Fileserver.Statistics.DiskFreeSpace > servers[j].Fileserver.Statistics.DiskFreeSpace
-	})
-
-	return servers[0]
-
-}
-
 // GetDownloadState returns the download state of a release
 func (a *ATUS) GetDownloadState(fsUID, torrentHash string) (*fileserver.ListFile, error) {
 
@@ -228,8 +179,12 @@ func (f *Fileserver) updateListTask(ctx context.Context, a *ATUS) {
 		return
 	}
 
+	f.m.RLock()
+	oldList := f.listCache
+	f.m.RUnlock()
+
 	for _, file := range list {
-		old, ok := f.listCache[file.Hash]
+		old, ok := oldList[file.Hash]
 		if !ok || (old.State != file.State || old.DownloadRate != file.DownloadRate || old.Done != file.Done || old.ETA != file.ETA) {
 			a.OnDownloadStateChanged(file)
 		}
@@ -240,7 +195,9 @@ func (f *Fileserver) updateListTask(ctx context.Context, a *ATUS) {
 		newList[t.Hash] = t
 	}
 
+	f.m.Lock()
 	f.listCache = newList
+	f.m.Unlock()
 
 }
 
@@ -262,7 +219,7 @@ func (a *ATUS) getMetaFilesTask(ctx context.Context, f *Fileserver) {
 		// build a list of all files that need to be downloaded
 		metaIndices := []int{}
 		metaMap := make(map[int]*release.MetaFile)
-		for _, mf := range r.MetaFiles {
+		for _, mf := range r.GetMetaFiles() {
 			if mf.Index != -1 && mf.State == release.MetafileStateUnknown {
 				metaIndices = append(metaIndices, mf.Index)
 				metaMap[mf.Index] = mf
@@ -295,12 +252,27 @@ func (a *ATUS) getMetaFilesTask(ctx context.Context, f *Fileserver) {
 					continue
 				}
 
+				// the release video is read from the fileserver by the sample queue
+				if mf.Type == release.MetafileTypeReleaseVideo {
+					logWithRef.Debugf("file %d for %s is the release video, adding to sample queue", fs.Index, r.Hash)
+
+					mf.State = release.MetafileStateDownloaded
+					if err := mf.Save(); err != nil {
+						logWithRef.Type(logger.TypeGeneric).Errorf("failed to save metafile: %v", err)
+						continue
+					}
+
+					a.queueSample(r)
+					anyUpdated = true
+					continue
+				}
+
 				// We found a meta file that is completed, download it
 				logWithRef.Debugf("file %d for %s is completed, downloading", fs.Index, r.Hash)
-				err = f.Fileserver.DownloadFile(fs.Index, r.Hash, path.Join(config.Base.Folders.Data, mf.ReleaseUID, mf.FileName))
-				if err != nil {
-					// ToDo: mark release  / metafile as broken
-					logWithRef.Errorf("failed to download file: %v", err)
+				if err := a.downloadMetaFile(ctx, f, r, mf); err != nil {
+					// the file stays in state UNKNOWN and will be downloaded again on the next run.
+					// interrupted downloads are resumed, corrupt files are discarded
+					logWithRef.Errorf("failed to download file %s: %v", mf.FileName, err)
 					continue
 				}
 
@@ -314,7 +286,7 @@ func (a *ATUS) getMetaFilesTask(ctx context.Context, f *Fileserver) {
 					logWithRef.Debugf("file %d for %s is a sample, adding to queue", fs.Index, r.Hash)
 
 					// add to sample queue
-					a.sampleQueue <- r
+					a.queueSample(r)
 				}
 
 				mf.State = newState
@@ -325,6 +297,21 @@ func (a *ATUS) getMetaFilesTask(ctx context.Context, f *Fileserver) {
 					continue
 				}
 
+				if mf.Type == release.MetafileTypeNFO {
+					if err := a.renderNFO(r, mf); err != nil {
+						logger.Ref(logger.RefRelease, r.UID).Type(logger.TypeGeneric).Errorf("failed to render nfo image: %v", err)
+					}
+
+					if err := a.extractExternalIDs(r, mf); err != nil {
+						logger.Ref(logger.RefRelease, r.UID).Type(logger.TypeGeneric).Errorf("failed to extract external ids from nfo: %v", err)
+					}
+
+					// ids of the nfo find movies and shows the release name didn't
+					if r.GetMetadata() == nil {
+						a.enrichReleaseAsync(r)
+					}
+				}
+
 				anyUpdated = true
 
 				logger.Ref(logger.RefRelease, f.UID).Debugf("[FS %s] successfully downloaded metafile %s", f.UID, mf.FileName)
@@ -340,6 +327,42 @@ func (a *ATUS) getMetaFilesTask(ctx context.Context, f *Fileserver) {
 	})
 }
 
+// downloadMetaFile downloads a meta file from the fileserver.
+// The size of the downloaded file is checked against the torrent's file list and the data is verified
+// against the torrent's piece hashes if the file is piece-aligned
+func (a *ATUS) downloadMetaFile(ctx context.Context, f *Fileserver, r *Release, mf *release.MetaFile) error {
+
+	dict, err := r.GetTorrentDict()
+	if err != nil {
+		return err
+	}
+
+	files := dict.GetFiles()
+	if mf.Index < 0 || mf.Index >= len(files) {
+		return fmt.Errorf("file index %d not found in torrent", mf.Index)
+	}
+
+	opts := &fileserver.DownloadOptions{
+		ExpectedSize: files[mf.Index].Length,
+		Verify: func(file *os.File) error {
+			verified, err := dict.VerifyFile(mf.Index, file)
+			if err != nil {
+				return err
+			}
+
+			logger.Ref(logger.RefRelease, r.UID).Type(logger.TypeFileserver).Debugf("verified %d pieces of %s", verified, mf.FileName)
+
+			return nil
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.GetInt64("FILESERVER__DOWNLOAD_TIMEOUT"))*time.Second)
+	defer cancel()
+
+	return f.Fileserver.DownloadFile(ctx, mf.Index, r.Hash, path.Join(config.Base.Folders.Data, mf.ReleaseUID, mf.FileName), opts)
+
+}
+
 func (a *ATUS) GetFileserverStatistics() []map[string]interface{} {
 
 	var ret []map[string]interface{}
@@ -348,11 +371,15 @@ func (a *ATUS) GetFileserverStatistics() []map[string]interface{} {
 
 		fs := v.(*Fileserver)
 
+		reservedSpace, activeDownloads := a.getFileserverReservations(fs, "")
+
 		ret = append(ret, map[string]interface{}{
-			"uid":        fs.UID,
-			"name":       fs.Name,
-			"enabled":    fs.Enabled,
-			"statistics": fs.Statistics,
+			"uid":             fs.UID,
+			"name":            fs.Name,
+			"enabled":         fs.Enabled,
+			"statistics":      fs.Statistics,
+			"reservedSpace":   reservedSpace,
+			"activeDownloads": activeDownloads,
 		})
 
 		return true
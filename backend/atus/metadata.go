@@ -0,0 +1,161 @@
+package atus
+
+import (
+	"atus/backend/category"
+	"atus/backend/config"
+	"atus/backend/logger"
+	"atus/backend/metadata"
+	"atus/backend/release"
+	"context"
+	"errors"
+	"os"
+	"path"
+	"time"
+)
+
+// getMetadataQuery returns nil for categories that are neither movies nor shows
+func getMetadataQuery(r *Release) *metadata.Query {
+
+	attributes := release.ParseName(r.Name)
+
+	var tv bool
+	switch category.Name(r.Category) {
+	case category.TV:
+		tv = true
+	case category.Movie, category.Docu:
+		// documentaries can be movies or series
+		tv = attributes.Season > 0
+	default:
+		return nil
+	}
+
+	return &metadata.Query{
+		ExternalIDs: r.GetExternalIDs(),
+		Title:       attributes.Title,
+		Year:        attributes.Year,
+		TV:          tv,
+	}
+
+}
+
+// enrichReleaseAsync runs enrichRelease in the background, lookups can take up to 20 seconds.
+// Releases that are already being looked up are skipped
+func (a *ATUS) enrichReleaseAsync(r *Release) {
+
+	if _, running := a.enrichingReleases.LoadOrStore(r.UID, struct{}{}); running {
+		return
+	}
+
+	go func() {
+		defer a.enrichingReleases.Delete(r.UID)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*20)
+		defer cancel()
+
+		if err := a.enrichRelease(ctx, r); err != nil {
+			logger.Ref(logger.RefRelease, r.UID).Type(logger.TypeGeneric).Errorf("failed to get metadata: %v", err)
+			return
+		}
+
+		a.OnMetaFilesUpdated(r)
+	}()
+
+}
+
+// isEnriching returns true while the metadata of the release is looked up by enrichReleaseAsync
+func (a *ATUS) isEnriching(r *Release) bool {
+	_, running := a.enrichingReleases.Load(r.UID)
+	return running
+}
+
+// enrichRelease looks up the movie or show of the release and downloads its poster.
+// The caller has to call OnMetaFilesUpdated
+func (a *ATUS) enrichRelease(ctx context.Context, r *Release) error {
+
+	if !config.GetBool("METADATA__ENABLED") {
+		return nil
+	}
+
+	q := getMetadataQuery(r)
+	if q == nil {
+		return nil
+	}
+
+	logWithRef := logger.Ref(logger.RefRelease, r.UID).Type(logger.TypeRelease)
+
+	m, err := metadata.Lookup(ctx, metadata.Providers(), q)
+	if errors.Is(err, metadata.ErrNotFound) {
+		logWithRef.Debugf("no metadata found for %s", q.Title)
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := release.SaveMetadata(r.UID, m); err != nil {
+		return err
+	}
+
+	r.setMetadata(m)
+
+	logWithRef.Infof("metadata found on %s: %s (%d)", m.Provider, m.Title, m.Year)
+
+	// the provider knows ids the release didn't have (e.g. the tmdb id of an imdb link).
+	// Title searches can match the wrong movie, their ids are not trusted
+	if q.HasIDs() {
+		if _, err := r.mergeExternalIDs(m.ExternalIDs); err != nil {
+			return err
+		}
+	}
+
+	if m.PosterURL == "" || !config.GetBool("METADATA__DOWNLOAD_POSTER") {
+		return nil
+	}
+
+	return a.savePoster(ctx, r, m)
+
+}
+
+// savePoster downloads the poster of the metadata. An existing poster is replaced
+func (a *ATUS) savePoster(ctx context.Context, r *Release, m *release.Metadata) error {
+
+	buf, ext, err := metadata.GetPoster(ctx, m.PosterURL)
+	if err != nil {
+		return err
+	}
+
+	fileName := "poster_" + r.UID + ext
+	if err := os.WriteFile(path.Join(config.Base.Folders.Data, r.UID, fileName), buf, 0644); err != nil {
+		return err
+	}
+
+	old, pm := r.copyMetaFileByType(release.MetafileTypePoster, fileName)
+
+	pm.Info = release.MetaInfo{
+		"provider": m.Provider,
+	}
+
+	if err := pm.Save(); err != nil {
+		return err
+	}
+
+	r.replaceMetaFile(old, pm)
+
+	if old != nil && old.FileName != fileName {
+		os.Remove(path.Join(config.Base.Folders.Data, r.UID, old.FileName))
+	}
+
+	return nil
+
+}
+
+// getPoster returns the poster of the release, nil if it has none
+func (r *Release) getPoster() *release.MetaFile {
+	for _, mf := range r.GetMetaFiles() {
+		if mf.Type == release.MetafileTypePoster && mf.State == release.MetafileStateProcessed {
+			return mf
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package atus
+
+import (
+	"atus/backend/release"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestReplaceMetaFile(t *testing.T) {
+
+	r := &Release{UID: "poster-release"}
+
+	old, pm := r.copyMetaFileByType(release.MetafileTypePoster, "poster_a.jpg")
+	if old != nil {
+		t.Fatal("expected no poster")
+	}
+
+	if err := pm.Save(); err != nil {
+		t.Fatal(err)
+	}
+	r.addMetaFile(pm)
+
+	// readers keep using the meta files they got, the background lookup adds and replaces meta files
+	snapshot := r.GetMetaFiles()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			r.addMetaFile(release.NewMetaFile(r.UID, fmt.Sprintf("screen-%d.jpg", i), -1, release.MetafileTypeScreenImage, release.MetafileStateProcessed, nil, nil))
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		for _, mf := range r.GetMetaFiles() {
+			_ = mf.FileName
+		}
+	}
+
+	old, pm = r.copyMetaFileByType(release.MetafileTypePoster, "poster_b.png")
+	if err := pm.Save(); err != nil {
+		t.Fatal(err)
+	}
+	r.replaceMetaFile(old, pm)
+
+	wg.Wait()
+
+	if snapshot[0].FileName != "poster_a.jpg" {
+		t.Errorf("expected the old poster to be unchanged, got %s", snapshot[0].FileName)
+	}
+
+	if p := r.getPoster(); p == nil || p.FileName != "poster_b.png" {
+		t.Errorf("expected the new poster, got %+v", p)
+	}
+
+	if n := len(r.GetMetaFiles()); n != 21 {
+		t.Errorf("expected 21 meta files, got %d", n)
+	}
+
+	// the copy updates the saved poster instead of adding another one
+	saved, err := release.GetMetaFiles(r.UID, "", release.MetafileTypePoster)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(saved) != 1 || saved[0].FileName != "poster_b.png" {
+		t.Errorf("expected 1 saved poster, got %d", len(saved))
+	}
+
+}
+
+func TestUploadReleaseWaitsForMetadata(t *testing.T) {
+
+	r := &Release{UID: "r", Name: "Release", FileserverUID: "fs1"}
+	a := newTestATUS([]*Fileserver{newTestFileserver("fs1", 0, nil)}, []*Release{r})
+
+	a.enrichingReleases.Store(r.UID, struct{}{})
+
+	if err := a.UploadRelease(context.Background(), r, false); err == nil || !strings.Contains(err.Error(), "looked up") {
+		t.Errorf("expected the upload to wait for the metadata lookup, got %v", err)
+	}
+
+}
@@ -0,0 +1,149 @@
+package atus
+
+import (
+	"atus/backend/config"
+	"atus/backend/description"
+	"atus/backend/helpers"
+	"atus/backend/logger"
+	"atus/backend/release"
+	"errors"
+	"fmt"
+	"image/color"
+	"os"
+	"path"
+	"strings"
+)
+
+var (
+	nfoImageForeground = color.RGBA{200, 200, 200, 255}
+	nfoImageBackground = color.RGBA{0, 0, 0, 255}
+)
+
+// getNFO returns the nfo meta file of the release, nil if it has none
+func (r *Release) getNFO() *release.MetaFile {
+	for _, mf := range r.GetMetaFiles() {
+		if mf.Type == release.MetafileTypeNFO && mf.State == release.MetafileStateProcessed {
+			return mf
+		}
+	}
+	return nil
+}
+
+// getNFOCharset returns the charset override of the nfo, CharsetAuto if it is detected
+func getNFOCharset(m *release.MetaFile) description.Charset {
+	charset, err := description.ParseCharset(m.Info["charset"])
+	if err != nil {
+		return description.CharsetAuto
+	}
+	return charset
+}
+
+// renderNFO renders the nfo as png so trackers can show it as image. An existing image is replaced.
+// The caller has to call OnMetaFilesUpdated
+func (a *ATUS) renderNFO(r *Release, m *release.MetaFile) error {
+
+	if !config.GetBool("NFO__RENDER_IMAGE") {
+		return nil
+	}
+
+	buf, err := m.GetFile()
+	if err != nil {
+		return err
+	}
+
+	charset := getNFOCharset(m)
+	if charset == description.CharsetAuto {
+		charset = description.DetectCharset(buf)
+	}
+
+	png, err := helpers.RenderNFO(description.DecodeNFO(buf, charset), nfoImageForeground, nfoImageBackground).EncodePNG()
+	if err != nil {
+		return err
+	}
+
+	// the charset is part of the name, browsers would show the cached image otherwise
+	fileName := fmt.Sprintf("nfo-%s.png", strings.ToLower(string(charset)))
+	if err := os.WriteFile(path.Join(config.Base.Folders.Data, r.UID, fileName), png, 0644); err != nil {
+		return err
+	}
+
+	old, nim := r.copyMetaFileByType(release.MetafileTypeNFOImage, fileName)
+
+	nim.Info = release.MetaInfo{
+		"charset": string(charset),
+	}
+
+	if err := nim.Save(); err != nil {
+		return err
+	}
+
+	r.replaceMetaFile(old, nim)
+
+	if old != nil && old.FileName != fileName {
+		os.Remove(path.Join(config.Base.Folders.Data, r.UID, old.FileName))
+	}
+
+	return nil
+
+}
+
+// SetNFOCharset overrides the detected charset of the nfo and renders the nfo image again.
+// CharsetAuto removes the override
+func (a *ATUS) SetNFOCharset(r *Release, charset description.Charset) error {
+
+	m := r.getNFO()
+	if m == nil {
+		return errors.New("release has no nfo")
+	}
+
+	if m.Info == nil {
+		m.Info = release.MetaInfo{}
+	}
+
+	if charset == description.CharsetAuto {
+		delete(m.Info, "charset")
+	} else {
+		m.Info["charset"] = string(charset)
+	}
+
+	if err := m.Save(); err != nil {
+		return err
+	}
+
+	logger.Ref(logger.RefRelease, r.UID).Type(logger.TypeGeneric).Infof("nfo charset set to %s", charsetName(charset))
+
+	if err := a.renderNFO(r, m); err != nil {
+		return err
+	}
+
+	a.OnMetaFilesUpdated(r)
+
+	return nil
+
+}
+
+func charsetName(charset description.Charset) string {
+	if charset == description.CharsetAuto {
+		return "auto"
+	}
+	return string(charset)
+}
+
+// extractExternalIDs adds the imdb, tvmaze, tvdb and tmdb ids found in the nfo to the release
+func (a *ATUS) extractExternalIDs(r *Release, m *release.MetaFile) error {
+
+	buf, err := m.GetFile()
+	if err != nil {
+		return err
+	}
+
+	changed, err := r.mergeExternalIDs(release.ExtractExternalIDs(description.DecodeNFO(buf, getNFOCharset(m))))
+	if err != nil || !changed {
+		return err
+	}
+
+	logger.Ref(logger.RefRelease, r.UID).Type(logger.TypeRelease).Debugf("external ids found in nfo: %+v", *r.GetExternalIDs())
+
+	return nil
+
+}
@@ -0,0 +1,234 @@
+package atus
+
+import (
+	"atus/backend/bencode"
+	"atus/backend/config"
+	"atus/backend/logger"
+	"atus/backend/release"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// getDestinationTorrent returns the torrent that is rewritten for the destination.
+// This is the source torrent unless the destination requires re-created torrents
+func (a *ATUS) getDestinationTorrent(ctx context.Context, r *Release, d *Destination, torrent []byte) ([]byte, error) {
+
+	if !d.RecreateTorrent {
+		return torrent, nil
+	}
+
+	dict, err := bencode.BDecode(torrent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode torrent file for release %s: %s", r.Name, err.Error())
+	}
+
+	return a.getRecreatedTorrent(ctx, r, dict, d.GetPieceLength(dict.GetSize()))
+
+}
+
+// failed re-creations are retried after recreateRetryDelay, doubled with every failure up to recreateMaxRetryDelay
+const (
+	recreateRetryDelay    = time.Minute
+	recreateMaxRetryDelay = time.Hour
+)
+
+// recreateJob is the background re-creation of the torrents of a release, see recreatedTorrentsReady
+type recreateJob struct {
+	m        sync.Mutex
+	running  bool
+	failures int
+	retryAt  time.Time
+}
+
+// recreatedTorrentsReady returns true if the torrents of all destinations that require re-created torrents exist.
+// Missing torrents are created in the background, hashing large releases takes a while
+// and must not block the other pending releases
+func (a *ATUS) recreatedTorrentsReady(r *Release) bool {
+
+	if a.hasRecreatedTorrents(r) {
+		a.recreateJobs.Delete(r.UID)
+		return true
+	}
+
+	v, _ := a.recreateJobs.LoadOrStore(r.UID, &recreateJob{})
+	job := v.(*recreateJob)
+
+	job.m.Lock()
+	defer job.m.Unlock()
+
+	if job.running || time.Now().Before(job.retryAt) {
+		return false
+	}
+
+	job.running = true
+
+	go func() {
+		err := a.prepareRecreatedTorrents(context.Background(), r)
+
+		job.m.Lock()
+		defer job.m.Unlock()
+
+		job.running = false
+		if err == nil {
+			job.failures = 0
+			return
+		}
+
+		delay := recreateRetryDelay << job.failures
+		if delay > recreateMaxRetryDelay || delay <= 0 {
+			delay = recreateMaxRetryDelay
+		}
+
+		job.failures++
+		job.retryAt = time.Now().Add(delay)
+
+		logger.Ref(logger.RefRelease, r.UID).Type(logger.TypeFileserver).Errorf("%s, retrying in %s", err.Error(), delay)
+	}()
+
+	return false
+
+}
+
+// hasRecreatedTorrents returns true if the release has the re-created torrents of all enabled destinations
+// that require them
+func (a *ATUS) hasRecreatedTorrents(r *Release) bool {
+
+	var dict *bencode.Dict
+	for _, d := range a.GetAllDestinations() {
+		if !d.Enabled || !d.RecreateTorrent {
+			continue
+		}
+
+		if dict == nil {
+			var err error
+			if dict, err = r.GetTorrentDict(); err != nil {
+				// prepareRecreatedTorrents reports the error
+				return false
+			}
+		}
+
+		if r.getRecreatedTorrentMetaFile(d.GetPieceLength(dict.GetSize())) == nil {
+			return false
+		}
+	}
+
+	return true
+
+}
+
+// prepareRecreatedTorrents creates the torrents of all enabled destinations that require re-created torrents,
+// so uploads and upload previews don't have to wait for the fileserver
+func (a *ATUS) prepareRecreatedTorrents(ctx context.Context, r *Release) error {
+
+	var dict *bencode.Dict
+	for _, d := range a.GetAllDestinations() {
+		if !d.Enabled || !d.RecreateTorrent {
+			continue
+		}
+
+		if dict == nil {
+			var err error
+			if dict, err = r.GetTorrentDict(); err != nil {
+				return err
+			}
+		}
+
+		if _, err := a.getRecreatedTorrent(ctx, r, dict, d.GetPieceLength(dict.GetSize())); err != nil {
+			return fmt.Errorf("failed to re-create torrent for destination %s: %s", d.Name, err.Error())
+		}
+	}
+
+	return nil
+
+}
+
+// getRecreatedTorrent returns a new torrent with the given piece length, created from the data on the fileserver.
+// Torrents are saved as meta files and reused by all destinations with the same piece length.
+func (a *ATUS) getRecreatedTorrent(ctx context.Context, r *Release, source *bencode.Dict, pieceLength int64) ([]byte, error) {
+
+	if mf := r.getRecreatedTorrentMetaFile(pieceLength); mf != nil {
+		return mf.GetFile()
+	}
+
+	fs := a.GetFileserverByUID(r.FileserverUID)
+	if fs == nil {
+		return nil, fmt.Errorf("fileserver %s not found", r.FileserverUID)
+	}
+
+	logWithRef := logger.Ref(logger.RefRelease, r.UID).Type(logger.TypeFileserver)
+	logWithRef.Infof("hashing data of %s on fileserver %s with a piece length of %d", r.Name, fs.Fileserver.Name, pieceLength)
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.GetInt64("FILESERVER__HASH_TIMEOUT"))*time.Second)
+	defer cancel()
+
+	hashes, err := fs.Fileserver.HashPieces(ctx, r.Hash, pieceLength)
+	if err != nil {
+		return nil, err
+	}
+
+	// the fileserver has to hash exactly the files of the source torrent. Padding files of hybrid torrents have no data
+	sourceFiles := source.GetContentFiles()
+	if len(hashes.Files) != len(sourceFiles) {
+		return nil, fmt.Errorf("fileserver returned %d files, the torrent has %d", len(hashes.Files), len(sourceFiles))
+	}
+
+	files := make([]*bencode.File, len(sourceFiles))
+	for i, f := range sourceFiles {
+		h := hashes.Files[i]
+		if strings.Join(h.Path, "/") != strings.Join(f.Path, "/") || h.Length != f.Length {
+			return nil, fmt.Errorf("file %d does not match the torrent. Expected: %s (%d bytes), Got: %s (%d bytes)", i, strings.Join(f.Path, "/"), f.Length, strings.Join(h.Path, "/"), h.Length)
+		}
+
+		files[i] = &bencode.File{
+			Path:   f.Path,
+			Length: f.Length,
+		}
+	}
+
+	metainfo, err := bencode.Create(source.Info.Name, pieceLength, files, hashes.Pieces)
+	if err != nil {
+		return nil, err
+	}
+
+	dict, err := metainfo.Dict()
+	if err != nil {
+		return nil, err
+	}
+
+	if report := bencode.Validate(dict, bencode.DefaultLimits); !report.Valid() {
+		return nil, fmt.Errorf("re-created torrent is invalid: %s", report.String())
+	}
+
+	torrent := metainfo.Bytes()
+
+	mf := release.NewMetaFile(r.UID, fmt.Sprintf("recreated_%d_%s.torrent", pieceLength, r.UID), -1, release.MetafileTypeRecreatedTorrent, release.MetafileStateProcessed, torrent, release.MetaInfo{
+		"pieceLength": strconv.FormatInt(pieceLength, 10),
+		"hash":        metainfo.InfoHash(),
+	})
+
+	if err := mf.Save(); err != nil {
+		return nil, err
+	}
+
+	r.addMetaFile(mf)
+
+	logWithRef.Infof("re-created torrent of %s with %d pieces", r.Name, len(hashes.Pieces)/20)
+
+	return torrent, nil
+
+}
+
+// getRecreatedTorrentMetaFile returns the re-created torrent with the given piece length, nil if there is none
+func (r *Release) getRecreatedTorrentMetaFile(pieceLength int64) *release.MetaFile {
+	pieceLengthStr := strconv.FormatInt(pieceLength, 10)
+	for _, mf := range r.GetMetaFiles() {
+		if mf.Type == release.MetafileTypeRecreatedTorrent && mf.Info["pieceLength"] == pieceLengthStr {
+			return mf
+		}
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package atus
+
+import (
+	"atus/backend/bencode"
+	"atus/backend/config"
+	"atus/backend/destination"
+	"atus/backend/fileserver"
+	"atus/backend/release"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recreateTestFileserver answers hashPieces requests once they are released with respond
+type recreateTestFileserver struct {
+	m        sync.Mutex
+	requests int
+	respond  chan bool // true responds with the piece hashes, false with an error
+}
+
+func (fs *recreateTestFileserver) requestCount() int {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+
+	return fs.requests
+}
+
+func (fs *recreateTestFileserver) serveHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if r.URL.Query().Get("action") != "hashPieces" {
+		http.NotFound(w, r)
+		return
+	}
+
+	fs.m.Lock()
+	fs.requests++
+	fs.m.Unlock()
+
+	if !<-fs.respond {
+		http.Error(w, "failed to read data", http.StatusInternalServerError)
+		return
+	}
+
+	pieceLength, _ := strconv.ParseInt(r.URL.Query().Get("pieceLength"), 10, 64)
+	pieces := (20000 + pieceLength - 1) / pieceLength
+
+	json.NewEncoder(w).Encode(&fileserver.PieceHashes{
+		Files:  []*fileserver.HashedFile{{Path: []string{"Release.mkv"}, Length: 20000}},
+		Pieces: bytes.Repeat([]byte{2}, int(pieces)*20),
+	})
+
+}
+
+// waitForRecreateJob waits until the background job of the release is done
+func waitForRecreateJob(t *testing.T, a *ATUS, uid string) *recreateJob {
+	t.Helper()
+
+	for i := 0; i < 500; i++ {
+		v, ok := a.recreateJobs.Load(uid)
+		if !ok {
+			t.Fatal("expected a re-create job")
+		}
+
+		job := v.(*recreateJob)
+		job.m.Lock()
+		running := job.running
+		job.m.Unlock()
+
+		if !running {
+			return job
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("the re-create job did not finish")
+	return nil
+}
+
+func TestRecreatedTorrentsReady(t *testing.T) {
+
+	dataFolder := config.Base.Folders.Data
+	config.Base.Folders.Data = t.TempDir()
+	t.Cleanup(func() { config.Base.Folders.Data = dataFolder })
+
+	tfs := &recreateTestFileserver{respond: make(chan bool)}
+	ts := httptest.NewServer(http.HandlerFunc(tfs.serveHTTP))
+	t.Cleanup(ts.Close)
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs := newTestFileserver("fs1", 0, func(f *fileserver.Fileserver) { f.URL = u })
+
+	metainfo, err := bencode.Create("Release.mkv", 16384, []*bencode.File{
+		{Path: []string{"Release.mkv"}, Length: 20000},
+	}, bytes.Repeat([]byte{1}, 40))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(path.Join(config.Base.Folders.Data, "r"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path.Join(config.Base.Folders.Data, "r", "r.torrent"), metainfo.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Release{
+		UID:           "r",
+		Name:          "Release",
+		Hash:          "h",
+		State:         release.StateDownloaded,
+		FileserverUID: "fs1",
+		MetaFiles: []*release.MetaFile{
+			release.NewMetaFile("r", "r.torrent", -1, release.MetafileTypeTorrent, release.MetafileStateProcessed, nil, nil),
+		},
+	}
+
+	a := newTestATUS([]*Fileserver{fs}, []*Release{r})
+	a.destinations.Store("d", &Destination{&destination.Destination{UID: "d", Name: "D", Enabled: true, RecreateTorrent: true}})
+
+	// the fileserver is hashing, the scheduler is not blocked and doesn't start another job
+	if a.recreatedTorrentsReady(r) {
+		t.Fatal("expected the torrent to be missing")
+	}
+
+	if a.recreatedTorrentsReady(r) {
+		t.Fatal("expected the torrent to be missing")
+	}
+
+	// a failed job is retried after a delay
+	tfs.respond <- false
+	job := waitForRecreateJob(t, a, r.UID)
+
+	if job.failures != 1 || time.Until(job.retryAt) < recreateRetryDelay-time.Second {
+		t.Fatalf("expected a retry in %s, got %d failures and a retry in %s", recreateRetryDelay, job.failures, time.Until(job.retryAt))
+	}
+
+	if a.recreatedTorrentsReady(r) || tfs.requestCount() != 1 {
+		t.Fatalf("expected no retry before the delay, got %d requests", tfs.requestCount())
+	}
+
+	// the retry creates the torrent
+	job.m.Lock()
+	job.retryAt = time.Now()
+	job.m.Unlock()
+
+	if a.recreatedTorrentsReady(r) {
+		t.Fatal("expected the torrent to be missing")
+	}
+
+	tfs.respond <- true
+	waitForRecreateJob(t, a, r.UID)
+
+	if !a.recreatedTorrentsReady(r) {
+		t.Fatal("expected the re-created torrent")
+	}
+
+	if tfs.requestCount() != 2 {
+		t.Errorf("expected 2 hash requests, got %d", tfs.requestCount())
+	}
+
+	if _, ok := a.recreateJobs.Load(r.UID); ok {
+		t.Error("expected the job to be removed")
+	}
+
+}
@@ -1,16 +1,21 @@
 package atus
 
 import (
+	"atus/backend/bencode"
+	"atus/backend/category"
 	"atus/backend/config"
+	"atus/backend/imaging"
 	"atus/backend/logger"
 	"atus/backend/predb"
 	"atus/backend/release"
 	"atus/backend/sqlite"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -26,8 +31,142 @@ type Release struct {
 	Pre           time.Time
 	State         release.ReleaseState
 	SourceUID     string
-	MetaFiles     []*release.MetaFile
 	FileserverUID string
+
+	// meta files are added and replaced in the background (e.g. the poster), use GetMetaFiles and addMetaFile
+	MetaFiles []*release.MetaFile
+
+	// guards MetaFiles, externalIDs and metadata, all of them change in the background.
+	// externalIDs and metadata are replaced on change and never modified, see GetExternalIDs and GetMetadata
+	m           sync.RWMutex
+	externalIDs *release.ExternalIDs
+
+	// nil if no metadata provider knows the movie or show
+	metadata *release.Metadata
+}
+
+// GetMetadata returns the movie or show of the release, nil if it is unknown
+func (r *Release) GetMetadata() *release.Metadata {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	return r.metadata
+}
+
+func (r *Release) setMetadata(m *release.Metadata) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.metadata = m
+}
+
+// GetExternalIDs returns the external ids of the release, never nil.
+// The returned ids must not be modified, use mergeExternalIDs
+func (r *Release) GetExternalIDs() *release.ExternalIDs {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	if r.externalIDs == nil {
+		return &release.ExternalIDs{}
+	}
+	return r.externalIDs
+}
+
+// mergeExternalIDs adds the unknown ids of other to the release and saves them.
+// Returns true if an id was added
+func (r *Release) mergeExternalIDs(other *release.ExternalIDs) (bool, error) {
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	merged, changed := r.externalIDs.Merge(other)
+	if !changed {
+		return false, nil
+	}
+
+	if err := release.SaveExternalIDs(r.UID, merged); err != nil {
+		return false, err
+	}
+
+	r.externalIDs = merged
+	return true, nil
+
+}
+
+// GetMetaFiles returns a copy of the meta files of the release
+func (r *Release) GetMetaFiles() []*release.MetaFile {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	return append([]*release.MetaFile(nil), r.MetaFiles...)
+}
+
+// addMetaFile adds a new meta file to the release
+func (r *Release) addMetaFile(mf *release.MetaFile) {
+	r.replaceMetaFile(nil, mf)
+}
+
+// replaceMetaFile replaces old with mf. mf is added if old is nil or not a meta file of the release
+func (r *Release) replaceMetaFile(old, mf *release.MetaFile) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	for i, f := range r.MetaFiles {
+		if old != nil && f == old {
+			r.MetaFiles[i] = mf
+			return
+		}
+	}
+
+	r.MetaFiles = append(r.MetaFiles, mf)
+}
+
+// copyMetaFileByType returns the first meta file of the given type and a processed copy of it with the new file name.
+// The copy is a new meta file if there is none. Meta files are not modified in place, readers of the release may
+// use them concurrently. Save the copy and pass both to replaceMetaFile
+func (r *Release) copyMetaFileByType(t release.MetaFileType, fileName string) (*release.MetaFile, *release.MetaFile) {
+
+	old := r.getMetaFileByType(t)
+	if old == nil {
+		return nil, release.NewMetaFile(r.UID, fileName, -1, t, release.MetafileStateProcessed, nil, nil)
+	}
+
+	mf := *old
+	mf.FileName = fileName
+	mf.State = release.MetafileStateProcessed
+
+	return old, &mf
+
+}
+
+// getMetaFileByType returns the first meta file of the given type, nil if there is none
+func (r *Release) getMetaFileByType(t release.MetaFileType) *release.MetaFile {
+	for _, mf := range r.GetMetaFiles() {
+		if mf.Type == t {
+			return mf
+		}
+	}
+	return nil
+}
+
+// GetTorrentDict returns the decoded source torrent file of the release
+func (r *Release) GetTorrentDict() (*bencode.Dict, error) {
+
+	for _, mf := range r.GetMetaFiles() {
+		if mf.Type != release.MetafileTypeTorrent {
+			continue
+		}
+
+		file, err := mf.GetFile()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get torrent file: %s", err)
+		}
+
+		return bencode.BDecode(file)
+	}
+
+	return nil, errors.New("no torrent file found")
+
 }
 
 func (a *ATUS) loadPendingReleases() ([]*Release, error) {
@@ -43,14 +182,18 @@ func (a *ATUS) loadPendingReleases() ([]*Release, error) {
 			category_raw,
 			source_uid,
 			fileserver_uid,
-			pre
+			pre,
+			external_ids,
+			metadata
 		FROM 
 			releases 
 		WHERE 
-			state NOT IN(?, ?, ?)`,
+			state NOT IN(?, ?, ?, ?, ?)`,
 		release.StateUploaded,
 		release.StateGeneralError,
 		release.StateUploadError,
+		release.StateRejected,
+		release.StateQuarantined,
 	)
 
 	if err != nil {
@@ -62,7 +205,7 @@ func (a *ATUS) loadPendingReleases() ([]*Release, error) {
 	var pendingReleases []*Release
 	for rows.Next() {
 		r := &Release{}
-		var preStr string
+		var preStr, externalIDs, metadata string
 		if err := rows.Scan(
 			&r.UID,
 			&r.Name,
@@ -74,10 +217,15 @@ func (a *ATUS) loadPendingReleases() ([]*Release, error) {
 			&r.SourceUID,
 			&r.FileserverUID,
 			&preStr,
+			&externalIDs,
+			&metadata,
 		); err != nil {
 			return nil, err
 		}
 
+		r.externalIDs = release.ParseExternalIDs(externalIDs)
+		r.metadata = release.ParseMetadata(metadata)
+
 		if lastCheck, err := time.Parse(time.RFC3339, preStr); err == nil {
 			r.Pre = lastCheck
 		}
@@ -96,8 +244,8 @@ func (a *ATUS) loadPendingReleases() ([]*Release, error) {
 
 		// put unprocessed samples in queue
 		for _, m := range upm {
-			if m.Type == release.MetafileTypeSampleVideo && m.State == release.MetafileStateDownloaded {
-				a.sampleQueue <- pr
+			if (m.Type == release.MetafileTypeSampleVideo || m.Type == release.MetafileTypeReleaseVideo) && m.State == release.MetafileStateDownloaded {
+				a.queueSample(pr)
 			}
 		}
 	}
@@ -109,7 +257,7 @@ func (a *ATUS) loadPendingReleases() ([]*Release, error) {
 func (a *ATUS) GetReleaseByUID(uid string) *Release {
 
 	r := &Release{}
-	var preStr string
+	var preStr, externalIDs, metadata string
 	if err := sqlite.Conn.QueryRow(
 		`SELECT 
 			uid,
@@ -121,7 +269,9 @@ func (a *ATUS) GetReleaseByUID(uid string) *Release {
 			category_raw,
 			source_uid,
 			fileserver_uid,
-			pre
+			pre,
+			external_ids,
+			metadata
 		FROM 
 			releases 
 		WHERE 
@@ -138,10 +288,15 @@ func (a *ATUS) GetReleaseByUID(uid string) *Release {
 		&r.SourceUID,
 		&r.FileserverUID,
 		&preStr,
+		&externalIDs,
+		&metadata,
 	); err != nil {
 		return nil
 	}
 
+	r.externalIDs = release.ParseExternalIDs(externalIDs)
+	r.metadata = release.ParseMetadata(metadata)
+
 	if lastCheck, err := time.Parse(time.RFC3339, preStr); err == nil {
 		r.Pre = lastCheck
 	}
@@ -184,8 +339,15 @@ func (a *ATUS) DeleteRelease(uid, hash string) error {
 		return err
 	}
 
+	// delete upload states from database
+	_, err = sqlite.Conn.Exec("DELETE FROM release_uploads WHERE release_uid = ?", uid)
+	if err != nil {
+		return err
+	}
+
 	// Delete data folder
 	os.RemoveAll(filepath.Join(config.Base.Folders.Data, uid))
+	imaging.RemoveCache(uid)
 
 	return nil
 }
@@ -212,9 +374,9 @@ func (a *ATUS) updatePendingReleaseState(r *Release, state release.ReleaseState)
 
 	r.State = state
 
-	// remove release from pending releases if state is uploaded or error
-	if state == release.StateUploaded || strings.HasSuffix(string(state), "ERROR") {
-		a.pendingReleases.Delete(r.UID)
+	// remove release from pending releases if state is uploaded, rejected or error
+	if state == release.StateUploaded || state == release.StateRejected || strings.HasSuffix(string(state), "ERROR") {
+		a.pendingReleases.Delete(r.Hash)
 	}
 
 	query := `UPDATE releases SET state = ?`
@@ -277,6 +439,9 @@ func (a *ATUS) onNewRelease(r *release.Release) {
 		}
 	}
 
+	// predb.ovh knows the imdb or tvmaze link of some releases
+	r.ExternalIDs, _ = r.ExternalIDs.Merge(release.ExtractExternalIDs(pre.URL))
+
 	// -- Get Category ----------------------------
 	logWithRef.Debugf("getting internal category for preDBCategory: %v", pre.Category)
 	cat := a.GetCategoryByName(pre.Category.Name)
@@ -305,9 +470,12 @@ func (a *ATUS) onNewRelease(r *release.Release) {
 
 	a.OnReleaseAdded(r)
 
-	// -- add to pending releases queue -----------
+	if r.State == release.StateQuarantined {
+		logWithRef.Warningf("release %s was quarantined, the torrent failed validation", r.Name)
+		return
+	}
 
-	a.pendingReleases.Store(r.Hash, &Release{
+	pr := &Release{
 		UID:         r.UID,
 		Name:        r.Name,
 		Hash:        r.Hash,
@@ -318,7 +486,15 @@ func (a *ATUS) onNewRelease(r *release.Release) {
 		CategoryRaw: pre.CategoryRaw,
 		Pre:         pre.At,
 		MetaFiles:   r.MetaFiles,
-	})
+		externalIDs: r.ExternalIDs,
+	}
+
+	// -- add to pending releases queue -----------
+	a.pendingReleases.Store(r.Hash, pr)
+
+	// -- look up movie or show -------------------
+	// in the background, the next release waits in releaseChan until this one is queued
+	a.enrichReleaseAsync(pr)
 
 }
 
@@ -349,7 +525,7 @@ func (a *ATUS) processPendingReleasesTask(ctx context.Context) {
 			// -- upload meta file -----------------------
 			var torrentFile []byte
 
-			for _, mf := range r.MetaFiles {
+			for _, mf := range r.GetMetaFiles() {
 				if mf.State != release.MetafileStateProcessed || mf.Type != release.MetafileTypeTorrent {
 					continue
 
@@ -449,12 +625,30 @@ func (a *ATUS) processPendingReleasesTask(ctx context.Context) {
 
 		// == handle downloaded releases ==============================================================
 		if r.State == release.StateDownloaded {
-			if err := a.UploadRelease(ctx, r); err != nil {
+			// the data is complete, destinations that require re-created torrents need them for previews and uploads
+			if !a.recreatedTorrentsReady(r) {
+				return true
+			}
+
+			// the metadata, poster and ids of the lookup are part of the payload
+			if a.isEnriching(r) {
+				return true
+			}
+
+			if a.isApprovalRequired(r) {
+				// wait until all meta files are processed, the operator has to see the complete payload
+				if r.metaFilesProcessed() == nil {
+					a.updatePendingReleaseState(r, release.StateAwaitingApproval)
+				}
+				return true
+			}
+
+			if err := a.UploadRelease(ctx, r, false); err != nil {
 				logWithRef.Errorf(err.Error())
 				return true
 			}
 
-			logWithRef.Infof("release was successfully uploaded to tracker")
+			logWithRef.Infof("release was successfully uploaded to all destinations")
 		}
 
 		return true
@@ -463,41 +657,175 @@ func (a *ATUS) processPendingReleasesTask(ctx context.Context) {
 
 }
 
-func (a *ATUS) UploadRelease(ctx context.Context, r *Release) error {
+// metaFilesProcessed returns an error if a meta file of the release is not processed yet
+func (r *Release) metaFilesProcessed() error {
+	for _, mf := range r.GetMetaFiles() {
+		if mf.State != release.MetafileStateProcessed && mf.State != release.MetafileStateError {
+			return fmt.Errorf("meta file %s is not processed", mf.FileName)
+		}
+	}
+	return nil
+}
+
+// UploadRelease uploads the release to the given destinations
+// If no destinations are passed, the release is uploaded to all enabled destinations it wasn't uploaded to yet
+// and which filters accept the release.
+// Destinations that are passed explicitly are uploaded to regardless of their filters and previous uploads.
+// ignoreDupeCheckErrors uploads the release even if a tracker couldn't be searched for existing torrents
+func (a *ATUS) UploadRelease(ctx context.Context, r *Release, ignoreDupeCheckErrors bool, destinationUIDs ...string) error {
 
 	fs := a.GetFileserverByUID(r.FileserverUID)
 	if fs == nil {
 		return fmt.Errorf("fileserver %s not found (is nil)", r.FileserverUID)
 	}
 
-	// check if all meta files are processed
-	for _, mf := range r.MetaFiles {
-		if mf.State != release.MetafileStateProcessed && mf.State != release.MetafileStateError {
-			return fmt.Errorf("meta file %s is not processed", mf.FileName)
+	if err := r.metaFilesProcessed(); err != nil {
+		return err
+	}
+
+	if a.isEnriching(r) {
+		return fmt.Errorf("metadata of release %s is being looked up", r.Name)
+	}
+
+	var destinations []*Destination
+	for _, uid := range destinationUIDs {
+		d := a.GetDestinationByUID(uid)
+		if d == nil {
+			return fmt.Errorf("destination %s not found", uid)
 		}
+		destinations = append(destinations, d)
 	}
 
-	newDict, err := a.UploadReleaseToTracker(ctx, r)
-	if err != nil {
-		// There is currently no auto-retry mechanism
-		// Once a release is in an error state, it will have to be manually uploaded through the web interface
+	isManual := len(destinations) > 0
+	if !isManual {
+		for _, d := range a.GetAllDestinations() {
+			if d.Enabled {
+				destinations = append(destinations, d)
+			}
+		}
+	}
+
+	if len(destinations) == 0 {
 		a.updatePendingReleaseState(r, release.StateUploadError)
-		return fmt.Errorf("failed to upload release to tracker: %s", err.Error())
+		return errors.New("no destinations configured")
 	}
 
-	// the release was uploaded successfully
-	// we now have to prepare the .torrent file with the trackers announce url
-	newDict.Announce = config.GetString("UPLOAD__USER_ANNOUNCE_URL")
-	newTorrent, err := newDict.BEncode()
+	logWithRef := logger.Ref(logger.RefRelease, r.UID).Type(logger.TypeUpload)
+
+	var failed []string
+	for _, d := range destinations {
+
+		u, err := release.GetUpload(r.UID, d.UID)
+		if err != nil {
+			return err
+		}
+
+		if u == nil {
+			u = release.NewUpload(r.UID, d.UID)
+		}
+
+		if !isManual {
+			if u.State == release.UploadStateUploaded || u.State == release.UploadStateDupe {
+				continue
+			}
+
+			if accepted, err := d.Accepts(category.Name(r.Category), r.Name, r.Size, r.GetExternalIDs()); !accepted {
+				logWithRef.Infof("release is not accepted by destination %s: %s", d.Name, err.Error())
+
+				u.State = release.UploadStateSkipped
+				u.Message = err.Error()
+				if err := u.Save(); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		res, err := a.uploadReleaseToDestination(ctx, r, fs, d, &UploadOptions{
+			Upload:                u,
+			IgnoreDupeCheckErrors: ignoreDupeCheckErrors,
+		})
+
+		var dupeErr *DupeError
+		switch {
+		case errors.As(err, &dupeErr):
+			logWithRef.Infof("release was not uploaded to destination %s: %s", d.Name, err.Error())
+			u.State = release.UploadStateDupe
+			u.Message = err.Error()
+			u.TorrentID = dupeErr.Existing.TorrentID
+			u.TorrentURL = dupeErr.Existing.TorrentURL
+
+		case err != nil:
+			logWithRef.Errorf("failed to upload release to destination %s: %s", d.Name, err.Error())
+			u.Message = err.Error()
+			failed = append(failed, d.Name)
+
+			// the trackers response to this or an earlier attempt got lost, the state stays UPLOADING
+			// so the next attempt keeps the idempotency key
+			if u.State != release.UploadStateUploading {
+				u.State = release.UploadStateUploadError
+			}
+
+		case d.DryRun:
+			logWithRef.Infof("dry run for destination %s finished", d.Name)
+			u.State = release.UploadStateDryRun
+			u.Message = "payload written to " + d.getDryRunFolder(r)
+
+		case res.Reconciled:
+			logWithRef.Infof("release already exists on destination %s and was not uploaded again", d.Name)
+			u.State = release.UploadStateUploaded
+			u.Message = "reconciled with the existing torrent on the tracker"
+
+		default:
+			logWithRef.Infof("release was uploaded to destination %s", d.Name)
+			u.State = release.UploadStateUploaded
+			u.Message = ""
+		}
+
+		if res != nil {
+			u.TorrentID = res.TorrentID
+			u.TorrentURL = res.TorrentURL
+		}
+
+		if err := u.Save(); err != nil {
+			return err
+		}
+	}
+
+	// the release state reflects the uploads to all destinations, not only the ones of this run
+	uploads, err := release.GetUploads(r.UID)
 	if err != nil {
-		a.updatePendingReleaseState(r, release.StateUploadError)
-		return fmt.Errorf("failed to encode torrent file: %s", err.Error())
+		return err
 	}
 
-	// send new torrent to fileserver
-	if _, err := fs.Fileserver.AddTorrent(ctx, newTorrent, r.Name+".torrent", config.GetString("FILESERVER__UPLOAD_LABEL")); err != nil {
+	hasError, isUploaded := false, false
+	for _, u := range uploads {
+		if a.GetDestinationByUID(u.DestinationUID) == nil {
+			continue
+		}
+
+		switch u.State {
+		case release.UploadStateUploadError, release.UploadStateUploading:
+			hasError = true
+		case release.UploadStateUploaded, release.UploadStateDryRun:
+			isUploaded = true
+		}
+	}
+
+	if hasError || !isUploaded {
+		// There is currently no auto-retry mechanism
+		// Once a release is in an error state, it will have to be manually uploaded through the web interface
 		a.updatePendingReleaseState(r, release.StateUploadError)
-		return fmt.Errorf("failed to add destination torrent to fileserver %s (%s): %s", fs.Fileserver.Name, fs.Fileserver.UID, err.Error())
+
+		if len(failed) > 0 {
+			return fmt.Errorf("failed to upload release to %s", strings.Join(failed, ", "))
+		}
+
+		if !isUploaded {
+			return errors.New("release was not accepted by any destination")
+		}
+
+		return nil
 	}
 
 	// update release state
@@ -506,3 +834,62 @@ func (a *ATUS) UploadRelease(ctx context.Context, r *Release) error {
 	return nil
 
 }
+
+// uploadReleaseToDestination uploads the release to the destination and seeds the destinations torrent on the fileserver
+// The upload is recorded as UPLOADING before it is sent. If the trackers response gets lost, the next attempt
+// reuses the idempotency key and the dupe check reconciles the upload.
+// If the torrent can't be seeded, the result is returned with the error so the torrent id is recorded
+// and the next attempt is reconciled with the uploaded torrent
+func (a *ATUS) uploadReleaseToDestination(ctx context.Context, r *Release, fs *Fileserver, d *Destination, opts *UploadOptions) (*UploadResult, error) {
+
+	u := opts.Upload
+
+	res, err := a.UploadReleaseToTracker(ctx, r, d, opts)
+	if err != nil {
+		var dupeErr *DupeError
+		if errors.As(err, &dupeErr) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to upload release to tracker: %w", err)
+	}
+
+	// trackers that modify the torrent on upload return their version, the hash of the seeded torrent is stored
+	u.OriginalHash = r.Hash
+	u.Hash = res.Metainfo.InfoHash()
+
+	// nothing was uploaded, there is nothing to seed
+	if d.DryRun {
+		return res, nil
+	}
+
+	if !res.Reconciled {
+		d.SumUploads++
+		if err := d.Save(); err != nil {
+			logger.Ref(logger.RefDestination, d.UID).Type(logger.TypeUpload).Errorf("failed to save destination %s: %s", d.Name, err.Error())
+		}
+	}
+
+	// the release was uploaded successfully
+	// we now have to prepare the .torrent file with the trackers announce url.
+	// torrents downloaded from the tracker already contain the users announce url
+	if d.UserAnnounceURL != "" {
+		res.Metainfo.SetAnnounce(d.UserAnnounceURL)
+	}
+	newTorrent := res.Metainfo.Bytes()
+
+	// send new torrent to fileserver
+	if _, err := fs.Fileserver.AddTorrent(ctx, newTorrent, r.Name+".torrent", config.GetString("FILESERVER__UPLOAD_LABEL")); err != nil {
+		err = fmt.Errorf("failed to add destination torrent to fileserver %s (%s): %s", fs.Fileserver.Name, fs.Fileserver.UID, err.Error())
+
+		// reconciled torrents are usually seeded already
+		if !res.Reconciled {
+			u.State = release.UploadStateUploadError
+			return res, err
+		}
+
+		logger.Ref(logger.RefRelease, r.UID).Type(logger.TypeUpload).Warningf(err.Error())
+	}
+
+	return res, nil
+
+}
@@ -5,131 +5,401 @@ import (
 	"atus/backend/logger"
 	"atus/backend/release"
 	"atus/backend/video"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path"
 	"time"
 )
 
-// onNewSample is called when a new sample is found and converts it to a browser compatible format
-func (a *ATUS) onNewSample(r *Release) error {
+// onNewSample is called when a new sample or release video is ready. Samples are converted to a browser compatible format,
+// release videos are only probed on the fileserver for screenshots and technical info.
+// onProgress receives the progress of the conversion in percent.
+// Samples that can't be converted are set to MetafileStateError and the error is returned.
+func (a *ATUS) onNewSample(ctx context.Context, r *Release, onProgress func(percent float64)) error {
 
-	logWithRef := logger.Ref(logger.RefRelease, r.UID)
-
-	for _, m := range r.MetaFiles {
+	for _, m := range r.GetMetaFiles() {
 
-		// make sure the file is a sample and finished downloading
-		if m.Type != release.MetafileTypeSampleVideo || m.State != release.MetafileStateDownloaded {
+		// make sure the file finished downloading
+		if m.State != release.MetafileStateDownloaded {
 			continue
 		}
 
-		logWithRef.Debugf("converting sample %s", m.FileName)
-
-		m.Info["originalFileName"] = m.FileName
+		switch m.Type {
+		case release.MetafileTypeSampleVideo:
+			if err := a.convertSample(ctx, r, m, onProgress); err != nil {
+				return err
+			}
 
-		v, err := video.New(path.Join(config.Base.Folders.Data, m.ReleaseUID, m.FileName))
-		if err != nil {
-			logWithRef.Errorf("error probing sample video: %v", err)
-			m.State = release.MetafileStateError
-			return m.Save()
+		case release.MetafileTypeReleaseVideo:
+			if err := a.probeReleaseVideo(ctx, r, m, onProgress); err != nil {
+				return err
+			}
 		}
+	}
 
-		duration, err := time.ParseDuration(fmt.Sprintf("%ss", v.ProbeData.Format.Duration))
-		if err != nil {
-			logWithRef.Errorf("error parsing sample duration: %v", err)
-			m.State = release.MetafileStateError
-			return m.Save()
+	return nil
+}
+
+// failMetaFile returns a function that marks the meta file as broken and returns the error.
+// It can be retried from the sample queue
+func failMetaFile(r *Release, m *release.MetaFile) func(format string, err error) error {
+	return func(format string, err error) error {
+		err = fmt.Errorf(format, err)
+		logger.Ref(logger.RefRelease, r.UID).Errorf(err.Error())
+		m.State = release.MetafileStateError
+		if saveErr := m.Save(); saveErr != nil {
+			return saveErr
 		}
+		return err
+	}
+}
 
-		m.Info["duration"] = duration.String()
+func (a *ATUS) convertSample(ctx context.Context, r *Release, m *release.MetaFile, onProgress func(percent float64)) error {
 
-		ppv, err := v.Prepare()
-		if err != nil {
-			logWithRef.Errorf("error preparing sample video: %v", err)
-			m.State = release.MetafileStateError
-			return m.Save()
+	logWithRef := logger.Ref(logger.RefRelease, r.UID)
+
+	logWithRef.Debugf("converting sample %s", m.FileName)
+
+	m.Info["originalFileName"] = m.FileName
+
+	fail := failMetaFile(r, m)
+
+	v, err := video.New(ctx, path.Join(config.Base.Folders.Data, m.ReleaseUID, m.FileName))
+	if err != nil {
+		return fail("error probing sample video: %v", err)
+	}
+
+	duration, err := v.Duration()
+	if err != nil {
+		return fail("error parsing sample duration: %v", err)
+	}
+
+	profile, err := video.ParseProfile(config.GetString("SAMPLES__PROFILE"))
+	if err != nil {
+		return fail("error loading transcoding profile: %v", err)
+	}
+
+	// the clip is shorter than the sample if the profile caps its length,
+	// screenshots are still spread over the whole sample
+	m.Info["duration"] = profile.Duration(duration).String()
+
+	// extracting the streams takes most of the time, screenshots and packaging the remaining 5%
+	ppv, err := v.Prepare(ctx, profile, func(percent float64) {
+		onProgress(percent * 0.95)
+	})
+	if err != nil {
+		return fail("error preparing sample video: %v", err)
+	}
+
+	defer ppv.RemoveTempDir()
+
+	// get file info
+	for _, pv := range ppv.PreparedStreams {
+		if pv.Stream.CodecType != "video" {
+			continue
 		}
 
-		defer ppv.RemoveTempDir()
+		m.Info["width"] = fmt.Sprintf("%d", pv.Stream.Width)
+		m.Info["height"] = fmt.Sprintf("%d", pv.Stream.Height)
+		m.Info["codec_name"] = pv.Stream.CodecName
+		m.Info["codec_long_name"] = pv.Stream.CodecLongName
+		break
+	}
 
-		// get file info
-		for _, pv := range ppv.PreparedStreams {
-			if pv.Stream.CodecType != "video" {
-				continue
-			}
+	logWithRef.Debugf("Sample info: %s, %v", m.FileName, m.Info)
+
+	if err := a.createScreenshots(ctx, r, m, v, duration, "sample", release.MetafileTypeScreenImageFromSample); err != nil {
+		return fail("error extracting screenshot: %v", err)
+	}
+
+	// the contact sheet covers the whole sample, the clip length of the profile doesn't apply.
+	// Like screenshots without usable frame, a missing contact sheet or report doesn't fail the sample
+	if err := a.createContactSheet(ctx, r, m, v, "sample"); err != nil {
+		logWithRef.Warningf("error creating contact sheet of %s: %v", m.FileName, err)
+	}
+
+	// technical report of the original sample
+	if err := a.createMediaInfo(r, m, v, "sample"); err != nil {
+		logWithRef.Warningf("error saving media info of %s: %v", m.FileName, err)
+	}
+
+	// -- finished processing, save
+	format, err := video.ParseOutputFormat(config.GetString("SAMPLES__OUTPUT_FORMAT"))
+	if err != nil {
+		return fail("error saving sample video: %v", err)
+	}
+
+	packager, err := video.ParsePackager(config.GetString("SAMPLES__PACKAGER"))
+	if err != nil {
+		return fail("error saving sample video: %v", err)
+	}
+
+	manifests, err := ppv.Save(ctx, path.Join(config.Base.Folders.Data, m.ReleaseUID), fmt.Sprintf("sample-manifest-%d", m.Index), format, packager)
+	if err != nil {
+		return fail("error saving sample video: %v", err)
+	}
+
+	// the file name is the DASH manifest if there is one, players pick the manifest they support from the info
+	m.Info["rawFile"] = m.FileName
+	m.Info["dashManifest"] = manifests.DASH
+	m.Info["hlsManifest"] = manifests.HLS
+	m.FileName = manifests.Main()
+	m.State = release.MetafileStateProcessed
+	if err := m.Save(); err != nil {
+		return err
+	}
+
+	a.OnMetaFilesUpdated(r)
+
+	onProgress(100)
+
+	logWithRef.Infof("sample %s converted successfully", m.FileName)
+
+	return nil
+
+}
+
+// probeReleaseVideo reads the main video of a release without sample from the fileserver.
+// ffprobe and ffmpeg only request the parts of the file they need, the video is never downloaded
+func (a *ATUS) probeReleaseVideo(ctx context.Context, r *Release, m *release.MetaFile, onProgress func(percent float64)) error {
 
-			m.Info["width"] = fmt.Sprintf("%d", pv.Stream.Width)
-			m.Info["height"] = fmt.Sprintf("%d", pv.Stream.Height)
-			m.Info["codec_name"] = pv.Stream.CodecName
-			m.Info["codec_long_name"] = pv.Stream.CodecLongName
-			break
+	logWithRef := logger.Ref(logger.RefRelease, r.UID)
+
+	logWithRef.Debugf("probing release video %s", m.Info["releasePath"])
+
+	fail := failMetaFile(r, m)
+
+	f := a.GetFileserverByUID(r.FileserverUID)
+	if f == nil {
+		return fail("error probing release video: %v", fmt.Errorf("fileserver %s not found", r.FileserverUID))
+	}
+
+	v, err := video.New(ctx, f.Fileserver.FileURL(r.Hash, m.Index))
+	if err != nil {
+		return fail("error probing release video: %v", err)
+	}
+
+	duration, err := v.Duration()
+	if err != nil {
+		return fail("error parsing release video duration: %v", err)
+	}
+
+	m.Info["duration"] = duration.String()
+	for _, s := range v.ProbeData.Streams {
+		if s.CodecType != "video" {
+			continue
 		}
 
-		logWithRef.Debugf("Sample info: %s, %v", m.FileName, m.Info)
+		m.Info["width"] = fmt.Sprintf("%d", s.Width)
+		m.Info["height"] = fmt.Sprintf("%d", s.Height)
+		m.Info["codec_name"] = s.CodecName
+		m.Info["codec_long_name"] = s.CodecLongName
+		break
+	}
 
-		// create screenshots
-		// calc the timestamp of each screenshot dynamically
-		// +2 so that the first and the last screenshot are not at the very beginning and the very end of the video
-		sumScreenshots := config.GetInt64("SAMPLES__SUM_SCREENSHOTS")
-		screenInterval := int64(duration/time.Second) / (sumScreenshots + 2)
-		for i := int64(1); i <= sumScreenshots; i++ {
+	onProgress(10)
 
-			ts := screenInterval * i
-			newFileName := fmt.Sprintf("sample-screenshot-%ds-%d.jpg", ts, m.Index)
-			savePath := path.Join(config.Base.Folders.Data, m.ReleaseUID, newFileName)
-			err := v.ExtractFrame(fmt.Sprintf("%d", ts), savePath)
-			if err != nil {
-				return err
-			}
+	if err := a.createScreenshots(ctx, r, m, v, duration, "release", release.MetafileTypeScreenImageFromVideo); err != nil {
+		return fail("error extracting screenshot: %v", err)
+	}
 
-			// BugFix: ffmpeg sometimes creates a 0 byte file
-			// if this happens, skip this screenshot
-			fileInfo, err := os.Stat(savePath)
-			if err != nil {
-				logWithRef.Errorf("error getting file info for %s: %v", newFileName, err)
-				continue
-			}
+	onProgress(60)
 
-			if fileInfo.Size() == 0 {
-				logWithRef.Errorf("file %s is 0 bytes, skipping", newFileName)
-				continue
-			}
+	// a missing contact sheet or report doesn't fail the release video
+	if err := a.createContactSheet(ctx, r, m, v, "release"); err != nil {
+		logWithRef.Warningf("error creating contact sheet of %s: %v", m.Info["releasePath"], err)
+	}
 
-			smv := release.NewMetaFile(m.ReleaseUID, newFileName, -1, release.MetafileTypeScreenImageFromSample, release.MetafileStateProcessed, nil, release.MetaInfo{
-				"width":  m.Info["width"],
-				"height": m.Info["height"],
-			})
+	if err := a.createMediaInfo(r, m, v, "release"); err != nil {
+		logWithRef.Warningf("error saving media info of %s: %v", m.Info["releasePath"], err)
+	}
 
-			r.MetaFiles = append(r.MetaFiles, smv)
+	m.State = release.MetafileStateProcessed
+	if err := m.Save(); err != nil {
+		return err
+	}
 
-			logWithRef.Debugf("created new sample screenshot: %s", newFileName)
+	a.OnMetaFilesUpdated(r)
 
-			if err := smv.Save(); err != nil {
-				return err
-			}
+	onProgress(100)
+
+	logWithRef.Infof("release video %s probed successfully", m.Info["releasePath"])
+
+	return nil
+
+}
+
+// createScreenshots extracts SAMPLES__SUM_SCREENSHOTS screenshots of the video.
+// File names start with prefix, screenshots of a previous run are kept
+func (a *ATUS) createScreenshots(ctx context.Context, r *Release, m *release.MetaFile, v *video.ProbedVideo, duration time.Duration, prefix string, screenType release.MetaFileType) error {
 
-			a.OnMetaFilesUpdated(r)
+	logWithRef := logger.Ref(logger.RefRelease, r.UID)
+
+	// calc the timestamp of each screenshot dynamically
+	// +2 so that the first and the last screenshot are not at the very beginning and the very end of the video
+	sumScreenshots := config.GetInt64("SAMPLES__SUM_SCREENSHOTS")
+	sumCandidates := config.GetInt64("SAMPLES__SCREENSHOT_CANDIDATES")
+	if sumCandidates < 1 {
+		sumCandidates = 1
+	}
+
+	screenInterval := int64(duration/time.Second) / (sumScreenshots + 2)
+	screenHashes := r.getScreenshotHashes()
+	for i := int64(1); i <= sumScreenshots; i++ {
+
+		ts := screenInterval * i
+		newFileName := fmt.Sprintf("%s-screenshot-%ds-%d.jpg", prefix, ts, m.Index)
+
+		// retried samples keep the screenshots of the previous run
+		if r.hasMetaFile(newFileName) {
+			continue
 		}
 
-		// -- finished processing, save
-		mpdName := fmt.Sprintf("sample-manifest-%d.mpd", m.Index)
-		if err := ppv.Save(path.Join(config.Base.Folders.Data, m.ReleaseUID), mpdName); err != nil {
-			logWithRef.Errorf("error saving sample video: %v", err)
-			m.State = release.MetafileStateError
-			return m.Save()
+		// candidates are spread evenly around the timestamp, the sharpest frame that
+		// is not black, white, blurry or a duplicate of another screenshot is kept.
+		// If all frames are black, white or blurry, the best of them is kept
+		slot := time.Duration(screenInterval) * time.Second
+		var candidates []time.Duration
+		for c := int64(0); c < sumCandidates; c++ {
+			offset := slot * time.Duration(2*c-(sumCandidates-1)) / time.Duration(2*sumCandidates)
+			candidates = append(candidates, time.Duration(ts)*time.Second+offset)
 		}
 
-		m.Info["rawFile"] = m.FileName
-		m.FileName = mpdName
-		m.State = release.MetafileStateProcessed
-		if err := m.Save(); err != nil {
+		savePath := path.Join(config.Base.Folders.Data, m.ReleaseUID, newFileName)
+		score, frameTS, err := v.BestFrame(ctx, candidates, savePath, screenHashes)
+		if errors.Is(err, video.ErrNoUsableFrame) {
+			logWithRef.Warningf("no frame for %s in %d candidates, skipping: %v", newFileName, len(candidates), err)
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if !score.Usable() {
+			logWithRef.Warningf("no usable frame for %s in %d candidates, keeping the best one at %s", newFileName, len(candidates), frameTS)
+		}
+
+		screenHashes = append(screenHashes, score.Hash)
+
+		smv := release.NewMetaFile(m.ReleaseUID, newFileName, -1, screenType, release.MetafileStateProcessed, nil, release.MetaInfo{
+			"width":     m.Info["width"],
+			"height":    m.Info["height"],
+			"timestamp": frameTS.String(),
+			"luma":      fmt.Sprintf("%.1f", score.Luma),
+			"sharpness": fmt.Sprintf("%.1f", score.Sharpness),
+			"phash":     score.FormatHash(),
+		})
+
+		r.addMetaFile(smv)
+
+		logWithRef.Debugf("created new %s screenshot: %s, %v", prefix, newFileName, smv.Info)
+
+		if err := smv.Save(); err != nil {
 			return err
 		}
 
 		a.OnMetaFilesUpdated(r)
+	}
+
+	return nil
 
-		logWithRef.Infof("sample %s converted successfully", m.FileName)
+}
+
+// createContactSheet creates a contact sheet of the video unless it is disabled or already exists
+func (a *ATUS) createContactSheet(ctx context.Context, r *Release, m *release.MetaFile, v *video.ProbedVideo, prefix string) error {
+
+	columns := config.GetInt64("SAMPLES__CONTACT_SHEET_COLUMNS")
+	rows := config.GetInt64("SAMPLES__CONTACT_SHEET_ROWS")
+	contactSheetFileName := fmt.Sprintf("%s-contactsheet-%d.jpg", prefix, m.Index)
+	if columns <= 0 || rows <= 0 || r.hasMetaFile(contactSheetFileName) {
+		return nil
 	}
 
+	savePath := path.Join(config.Base.Folders.Data, m.ReleaseUID, contactSheetFileName)
+	if err := v.ContactSheet(ctx, savePath, getVideoName(m), int(columns), int(rows)); err != nil {
+		return err
+	}
+
+	csm := release.NewMetaFile(m.ReleaseUID, contactSheetFileName, -1, release.MetafileTypeContactSheet, release.MetafileStateProcessed, nil, release.MetaInfo{
+		"columns": fmt.Sprintf("%d", columns),
+		"rows":    fmt.Sprintf("%d", rows),
+	})
+
+	r.addMetaFile(csm)
+
+	logger.Ref(logger.RefRelease, r.UID).Debugf("created contact sheet: %s", contactSheetFileName)
+
+	if err := csm.Save(); err != nil {
+		return err
+	}
+
+	a.OnMetaFilesUpdated(r)
+
+	return nil
+
+}
+
+// createMediaInfo saves the MediaInfo report of the video unless it already exists
+func (a *ATUS) createMediaInfo(r *Release, m *release.MetaFile, v *video.ProbedVideo, prefix string) error {
+
+	mediaInfoFileName := fmt.Sprintf("%s-mediainfo-%d.txt", prefix, m.Index)
+	if r.hasMetaFile(mediaInfoFileName) {
+		return nil
+	}
+
+	savePath := path.Join(config.Base.Folders.Data, m.ReleaseUID, mediaInfoFileName)
+	if err := os.WriteFile(savePath, []byte(v.MediaInfo(getVideoName(m))), 0644); err != nil {
+		return err
+	}
+
+	mim := release.NewMetaFile(m.ReleaseUID, mediaInfoFileName, -1, release.MetafileTypeMediaInfo, release.MetafileStateProcessed, nil, release.MetaInfo{
+		"source": getVideoName(m),
+	})
+
+	r.addMetaFile(mim)
+
+	if err := mim.Save(); err != nil {
+		return err
+	}
+
+	a.OnMetaFilesUpdated(r)
+
 	return nil
+
+}
+
+// getVideoName returns the name of a sample or release video as it is shown in contact sheets and reports
+func getVideoName(m *release.MetaFile) string {
+	if p := m.Info["releasePath"]; p != "" {
+		return path.Base(p)
+	}
+	return m.Info["originalFileName"]
+}
+
+// getScreenshotHashes returns the perceptual hashes of the screenshots taken from samples and release videos
+func (r *Release) getScreenshotHashes() []uint64 {
+	var hashes []uint64
+	for _, m := range r.GetMetaFiles() {
+		if m.Type != release.MetafileTypeScreenImageFromSample && m.Type != release.MetafileTypeScreenImageFromVideo {
+			continue
+		}
+
+		if h, err := video.ParseHash(m.Info["phash"]); err == nil {
+			hashes = append(hashes, h)
+		}
+	}
+	return hashes
+}
+
+// hasMetaFile returns true if the release has a meta file with the given file name
+func (r *Release) hasMetaFile(fileName string) bool {
+	for _, m := range r.GetMetaFiles() {
+		if m.FileName == fileName {
+			return true
+		}
+	}
+	return false
 }
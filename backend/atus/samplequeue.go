@@ -0,0 +1,249 @@
+package atus
+
+import (
+	"atus/backend/config"
+	"atus/backend/logger"
+	"atus/backend/release"
+	"atus/backend/video"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+type SampleJobState string
+
+const (
+	SampleJobStatePending SampleJobState = "PENDING"
+	SampleJobStateRunning SampleJobState = "RUNNING"
+	SampleJobStateFailed  SampleJobState = "FAILED" // failed, timed out or cancelled. Can be retried
+	SampleJobStateDone    SampleJobState = "DONE"   // only passed to OnSampleJobUpdated, done jobs are removed from the queue
+)
+
+// SampleJob converts the samples of a release. Finished jobs are removed from the queue
+type SampleJob struct {
+	ReleaseUID  string         `json:"releaseUID"`
+	ReleaseName string         `json:"releaseName"`
+	State       SampleJobState `json:"state"`
+	Progress    int            `json:"progress"` // percent
+	Error       string         `json:"error"`
+	Added       time.Time      `json:"added"`
+	Started     time.Time      `json:"started"`
+
+	release *Release
+	cancel  context.CancelFunc
+}
+
+// queueSample adds a job for the release to the sample queue.
+// Releases that are already pending or running are ignored.
+func (a *ATUS) queueSample(r *Release) {
+
+	a.sampleJobsMutex.Lock()
+	if j, ok := a.sampleJobs[r.UID]; ok && j.State != SampleJobStateFailed {
+		a.sampleJobsMutex.Unlock()
+		return
+	}
+
+	j := &SampleJob{
+		ReleaseUID:  r.UID,
+		ReleaseName: r.Name,
+		State:       SampleJobStatePending,
+		Added:       time.Now(),
+		release:     r,
+	}
+	a.sampleJobs[r.UID] = j
+	a.sampleJobsMutex.Unlock()
+
+	a.onSampleJobUpdated(j)
+
+	a.sampleQueue <- r
+
+}
+
+// runSampleWorker processes jobs from the sample queue until the queue is closed
+func (a *ATUS) runSampleWorker() {
+	for r := range a.sampleQueue {
+		a.processSampleJob(r)
+	}
+}
+
+func (a *ATUS) processSampleJob(r *Release) {
+
+	logWithRef := logger.Ref(logger.RefRelease, r.UID).Type(logger.TypeSample)
+
+	// the job was cancelled while it was pending or the release was queued more than once
+	a.sampleJobsMutex.Lock()
+	j, ok := a.sampleJobs[r.UID]
+	if !ok || j.State != SampleJobStatePending {
+		a.sampleJobsMutex.Unlock()
+		return
+	}
+
+	// the sample stays downloaded, the job can be retried once the dependencies are installed
+	if err := video.DependencyError(); err != nil {
+		j.State = SampleJobStateFailed
+		j.Error = fmt.Sprintf("sample processing is disabled: %s", err)
+		a.sampleJobsMutex.Unlock()
+		a.onSampleJobUpdated(j)
+		return
+	}
+
+	timeout := time.Duration(config.GetInt64("SAMPLES__TIMEOUT")) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	j.State = SampleJobStateRunning
+	j.Started = time.Now()
+	j.cancel = cancel
+	a.sampleJobsMutex.Unlock()
+
+	a.onSampleJobUpdated(j)
+
+	err := a.convertSamples(ctx, r, func(percent float64) {
+		a.setSampleJobProgress(j, int(percent))
+	})
+
+	if err != nil {
+		switch {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			err = fmt.Errorf("timed out after %s", timeout)
+		case errors.Is(ctx.Err(), context.Canceled):
+			err = errors.New("cancelled")
+		}
+	}
+
+	a.sampleJobsMutex.Lock()
+	j.cancel = nil
+	if err != nil {
+		j.State = SampleJobStateFailed
+		j.Error = err.Error()
+	} else {
+		j.State = SampleJobStateDone
+		j.Progress = 100
+		delete(a.sampleJobs, r.UID)
+	}
+	a.sampleJobsMutex.Unlock()
+
+	if err != nil {
+		logWithRef.Errorf("error converting sample: %s", err)
+	}
+
+	a.onSampleJobUpdated(j)
+
+}
+
+// setSampleJobProgress updates the progress of a running job. Clients are only notified if the percentage changed
+func (a *ATUS) setSampleJobProgress(j *SampleJob, percent int) {
+
+	a.sampleJobsMutex.Lock()
+	if j.Progress == percent {
+		a.sampleJobsMutex.Unlock()
+		return
+	}
+	j.Progress = percent
+	a.sampleJobsMutex.Unlock()
+
+	a.onSampleJobUpdated(j)
+
+}
+
+// onSampleJobUpdated passes a copy of the job to OnSampleJobUpdated
+func (a *ATUS) onSampleJobUpdated(j *SampleJob) {
+
+	a.sampleJobsMutex.Lock()
+	c := *j
+	a.sampleJobsMutex.Unlock()
+
+	a.OnSampleJobUpdated(&c)
+
+}
+
+// GetSampleJobs returns copies of all pending, running and failed jobs, oldest first
+func (a *ATUS) GetSampleJobs() []*SampleJob {
+
+	a.sampleJobsMutex.Lock()
+	defer a.sampleJobsMutex.Unlock()
+
+	jobs := make([]*SampleJob, 0, len(a.sampleJobs))
+	for _, j := range a.sampleJobs {
+		c := *j
+		jobs = append(jobs, &c)
+	}
+
+	sort.Slice(jobs, func(i, k int) bool {
+		return jobs[i].Added.Before(jobs[k].Added)
+	})
+
+	return jobs
+
+}
+
+// RetrySampleJob queues a failed job again. Samples and release videos that failed are reset to MetafileStateDownloaded
+func (a *ATUS) RetrySampleJob(releaseUID string) error {
+
+	a.sampleJobsMutex.Lock()
+	j, ok := a.sampleJobs[releaseUID]
+	if !ok {
+		a.sampleJobsMutex.Unlock()
+		return fmt.Errorf("sample job for release %s not found", releaseUID)
+	}
+
+	if j.State != SampleJobStateFailed {
+		a.sampleJobsMutex.Unlock()
+		return fmt.Errorf("sample job for release %s is %s", releaseUID, j.State)
+	}
+	a.sampleJobsMutex.Unlock()
+
+	r := j.release
+	for _, m := range r.GetMetaFiles() {
+		if (m.Type != release.MetafileTypeSampleVideo && m.Type != release.MetafileTypeReleaseVideo) || m.State != release.MetafileStateError {
+			continue
+		}
+
+		m.State = release.MetafileStateDownloaded
+		if err := m.Save(); err != nil {
+			return err
+		}
+	}
+
+	a.OnMetaFilesUpdated(r)
+
+	logger.Ref(logger.RefRelease, r.UID).Type(logger.TypeSample).Infof("retrying sample conversion")
+
+	a.queueSample(r)
+
+	return nil
+
+}
+
+// CancelSampleJob cancels a pending or running job. The job stays in the queue as failed
+func (a *ATUS) CancelSampleJob(releaseUID string) error {
+
+	a.sampleJobsMutex.Lock()
+	j, ok := a.sampleJobs[releaseUID]
+	if !ok {
+		a.sampleJobsMutex.Unlock()
+		return fmt.Errorf("sample job for release %s not found", releaseUID)
+	}
+
+	switch j.State {
+	case SampleJobStatePending:
+		j.State = SampleJobStateFailed
+		j.Error = "cancelled"
+	case SampleJobStateRunning:
+		// the worker marks the job as failed once ffmpeg has exited
+		j.cancel()
+		a.sampleJobsMutex.Unlock()
+		return nil
+	default:
+		a.sampleJobsMutex.Unlock()
+		return fmt.Errorf("sample job for release %s is %s", releaseUID, j.State)
+	}
+	a.sampleJobsMutex.Unlock()
+
+	a.onSampleJobUpdated(j)
+
+	return nil
+
+}
@@ -0,0 +1,264 @@
+package atus
+
+import (
+	"atus/backend/release"
+	"atus/backend/video"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// sampleTestQueue records the job updates and counts the conversions of a stub converter
+type sampleTestQueue struct {
+	*ATUS
+
+	m       sync.Mutex
+	updates []SampleJob
+	calls   int
+}
+
+// newSampleTestQueue returns a queue whose converter calls convert
+func newSampleTestQueue(t *testing.T, convert func(ctx context.Context, r *Release, onProgress func(percent float64)) error) *sampleTestQueue {
+
+	video.SetDependencyError(nil)
+	t.Cleanup(func() { video.SetDependencyError(errors.New("dependencies have not been checked")) })
+
+	q := &sampleTestQueue{}
+	q.ATUS = &ATUS{
+		sampleQueue:        make(chan *Release, 10),
+		sampleJobs:         make(map[string]*SampleJob),
+		OnMetaFilesUpdated: func(*Release) {},
+		OnSampleJobUpdated: func(j *SampleJob) {
+			q.m.Lock()
+			q.updates = append(q.updates, *j)
+			q.m.Unlock()
+		},
+	}
+
+	q.convertSamples = func(ctx context.Context, r *Release, onProgress func(percent float64)) error {
+		q.m.Lock()
+		q.calls++
+		q.m.Unlock()
+
+		return convert(ctx, r, onProgress)
+	}
+
+	return q
+
+}
+
+// states returns the states of the job updates
+func (q *sampleTestQueue) states() []SampleJobState {
+	q.m.Lock()
+	defer q.m.Unlock()
+
+	var states []SampleJobState
+	for _, u := range q.updates {
+		if len(states) == 0 || states[len(states)-1] != u.State {
+			states = append(states, u.State)
+		}
+	}
+	return states
+}
+
+// processNext processes the next release of the queue, false if the queue is empty
+func (q *sampleTestQueue) processNext() bool {
+	select {
+	case r := <-q.sampleQueue:
+		q.processSampleJob(r)
+		return true
+	default:
+		return false
+	}
+}
+
+func (q *sampleTestQueue) job(uid string) *SampleJob {
+	for _, j := range q.GetSampleJobs() {
+		if j.ReleaseUID == uid {
+			return j
+		}
+	}
+	return nil
+}
+
+func expectStates(t *testing.T, got []SampleJobState, expected ...SampleJobState) {
+	t.Helper()
+
+	if len(got) != len(expected) {
+		t.Fatalf("expected states %v, got %v", expected, got)
+	}
+
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("expected states %v, got %v", expected, got)
+		}
+	}
+}
+
+func TestSampleQueueEnqueue(t *testing.T) {
+
+	q := newSampleTestQueue(t, func(ctx context.Context, r *Release, onProgress func(percent float64)) error {
+		onProgress(50.4)
+		onProgress(50.6) // same percentage, no update
+		return nil
+	})
+
+	r := &Release{UID: "r", Name: "Release"}
+
+	q.queueSample(r)
+	q.queueSample(r) // already pending
+
+	if j := q.job("r"); j == nil || j.State != SampleJobStatePending {
+		t.Fatalf("expected a pending job, got %+v", j)
+	}
+
+	for q.processNext() {
+	}
+
+	if q.calls != 1 {
+		t.Errorf("expected 1 conversion, got %d", q.calls)
+	}
+
+	if j := q.job("r"); j != nil {
+		t.Errorf("expected the done job to be removed, got %+v", j)
+	}
+
+	expectStates(t, q.states(), SampleJobStatePending, SampleJobStateRunning, SampleJobStateDone)
+
+	if len(q.updates) != 4 || q.updates[2].Progress != 50 || q.updates[3].Progress != 100 {
+		t.Errorf("expected progress updates 50 and 100, got %+v", q.updates)
+	}
+
+}
+
+func TestSampleQueueMissingDependencies(t *testing.T) {
+
+	q := newSampleTestQueue(t, func(ctx context.Context, r *Release, onProgress func(percent float64)) error {
+		return nil
+	})
+
+	video.SetDependencyError(errors.New("ffmpeg is not installed"))
+
+	q.queueSample(&Release{UID: "r"})
+	q.processNext()
+
+	j := q.job("r")
+	if j == nil || j.State != SampleJobStateFailed || j.Error != "sample processing is disabled: ffmpeg is not installed" {
+		t.Fatalf("expected a failed job, got %+v", j)
+	}
+
+	if q.calls != 0 {
+		t.Errorf("expected no conversion, got %d", q.calls)
+	}
+
+}
+
+func TestSampleQueueCancel(t *testing.T) {
+
+	started := make(chan struct{})
+	q := newSampleTestQueue(t, func(ctx context.Context, r *Release, onProgress func(percent float64)) error {
+		close(started)
+		<-ctx.Done()
+		return errors.New("ffmpeg was killed")
+	})
+
+	if err := q.CancelSampleJob("unknown"); err == nil {
+		t.Error("expected an error for an unknown job")
+	}
+
+	// a pending job is skipped by the worker
+	q.queueSample(&Release{UID: "pending"})
+
+	if err := q.CancelSampleJob("pending"); err != nil {
+		t.Fatal(err)
+	}
+
+	q.processNext()
+
+	if j := q.job("pending"); j == nil || j.State != SampleJobStateFailed || j.Error != "cancelled" {
+		t.Fatalf("expected a cancelled job, got %+v", j)
+	}
+
+	if err := q.CancelSampleJob("pending"); err == nil {
+		t.Error("expected an error for a failed job")
+	}
+
+	if q.calls != 0 {
+		t.Fatalf("expected no conversion, got %d", q.calls)
+	}
+
+	// the worker marks a running job as failed once the converter returned
+	q.queueSample(&Release{UID: "running"})
+
+	done := make(chan struct{})
+	go func() {
+		q.processNext()
+		close(done)
+	}()
+
+	<-started
+
+	if err := q.CancelSampleJob("running"); err != nil {
+		t.Fatal(err)
+	}
+
+	<-done
+
+	if j := q.job("running"); j == nil || j.State != SampleJobStateFailed || j.Error != "cancelled" {
+		t.Fatalf("expected a cancelled job, got %+v", j)
+	}
+
+}
+
+func TestSampleQueueRetry(t *testing.T) {
+
+	fail := true
+	q := newSampleTestQueue(t, func(ctx context.Context, r *Release, onProgress func(percent float64)) error {
+		if fail {
+			r.MetaFiles[0].State = release.MetafileStateError
+			return errors.New("conversion failed")
+		}
+		return nil
+	})
+
+	sample := release.NewMetaFile("r", "0_r.mkv", 0, release.MetafileTypeSampleVideo, release.MetafileStateDownloaded, nil, nil)
+	r := &Release{UID: "r", MetaFiles: []*release.MetaFile{sample}}
+
+	q.queueSample(r)
+
+	if err := q.RetrySampleJob("r"); err == nil {
+		t.Error("expected an error for a pending job")
+	}
+
+	q.processNext()
+
+	if j := q.job("r"); j == nil || j.State != SampleJobStateFailed || j.Error != "conversion failed" {
+		t.Fatalf("expected a failed job, got %+v", j)
+	}
+
+	fail = false
+	if err := q.RetrySampleJob("r"); err != nil {
+		t.Fatal(err)
+	}
+
+	if sample.State != release.MetafileStateDownloaded {
+		t.Errorf("expected the sample to be reset to %s, got %s", release.MetafileStateDownloaded, sample.State)
+	}
+
+	q.processNext()
+
+	if j := q.job("r"); j != nil {
+		t.Errorf("expected the job to be done, got %+v", j)
+	}
+
+	if q.calls != 2 {
+		t.Errorf("expected 2 conversions, got %d", q.calls)
+	}
+
+	expectStates(t, q.states(),
+		SampleJobStatePending, SampleJobStateRunning, SampleJobStateFailed,
+		SampleJobStatePending, SampleJobStateRunning, SampleJobStateDone,
+	)
+
+}
@@ -137,7 +137,7 @@ func (s *Source) checkRSSFeedTaskInit(releaseChan chan<- *release.Release) sched
 			}
 
 			// -- create release instance -------------
-			release, err := release.New(ctx, s.Source, item.Title, metaURL, imageURL)
+			rls, err := release.New(ctx, s.Source, item.Title, metaURL, imageURL)
 			if err != nil {
 				if errors.Is(err, context.Canceled) {
 					logWithRef.Debug("RSS feed check canceled (02)")
@@ -147,8 +147,15 @@ func (s *Source) checkRSSFeedTaskInit(releaseChan chan<- *release.Release) sched
 				continue
 			}
 
+			// -- find external ids -------------------
+			var itemURLs []string
+			for _, u := range item.URLs {
+				itemURLs = append(itemURLs, u.URL.String())
+			}
+			rls.ExternalIDs, _ = rls.ExternalIDs.Merge(release.ExtractExternalIDs(itemURLs...))
+
 			// thats all we need for now, send the release to the release channel
-			releaseChan <- release
+			releaseChan <- rls
 
 		}
 	}
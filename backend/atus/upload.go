@@ -2,32 +2,42 @@ package atus
 
 import (
 	"atus/backend/bencode"
+	"atus/backend/category"
 	"atus/backend/config"
+	"atus/backend/destination"
+	"atus/backend/helpers"
+	"atus/backend/logger"
 	"atus/backend/release"
 	"atus/backend/request"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net"
+	"os"
+	"path"
+	"sort"
 	"strings"
 	"time"
 )
 
-// returns (newTorrentFile, error)
-func (a *ATUS) UploadReleaseToTracker(ctx context.Context, r *Release) (*bencode.Dict, error) {
+// getUploadFiles returns the torrent and nfo file of the release
+func (r *Release) getUploadFiles() ([]byte, []byte, error) {
 
-	// get torrent & nfo file
 	var torrent, nfo []byte
-	for _, mf := range r.MetaFiles {
+	for _, mf := range r.GetMetaFiles() {
 		if mf.Type != release.MetafileTypeTorrent && mf.Type != release.MetafileTypeNFO {
 			continue
 		}
 
 		file, err := mf.GetFile()
 		if err != nil {
-			return nil, fmt.Errorf("failed to get %s file for release %s: %s", strings.ToLower(string(mf.Type)), r.Name, err.Error())
+			return nil, nil, fmt.Errorf("failed to get %s file for release %s: %s", strings.ToLower(string(mf.Type)), r.Name, err.Error())
 		}
 
 		if mf.Type == release.MetafileTypeTorrent {
@@ -41,151 +51,518 @@ func (a *ATUS) UploadReleaseToTracker(ctx context.Context, r *Release) (*bencode
 		}
 	}
 
-	d := &Destination{
-		TrackerAnnounceURL: config.GetString("UPLOAD__TRACKER_ANNOUNCE_URL"),
-		Comment:            config.GetString("UPLOAD__COMMENT"),
-		CreatedBy:          config.GetString("UPLOAD__CREATED_BY"),
-		UserID:             config.GetString("UPLOAD__USER_ID"),
-		APIURL:             config.GetString("UPLOAD__API_URL"),
-		APIAuthToken:       config.GetString("API__AUTH_TOKEN"),
+	return torrent, nfo, nil
+
+}
+
+// getMediaInfo returns the MediaInfo report of the release, empty if there is none
+func (r *Release) getMediaInfo() (string, error) {
+
+	for _, mf := range r.GetMetaFiles() {
+		if mf.Type == release.MetafileTypeMediaInfo && mf.State == release.MetafileStateProcessed {
+			file, err := mf.GetFile()
+			return string(file), err
+		}
 	}
 
-	return d.UploadRelease(ctx, r, torrent, nfo)
+	return "", nil
 
 }
 
-type Destination struct {
-	TrackerAnnounceURL string
-	Comment            string
-	CreatedBy          string
-	UserID             string
-	APIURL             string
-	APIAuthToken       string
+// getContactSheet returns the contact sheet of the release, nil if there is none
+func (r *Release) getContactSheet() ([]byte, error) {
+
+	for _, mf := range r.GetMetaFiles() {
+		if mf.Type == release.MetafileTypeContactSheet && mf.State == release.MetafileStateProcessed {
+			return mf.GetFile()
+		}
+	}
+
+	return nil, nil
+
 }
 
-// uploadRelease uploads a release to the destination tracker
-func (d *Destination) UploadRelease(ctx context.Context, r *Release, torrent, nfo []byte) (*bencode.Dict, error) {
+// UploadReleaseToTracker uploads the release files to the destination tracker
+func (a *ATUS) UploadReleaseToTracker(ctx context.Context, r *Release, d *Destination, opts *UploadOptions) (*UploadResult, error) {
 
-	// build torrent file for tracker
-	dict, err := bencode.BDecode(torrent)
+	torrent, nfo, err := r.getUploadFiles()
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode torrent file for release %s: %s", r.Name, err.Error())
+		return nil, err
 	}
 
-	// set values
-	dict.Announce = d.TrackerAnnounceURL
-	dict.Comment = d.Comment
-	dict.Private = 1
-	dict.CreatedBy = d.CreatedBy
-	dict.CreatedAt = time.Now().Unix()
-	dict.Bot = "ATUS (github.com/SteffenLoges/atus)"
-
-	encodedTorrent, err := dict.BEncode()
-	if err != nil {
-		return nil, fmt.Errorf("failed to encode torrent file for release %s: %s", r.Name, err.Error())
+	if torrent, err = a.getDestinationTorrent(ctx, r, d, torrent); err != nil {
+		return nil, err
 	}
 
-	// hash
-	hash, err := dict.GenHash()
-	if err != nil {
-		return nil, err
+	return d.UploadRelease(ctx, r, torrent, nfo, opts)
+
+}
+
+// UploadPayload contains everything an Uploader needs to upload a release
+type UploadPayload struct {
+	Release *Release
+
+	// torrent file rewritten for the destination
+	Metainfo *bencode.Metainfo
+	Dict     *bencode.Dict
+	Torrent  []byte
+	Hash     string
+
+	// SHA-256 infohash of v2 and hybrid torrents. The v2 structure is kept by the rewrite
+	HashV2 string
+
+	NFO []byte
+
+	// contact sheet of the sample. nil if the release has none
+	ContactSheet []byte
+
+	// MediaInfo report of the sample. Empty if the release has none
+	MediaInfo string
+
+	// description rendered from the destinations template
+	Description string
+
+	// sent as Idempotency-Key header. Empty for previews and test uploads
+	IdempotencyKey string
+
+	// category as it is used by the tracker
+	Category string
+
+	Attributes *release.NameAttributes
+}
+
+// fingerprint identifies the content of an upload. The torrent file itself is not part of it,
+// its creation date changes with every attempt
+func (p *UploadPayload) fingerprint(form *UploadForm) string {
+
+	keys := make([]string, 0, len(form.Fields))
+	for k := range form.Fields {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	h.Write([]byte(p.Hash))
+	for _, k := range keys {
+		fmt.Fprintf(h, "\x00%s=%s", k, form.Fields[k])
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+
+}
+
+// UploadOptions are the options of Destination.UploadRelease
+type UploadOptions struct {
+	// Upload is the upload state of the release on the destination. It holds the idempotency key and the
+	// torrent id of earlier attempts and is updated, but not saved, by UploadRelease. nil for test uploads
+	Upload *release.Upload
+
+	// IgnoreDupeCheckErrors uploads the release even if the tracker couldn't be searched for existing torrents
+	IgnoreDupeCheckErrors bool
+}
+
+// UploadForm is the multipart form an Uploader posts to the tracker
+type UploadForm struct {
+	Fields map[string]string `json:"fields"`
+	Files  []*UploadFormFile `json:"files"`
+}
+
+type UploadFormFile struct {
+	Field    string `json:"field"`
+	FileName string `json:"fileName"`
+	Size     int    `json:"size"`
+	Data     []byte `json:"-"`
+}
+
+// AddFile adds a file to the form
+func (f *UploadForm) AddFile(field, fileName string, data []byte) {
+	f.Files = append(f.Files, &UploadFormFile{
+		Field:    field,
+		FileName: fileName,
+		Size:     len(data),
+		Data:     data,
+	})
+}
+
+// Encode encodes the form as multipart/form-data
+// returns (body, contentType, error)
+func (f *UploadForm) Encode() (*bytes.Buffer, string, error) {
 
-	// build request
 	buf := new(bytes.Buffer)
 	writer := multipart.NewWriter(buf)
 
-	// add files
-	files := map[string][]byte{
-		"torrent": encodedTorrent,
-		"nfo":     nfo,
-	}
-
-	for name, data := range files {
-		w, err := writer.CreateFormFile(name, fmt.Sprintf("%s.%[1]s", name))
+	for _, file := range f.Files {
+		w, err := writer.CreateFormFile(file.Field, file.FileName)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
-		if _, err := w.Write(data); err != nil {
-			return nil, err
+		if _, err := w.Write(file.Data); err != nil {
+			return nil, "", err
 		}
 	}
 
-	// -- post data ---------------------------------------------------------------------------------
-	postData := map[string]string{}
+	for k, v := range f.Fields {
+		if err := writer.WriteField(k, v); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return buf, writer.FormDataContentType(), nil
+
+}
+
+// UploadResult is the outcome of a successful upload
+type UploadResult struct {
+	// torrent file that has to be seeded. Trackers that modify the torrent on upload return their version of the file.
+	Metainfo *bencode.Metainfo
+
+	// id and url of the torrent on the tracker. Empty if the tracker doesn't return them
+	TorrentID  string
+	TorrentURL string
+
+	// the torrent already existed on the tracker, nothing was uploaded
+	Reconciled bool
+}
+
+// Uploader uploads releases using the protocol of a tracker
+type Uploader interface {
+	// Form builds the form that is posted to the tracker
+	Form(p *UploadPayload) (*UploadForm, error)
+
+	// Upload posts the form to the tracker
+	Upload(ctx context.Context, p *UploadPayload, form *UploadForm) (*UploadResult, error)
+}
+
+// ExistingTorrent is a torrent that is already on the destination tracker
+type ExistingTorrent struct {
+	Name       string
+	Hash       string
+	TorrentID  string
+	TorrentURL string
+
+	// torrent file provided by the tracker. nil if the tracker doesn't modify uploaded torrents
+	Metainfo *bencode.Metainfo
+}
+
+// DupeChecker is implemented by Uploaders that can search the tracker for existing torrents
+type DupeChecker interface {
+	// FindExisting returns torrents on the tracker with the name or infohash of the payload
+	FindExisting(ctx context.Context, p *UploadPayload) ([]*ExistingTorrent, error)
+}
+
+// DupeError is returned if the tracker already has a different torrent with the same name
+type DupeError struct {
+	Existing *ExistingTorrent
+}
+
+func (e *DupeError) Error() string {
+	ref := e.Existing.TorrentURL
+	if ref == "" {
+		ref = e.Existing.TorrentID
+	}
+	return fmt.Sprintf("a torrent named %s already exists on the tracker (%s)", e.Existing.Name, ref)
+}
+
+// GetUploader returns the Uploader for the destinations type
+func (d *Destination) GetUploader() (Uploader, error) {
+	switch d.Type {
+	case destination.TypeATUS, "":
+		return &atusUploader{d}, nil
+	case destination.TypeUNIT3D:
+		return &unit3dUploader{d}, nil
+	case destination.TypeGazelle:
+		return &gazelleUploader{d}, nil
+	}
+
+	return nil, fmt.Errorf("unknown destination type %s", d.Type)
+}
+
+// GetSourceTag returns the tag written into info.source of uploaded torrents
+func (d *Destination) GetSourceTag() string {
+	if d.SourceTag != "" {
+		return d.SourceTag
+	}
+	return d.Name
+}
+
+// PrepareUpload rewrites the torrent for the destination and builds the form that would be posted to the tracker
+func (d *Destination) PrepareUpload(r *Release, torrent, nfo []byte) (*UploadPayload, *UploadForm, error) {
 
-	// fileList
-	fileList := dict.GetFiles()
-	sortedFileList := bencode.SortFiles(fileList)
+	// build torrent file for tracker
+	// the lossless model keeps keys like url-list or unknown info keys of the original torrent
+	metainfo, err := bencode.ParseMetainfo(torrent)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decode torrent file for release %s: %s", r.Name, err.Error())
+	}
 
-	marshaledFileList, err := json.Marshal(sortedFileList)
+	// set values
+	metainfo.SetAnnounce(d.TrackerAnnounceURL)
+	metainfo.SetAnnounceList(nil)
+	metainfo.SetString("comment", d.Comment)
+	metainfo.SetString("created by", d.CreatedBy)
+	metainfo.SetInt("creation date", time.Now().Unix())
+	metainfo.SetString("bot", "ATUS (github.com/SteffenLoges/atus)")
+
+	// source and private flag are part of the info dict, the destinations torrent gets its own infohash
+	// so clients don't announce it to the source tracker. private outside of info is ignored by clients
+	metainfo.Root.Delete("private")
+	metainfo.SetPrivate(true)
+	metainfo.SetSource(d.GetSourceTag())
+
+	encodedTorrent := metainfo.Bytes()
+
+	dict, err := metainfo.Dict()
 	if err != nil {
-		return nil, err
+		return nil, nil, fmt.Errorf("failed to decode torrent file for release %s: %s", r.Name, err.Error())
 	}
 
-	postData["fileList"] = string(marshaledFileList)
+	desc, err := d.RenderDescription(r, dict, nfo)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to render description for release %s: %s", r.Name, err.Error())
+	}
 
-	// metaFiles
-	marshaledMetaFiles, err := json.Marshal(r.MetaFiles)
+	uploader, err := d.GetUploader()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	postData["metaFiles"] = string(marshaledMetaFiles)
-	postData["hash"] = hash
-	postData["name"] = r.Name
-	postData["category"] = r.Category
-	postData["categoryRaw"] = r.CategoryRaw
-	postData["pre"] = r.Pre.UTC().String()
-	postData["userID"] = d.UserID
+	contactSheet, err := r.getContactSheet()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get contact sheet for release %s: %s", r.Name, err.Error())
+	}
 
-	// -- send request ------------------------------------------------------------------------------
-	for k, v := range postData {
-		if err := writer.WriteField(k, v); err != nil {
-			panic(err)
-		}
+	mediaInfo, err := r.getMediaInfo()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get media info for release %s: %s", r.Name, err.Error())
 	}
 
-	if err := writer.Close(); err != nil {
-		return nil, err
+	p := &UploadPayload{
+		Release:      r,
+		Metainfo:     metainfo,
+		Dict:         dict,
+		Torrent:      encodedTorrent,
+		Hash:         metainfo.InfoHash(),
+		HashV2:       metainfo.InfoHashV2(),
+		NFO:          nfo,
+		ContactSheet: contactSheet,
+		MediaInfo:    mediaInfo,
+		Description:  desc,
+		Category:     d.GetCategory(category.Name(r.Category)),
+		Attributes:   release.ParseName(r.Name),
 	}
 
-	url := fmt.Sprintf("%s?action=upload&authentication=%s", d.APIURL, d.APIAuthToken)
-	req, err := request.NewWithContext(ctx, "POST", url, buf)
+	form, err := uploader.Form(p)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	// attachments don't depend on the tracker type
+	if d.ContactSheetField != "" && p.ContactSheet != nil {
+		form.AddFile(d.ContactSheetField, r.Name+".contactsheet.jpg", p.ContactSheet)
+	}
+
+	return p, form, nil
+
+}
+
+// UploadRelease uploads a release to the destination tracker
+// The tracker is checked for existing torrents first: a torrent with the same infohash, the torrent id of an earlier
+// attempt or the name of a retried lost attempt is reconciled instead of being uploaded again, any other torrent
+// with the same name results in a DupeError. If the tracker can't be searched, the upload fails unless
+// opts.IgnoreDupeCheckErrors is set.
+// Destinations in dry-run mode write the payload to disk instead of sending it, see writeDryRun
+func (d *Destination) UploadRelease(ctx context.Context, r *Release, torrent, nfo []byte, opts *UploadOptions) (*UploadResult, error) {
+
+	if opts == nil {
+		opts = &UploadOptions{}
 	}
 
-	req.Raw.Header.Set("Content-Type", writer.FormDataContentType())
+	p, form, err := d.PrepareUpload(r, torrent, nfo)
+	if err != nil {
+		return nil, err
+	}
 
-	resp, err := req.Do()
+	uploader, err := d.GetUploader()
 	if err != nil {
 		return nil, err
 	}
 
-	defer resp.Body.Close()
+	// the idempotency key is only kept if the previous attempt was sent without getting a response and the payload
+	// didn't change since. Any other attempt gets a new key, otherwise the tracker could match the attempt to a
+	// request with a different payload
+	u := opts.Upload
+	retry := false
+	if u != nil {
+		fingerprint := p.fingerprint(form)
+		retry = u.State == release.UploadStateUploading && u.AttemptKey != "" && u.AttemptPayload == fingerprint
+
+		if !retry {
+			u.AttemptKey = helpers.GetUUID()
+			u.AttemptPayload = fingerprint
+		}
 
-	//  read body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %s", err.Error())
+		p.IdempotencyKey = u.AttemptKey
 	}
 
-	var respStruct struct {
-		Success bool
-		Message string
+	if dc, ok := uploader.(DupeChecker); ok {
+		existing, err := dc.FindExisting(ctx, p)
+		if err != nil {
+			if !opts.IgnoreDupeCheckErrors {
+				return nil, fmt.Errorf("dupe check failed: %w", err)
+			}
+
+			logger.Ref(logger.RefRelease, r.UID).Type(logger.TypeUpload).Warningf("dupe check on destination %s failed, uploading anyway: %s", d.Name, err.Error())
+		}
+
+		// trackers that rewrite the info dict (e.g. UNIT3D) store a different infohash,
+		// their torrents are matched by the id of an earlier attempt or the name of the lost attempt
+		for _, e := range existing {
+			if !strings.EqualFold(e.Hash, p.Hash) && !isPreviousAttempt(e, r, u, retry) {
+				continue
+			}
+
+			ret := &UploadResult{
+				Metainfo:   e.Metainfo,
+				TorrentID:  e.TorrentID,
+				TorrentURL: e.TorrentURL,
+				Reconciled: true,
+			}
+
+			if ret.Metainfo == nil {
+				ret.Metainfo = p.Metainfo
+			}
+
+			return ret, nil
+		}
+
+		for _, e := range existing {
+			if strings.EqualFold(e.Name, r.Name) {
+				return nil, &DupeError{Existing: e}
+			}
+		}
 	}
 
-	if err := json.Unmarshal(body, &respStruct); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response body: %s; err: %s", body, err.Error())
+	if d.DryRun {
+		if err := d.writeDryRun(p, form); err != nil {
+			return nil, fmt.Errorf("failed to write dry run payload: %s", err.Error())
+		}
+		return &UploadResult{Metainfo: p.Metainfo}, nil
 	}
 
-	// check if error is set
-	if !respStruct.Success {
-		return nil, fmt.Errorf("failed to upload release: %s; Raw: %s", respStruct.Message, body)
+	if u != nil {
+		u.State = release.UploadStateUploading
+		u.Message = ""
+		if err := u.Save(); err != nil {
+			return nil, err
+		}
 	}
 
-	return dict, nil
+	res, err := uploader.Upload(ctx, p, form)
+
+	// without a response the tracker may have received the upload. The state stays UPLOADING,
+	// so the next attempt keeps the key and is reconciled if the upload arrived
+	if err != nil && u != nil && !isLostResponse(err) {
+		u.State = release.UploadStateUploadError
+	}
+
+	return res, err
+
+}
+
+// isPreviousAttempt returns true if the existing torrent was uploaded by an earlier attempt to the destination
+func isPreviousAttempt(e *ExistingTorrent, r *Release, u *release.Upload, retry bool) bool {
+
+	if u == nil {
+		return false
+	}
+
+	// the torrent id of a dupe belongs to the other torrent
+	if u.TorrentID != "" && u.State != release.UploadStateDupe && e.TorrentID == u.TorrentID {
+		return true
+	}
+
+	return retry && strings.EqualFold(e.Name, r.Name)
+
+}
+
+// errUploadUnconfirmed is returned by Uploaders if the tracker accepted the upload but its result couldn't be fetched
+var errUploadUnconfirmed = errors.New("upload was accepted but the result couldn't be fetched")
+
+// isLostResponse returns true if the tracker may have received the upload but the response got lost, e.g. on timeouts
+func isLostResponse(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, errUploadUnconfirmed)
+}
+
+// setIdempotencyKey sets the Idempotency-Key header of an upload request
+func setIdempotencyKey(req *request.Request, p *UploadPayload) {
+	if p.IdempotencyKey != "" {
+		req.Raw.Header.Set("Idempotency-Key", p.IdempotencyKey)
+	}
+}
+
+// rawID returns a json number or string as string
+func rawID(raw json.RawMessage) string {
+	return strings.Trim(string(raw), `"`)
+}
+
+// form fields that hold credentials, they are redacted in dry runs
+var dryRunCredentialFields = []string{"auth", "api_token"}
+
+// getDryRunFolder returns the folder dry runs of the release are written to
+func (d *Destination) getDryRunFolder(r *Release) string {
+	return path.Join(config.Base.Folders.Data, r.UID, "dry-run", d.UID)
+}
+
+// writeDryRun writes the form fields as fields.json and the form files to the dry run folder of the release
+func (d *Destination) writeDryRun(p *UploadPayload, form *UploadForm) error {
+
+	folder := d.getDryRunFolder(p.Release)
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return err
+	}
+
+	// the data folder is served to destinations, the api key must not end up in it
+	redacted := make(map[string]string, len(form.Fields))
+	for k, v := range form.Fields {
+		redacted[k] = v
+		if d.TrackerAPIKey != "" && strings.Contains(v, d.TrackerAPIKey) {
+			redacted[k] = "REDACTED"
+		}
+	}
+
+	for _, k := range dryRunCredentialFields {
+		if _, ok := redacted[k]; ok {
+			redacted[k] = "REDACTED"
+		}
+	}
+
+	fields, err := json.MarshalIndent(redacted, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path.Join(folder, "fields.json"), fields, 0644); err != nil {
+		return err
+	}
+
+	for _, f := range form.Files {
+		if err := os.WriteFile(path.Join(folder, f.Field+"_"+path.Base(f.FileName)), f.Data, 0644); err != nil {
+			return err
+		}
+	}
+
+	logger.Ref(logger.RefRelease, p.Release.UID).Type(logger.TypeUpload).Infof("dry run for destination %s written to %s", d.Name, folder)
+
+	return nil
 
 }
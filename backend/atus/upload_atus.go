@@ -0,0 +1,194 @@
+package atus
+
+import (
+	"atus/backend/bencode"
+	"atus/backend/request"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+)
+
+type atusUploader struct {
+	d *Destination
+}
+
+// Form builds the form of the atus-tracker-api plugin
+func (u *atusUploader) Form(p *UploadPayload) (*UploadForm, error) {
+
+	d := u.d
+	r := p.Release
+
+	form := &UploadForm{
+		Fields: map[string]string{},
+	}
+
+	// add files
+	form.AddFile("torrent", "torrent.torrent", p.Torrent)
+	form.AddFile("nfo", "nfo.nfo", p.NFO)
+
+	// fileList
+	fileList := p.Dict.GetContentFiles()
+	sortedFileList := bencode.SortFiles(fileList)
+
+	marshaledFileList, err := json.Marshal(sortedFileList)
+	if err != nil {
+		return nil, err
+	}
+
+	form.Fields["fileList"] = string(marshaledFileList)
+
+	// metaFiles
+	marshaledMetaFiles, err := json.Marshal(r.GetMetaFiles())
+	if err != nil {
+		return nil, err
+	}
+
+	form.Fields["metaFiles"] = string(marshaledMetaFiles)
+
+	// externalIDs
+	marshaledExternalIDs, err := json.Marshal(r.GetExternalIDs())
+	if err != nil {
+		return nil, err
+	}
+
+	form.Fields["externalIDs"] = string(marshaledExternalIDs)
+
+	// metadata, null if no metadata provider knows the movie or show
+	marshaledMetadata, err := json.Marshal(r.GetMetadata())
+	if err != nil {
+		return nil, err
+	}
+
+	form.Fields["metadata"] = string(marshaledMetadata)
+	form.Fields["hash"] = p.Hash
+	form.Fields["name"] = r.Name
+	form.Fields["description"] = p.Description
+	form.Fields["mediaInfo"] = p.MediaInfo
+	form.Fields["category"] = p.Category
+	form.Fields["categoryRaw"] = r.CategoryRaw
+	form.Fields["pre"] = r.Pre.UTC().String()
+	form.Fields["userID"] = d.UserID
+
+	return form, nil
+
+}
+
+// Upload uploads a release to the atus-tracker-api plugin
+func (u *atusUploader) Upload(ctx context.Context, p *UploadPayload, form *UploadForm) (*UploadResult, error) {
+
+	d := u.d
+
+	buf, contentType, err := form.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	uploadURL := fmt.Sprintf("%s?action=upload&authentication=%s", d.APIURL, d.APIAuthToken)
+	req, err := request.NewWithContext(ctx, "POST", uploadURL, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Raw.Header.Set("Content-Type", contentType)
+	setIdempotencyKey(req, p)
+
+	resp, err := req.Do()
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	//  read body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// id and url are returned by newer versions of the plugin
+	var respStruct struct {
+		Success bool
+		Message string
+		ID      json.RawMessage
+		URL     string
+	}
+
+	if err := json.Unmarshal(body, &respStruct); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %s; err: %s", body, err.Error())
+	}
+
+	// check if error is set
+	if !respStruct.Success {
+		return nil, fmt.Errorf("failed to upload release: %s; Raw: %s", respStruct.Message, body)
+	}
+
+	return &UploadResult{
+		Metainfo:   p.Metainfo,
+		TorrentID:  rawID(respStruct.ID),
+		TorrentURL: respStruct.URL,
+	}, nil
+
+}
+
+// FindExisting searches the tracker for torrents with the name or infohash of the release
+func (u *atusUploader) FindExisting(ctx context.Context, p *UploadPayload) ([]*ExistingTorrent, error) {
+
+	d := u.d
+
+	query := url.Values{}
+	query.Set("action", "exists")
+	query.Set("authentication", d.APIAuthToken)
+	query.Set("hash", p.Hash)
+	query.Set("name", p.Release.Name)
+
+	req, err := request.NewWithContext(ctx, "GET", d.APIURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := req.Do()
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %s", err.Error())
+	}
+
+	var respStruct struct {
+		Success  bool
+		Message  string
+		Torrents []struct {
+			ID   json.RawMessage
+			Name string
+			Hash string
+			URL  string
+		}
+	}
+
+	if err := json.Unmarshal(body, &respStruct); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %s; err: %s", body, err.Error())
+	}
+
+	if !respStruct.Success {
+		return nil, fmt.Errorf("dupe check failed: %s", respStruct.Message)
+	}
+
+	var existing []*ExistingTorrent
+	for _, t := range respStruct.Torrents {
+		existing = append(existing, &ExistingTorrent{
+			Name:       t.Name,
+			Hash:       t.Hash,
+			TorrentID:  rawID(t.ID),
+			TorrentURL: t.URL,
+		})
+	}
+
+	return existing, nil
+
+}
@@ -0,0 +1,50 @@
+package atus
+
+import (
+	"atus/backend/config"
+	"atus/backend/destination"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestWriteDryRunRedactsCredentials(t *testing.T) {
+
+	dataFolder := config.Base.Folders.Data
+	config.Base.Folders.Data = t.TempDir()
+	t.Cleanup(func() { config.Base.Folders.Data = dataFolder })
+
+	d := &Destination{&destination.Destination{UID: "gazelle", Name: "Gazelle", TrackerAPIKey: "secret-key"}}
+	r := &Release{UID: "r", Name: "Release"}
+
+	form := &UploadForm{Fields: map[string]string{
+		"auth":      "secret-key",
+		"api_token": "other-secret",
+		"desc":      "https://tracker.example/announce?key=secret-key",
+		"title":     "Release",
+	}}
+	form.AddFile("file_input", "Release.torrent", []byte("torrent"))
+
+	if err := d.writeDryRun(&UploadPayload{Release: r}, form); err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := os.ReadFile(path.Join(d.getDryRunFolder(r), "fields.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(buf), "secret") {
+		t.Errorf("expected the credentials to be redacted, got %s", buf)
+	}
+
+	if !strings.Contains(string(buf), `"title": "Release"`) {
+		t.Errorf("expected the other fields to be written, got %s", buf)
+	}
+
+	if form.Fields["auth"] != "secret-key" {
+		t.Error("expected the form to be unchanged")
+	}
+
+}
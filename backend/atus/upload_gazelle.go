@@ -0,0 +1,142 @@
+package atus
+
+import (
+	"atus/backend/request"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type gazelleUploader struct {
+	d *Destination
+}
+
+// Form builds the Gazelle upload.php form
+func (u *gazelleUploader) Form(p *UploadPayload) (*UploadForm, error) {
+
+	d := u.d
+	r := p.Release
+
+	form := &UploadForm{}
+	form.AddFile("file_input", r.Name+".torrent", p.Torrent)
+
+	title := p.Attributes.Title
+	if title == "" {
+		title = r.Name
+	}
+
+	form.Fields = map[string]string{
+		"submit":       "true",
+		"auth":         d.TrackerAPIKey,
+		"type":         p.Category,
+		"title":        title,
+		"media":        d.GetType(p.Attributes.Source),
+		"resolution":   d.GetResolution(p.Attributes.Resolution),
+		"codec":        p.Attributes.VideoCodec,
+		"desc":         p.Description,
+		"release_desc": r.Name,
+	}
+
+	if p.Attributes.Year > 0 {
+		form.Fields["year"] = strconv.Itoa(p.Attributes.Year)
+	}
+
+	if pm := r.getPoster(); pm != nil {
+		form.Fields["image"] = d.getFileURL(pm)
+	}
+
+	// gazelle tags are lowercase and dot separated, e.g. science.fiction
+	if m := r.GetMetadata(); m != nil && len(m.Genres) > 0 {
+		tags := make([]string, len(m.Genres))
+		for i, g := range m.Genres {
+			tags[i] = strings.ReplaceAll(strings.ToLower(g), " ", ".")
+		}
+		form.Fields["tags"] = strings.Join(tags, ",")
+	}
+
+	if d.Anonymous {
+		form.Fields["anonymous"] = "1"
+	}
+
+	return form, nil
+
+}
+
+// Upload uploads a release using the Gazelle upload.php form
+// Gazelle doesn't modify the torrent, the uploaded torrent is seeded as it is.
+// Gazelle can't search torrents by release name or infohash, so there is no dupe check for this type.
+func (u *gazelleUploader) Upload(ctx context.Context, p *UploadPayload, form *UploadForm) (*UploadResult, error) {
+
+	d := u.d
+
+	buf, contentType, err := form.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := request.NewWithContext(ctx, "POST", d.APIURL, buf)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Raw.Header.Set("Content-Type", contentType)
+	setIdempotencyKey(req, p)
+
+	// ajax.php?action=upload authenticates with the api key in the authorization header,
+	// upload.php with the auth form field
+	req.Raw.Header.Set("Authorization", d.TrackerAPIKey)
+
+	resp, err := req.Do()
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	// ajax.php returns json
+	var respStruct struct {
+		Status   string `json:"status"`
+		Error    string `json:"error"`
+		Response struct {
+			TorrentID json.RawMessage `json:"torrentid"`
+			GroupID   json.RawMessage `json:"groupid"`
+		} `json:"response"`
+	}
+
+	if err := json.Unmarshal(body, &respStruct); err == nil {
+		if respStruct.Status != "success" {
+			return nil, fmt.Errorf("failed to upload release: %s; Raw: %s", respStruct.Error, body)
+		}
+
+		ret := &UploadResult{
+			Metainfo:  p.Metainfo,
+			TorrentID: rawID(respStruct.Response.TorrentID),
+		}
+
+		if ret.TorrentID != "" {
+			ret.TorrentURL = resp.Request.URL.Scheme + "://" + resp.Request.URL.Host + "/torrents.php?torrentid=" + ret.TorrentID
+		}
+
+		return ret, nil
+	}
+
+	// upload.php redirects to the torrent page on success and shows the form with an error otherwise
+	if !strings.Contains(resp.Request.URL.Path, "torrents.php") {
+		return nil, fmt.Errorf("failed to upload release: tracker did not redirect to the torrent page (%s)", resp.Request.URL.String())
+	}
+
+	return &UploadResult{
+		Metainfo:   p.Metainfo,
+		TorrentID:  resp.Request.URL.Query().Get("torrentid"),
+		TorrentURL: resp.Request.URL.String(),
+	}, nil
+
+}
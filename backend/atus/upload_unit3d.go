@@ -0,0 +1,285 @@
+package atus
+
+import (
+	"atus/backend/bencode"
+	"atus/backend/release"
+	"atus/backend/request"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var unit3dDownloadIDRegExp = regexp.MustCompile(`/download/(\d+)`)
+
+type unit3dUploader struct {
+	d *Destination
+}
+
+// Form builds the form of the UNIT3D api (/api/torrents/upload)
+func (u *unit3dUploader) Form(p *UploadPayload) (*UploadForm, error) {
+
+	d := u.d
+	r := p.Release
+
+	form := &UploadForm{}
+	form.AddFile("torrent", r.Name+".torrent", p.Torrent)
+	if len(p.NFO) > 0 {
+		form.AddFile("nfo", r.Name+".nfo", p.NFO)
+	}
+
+	anonymous := "0"
+	if d.Anonymous {
+		anonymous = "1"
+	}
+
+	// standard definition
+	sd := "0"
+	if res, err := strconv.Atoi(strings.TrimRight(p.Attributes.Resolution, "pi")); err == nil && res < 720 {
+		sd = "1"
+	}
+
+	form.Fields = map[string]string{
+		"name":             r.Name,
+		"description":      p.Description,
+		"mediainfo":        p.MediaInfo,
+		"category_id":      p.Category,
+		"type_id":          d.GetType(p.Attributes.Source),
+		"resolution_id":    d.GetResolution(p.Attributes.Resolution),
+		"anonymous":        anonymous,
+		"sd":               sd,
+		"stream":           "0",
+		"internal":         "0",
+		"personal_release": "0",
+		"tmdb":             "0",
+		"imdb":             "0",
+		"tvdb":             "0",
+		"mal":              "0",
+		"igdb":             "0",
+	}
+
+	// UNIT3D expects numeric ids, imdb ids without the tt prefix
+	if id := strings.TrimPrefix(r.GetExternalIDs().Get(release.ExternalIDTypeIMDb), "tt"); id != "" {
+		form.Fields["imdb"] = id
+	}
+
+	if id := r.GetExternalIDs().Get(release.ExternalIDTypeTVDB); id != "" {
+		form.Fields["tvdb"] = id
+	}
+
+	if id := r.GetExternalIDs().Get(release.ExternalIDTypeTMDB); id != "" {
+		form.Fields["tmdb"] = id
+	}
+
+	if p.Attributes.Season > 0 {
+		form.Fields["season_number"] = strconv.Itoa(p.Attributes.Season)
+		form.Fields["episode_number"] = strconv.Itoa(p.Attributes.Episode)
+	}
+
+	return form, nil
+
+}
+
+// Upload uploads a release using the UNIT3D api (/api/torrents/upload)
+// UNIT3D rewrites the torrent on upload, the torrent that has to be seeded is downloaded from the tracker afterwards.
+func (u *unit3dUploader) Upload(ctx context.Context, p *UploadPayload, form *UploadForm) (*UploadResult, error) {
+
+	d := u.d
+
+	buf, contentType, err := form.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	uploadURL, err := url.Parse(d.APIURL)
+	if err != nil {
+		return nil, err
+	}
+
+	query := uploadURL.Query()
+	query.Set("api_token", d.TrackerAPIKey)
+	uploadURL.RawQuery = query.Encode()
+
+	req, err := request.NewWithContext(ctx, "POST", uploadURL.String(), buf)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Raw.Header.Set("Content-Type", contentType)
+	setIdempotencyKey(req, p)
+
+	resp, err := req.Do()
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var respStruct struct {
+		Success bool            `json:"success"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	}
+
+	if err := json.Unmarshal(body, &respStruct); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %s; err: %s", body, err.Error())
+	}
+
+	if !respStruct.Success {
+		return nil, fmt.Errorf("failed to upload release: %s; Raw: %s", respStruct.Message, body)
+	}
+
+	// on success, data contains the download url of the rewritten torrent
+	var downloadURL string
+	if err := json.Unmarshal(respStruct.Data, &downloadURL); err != nil || downloadURL == "" {
+		return nil, fmt.Errorf("no download url in response: %s", body)
+	}
+
+	metainfo, err := u.downloadTorrent(ctx, downloadURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errUploadUnconfirmed, err.Error())
+	}
+
+	ret := &UploadResult{
+		Metainfo: metainfo,
+	}
+
+	// the torrent id is part of the download url (/torrent/download/{id}.{rsskey})
+	if m := unit3dDownloadIDRegExp.FindStringSubmatch(downloadURL); m != nil {
+		ret.TorrentID = m[1]
+		ret.TorrentURL = u.getTorrentURL(m[1])
+	}
+
+	return ret, nil
+
+}
+
+// getTorrentURL returns the url of the torrents details page
+func (u *unit3dUploader) getTorrentURL(id string) string {
+	apiURL, err := url.Parse(u.d.APIURL)
+	if err != nil {
+		return ""
+	}
+
+	return apiURL.Scheme + "://" + apiURL.Host + "/torrents/" + id
+}
+
+// FindExisting searches the tracker for torrents with the name of the release using the filter api (/api/torrents/filter)
+// UNIT3D rewrites the info dict on upload, the infohash of our torrent never matches. Uploads of earlier attempts are
+// recognized by their torrent id or name, see UploadRelease
+func (u *unit3dUploader) FindExisting(ctx context.Context, p *UploadPayload) ([]*ExistingTorrent, error) {
+
+	d := u.d
+
+	filterURL, err := url.Parse(d.APIURL)
+	if err != nil {
+		return nil, err
+	}
+
+	filterURL.Path = strings.TrimSuffix(strings.TrimSuffix(filterURL.Path, "/"), "/upload") + "/filter"
+
+	query := url.Values{}
+	query.Set("api_token", d.TrackerAPIKey)
+	query.Set("name", p.Release.Name)
+	filterURL.RawQuery = query.Encode()
+
+	req, err := request.NewWithContext(ctx, "GET", filterURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Raw.Header.Set("Accept", "application/json")
+
+	resp, err := req.Do()
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %s", err.Error())
+	}
+
+	var respStruct struct {
+		Data []struct {
+			ID         json.RawMessage `json:"id"`
+			Attributes struct {
+				Name         string `json:"name"`
+				InfoHash     string `json:"info_hash"`
+				DownloadLink string `json:"download_link"`
+				DetailsLink  string `json:"details_link"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+
+	if err := json.Unmarshal(body, &respStruct); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response body: %s; err: %s", body, err.Error())
+	}
+
+	var existing []*ExistingTorrent
+	for _, t := range respStruct.Data {
+		e := &ExistingTorrent{
+			Name:       t.Attributes.Name,
+			Hash:       t.Attributes.InfoHash,
+			TorrentID:  rawID(t.ID),
+			TorrentURL: t.Attributes.DetailsLink,
+		}
+
+		if e.TorrentURL == "" {
+			e.TorrentURL = u.getTorrentURL(e.TorrentID)
+		}
+
+		// the torrent on the tracker is the one that has to be seeded if the result is reconciled
+		reconcilable := strings.EqualFold(e.Hash, p.Hash) || strings.EqualFold(e.Name, p.Release.Name)
+		if reconcilable && t.Attributes.DownloadLink != "" {
+			if e.Metainfo, err = u.downloadTorrent(ctx, t.Attributes.DownloadLink); err != nil {
+				return nil, err
+			}
+		}
+
+		existing = append(existing, e)
+	}
+
+	return existing, nil
+
+}
+
+func (u *unit3dUploader) downloadTorrent(ctx context.Context, downloadURL string) (*bencode.Metainfo, error) {
+
+	req, err := request.NewWithContext(ctx, "GET", downloadURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := req.Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to download torrent from tracker: %s", err.Error())
+	}
+
+	defer resp.Body.Close()
+
+	torrent, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	metainfo, err := bencode.ParseMetainfo(torrent)
+	if err != nil {
+		return nil, errors.New("tracker returned an invalid torrent file")
+	}
+
+	return metainfo, nil
+
+}
@@ -0,0 +1,393 @@
+package atus
+
+import (
+	"atus/backend/bencode"
+	"atus/backend/category"
+	"atus/backend/destination"
+	"atus/backend/release"
+	"atus/backend/sqlite"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+
+	// upload states are saved to sqlite
+	if err := sqlite.Connect(":memory:"); err != nil {
+		panic(err)
+	}
+
+	if err := sqlite.Prepare(); err != nil {
+		panic(err)
+	}
+
+	os.Exit(m.Run())
+
+}
+
+type unit3dTestTorrent struct {
+	id      int
+	name    string
+	torrent []byte // the torrent as it was rewritten by the tracker
+}
+
+// unit3dTestTracker is a stand-in for the UNIT3D api. Like UNIT3D it rewrites the info dict of uploaded torrents
+type unit3dTestTracker struct {
+	*httptest.Server
+
+	m        sync.Mutex
+	torrents []*unit3dTestTorrent
+
+	// idempotency keys of all upload requests
+	uploads []string
+
+	// the connection is closed after the upload was stored, the response gets lost
+	loseResponse bool
+
+	// the filter api responds with an error
+	failFilter bool
+}
+
+// set changes the behaviour of the tracker
+func (tr *unit3dTestTracker) set(loseResponse, failFilter bool) {
+	tr.m.Lock()
+	defer tr.m.Unlock()
+
+	tr.loseResponse = loseResponse
+	tr.failFilter = failFilter
+}
+
+// uploadCount returns the number of upload requests
+func (tr *unit3dTestTracker) uploadCount() int {
+	tr.m.Lock()
+	defer tr.m.Unlock()
+
+	return len(tr.uploads)
+}
+
+func newUnit3dTestTracker(t *testing.T) *unit3dTestTracker {
+
+	tr := &unit3dTestTracker{}
+	tr.Server = httptest.NewServer(http.HandlerFunc(tr.serveHTTP))
+	t.Cleanup(tr.Close)
+
+	return tr
+
+}
+
+// add stores a torrent of another uploader
+func (tr *unit3dTestTracker) add(name string, torrent []byte) *unit3dTestTorrent {
+
+	tr.m.Lock()
+	defer tr.m.Unlock()
+
+	metainfo, err := bencode.ParseMetainfo(torrent)
+	if err != nil {
+		panic(err)
+	}
+
+	metainfo.SetSource("UNIT3D")
+
+	t := &unit3dTestTorrent{
+		id:      len(tr.torrents) + 1,
+		name:    name,
+		torrent: metainfo.Bytes(),
+	}
+	tr.torrents = append(tr.torrents, t)
+
+	return t
+
+}
+
+func (tr *unit3dTestTracker) serveHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if r.URL.Query().Get("api_token") != "" && r.URL.Query().Get("api_token") != "token" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tr.m.Lock()
+	loseResponse, failFilter := tr.loseResponse, tr.failFilter
+	tr.m.Unlock()
+
+	switch {
+	case r.URL.Path == "/api/torrents/upload" && r.Method == "POST":
+		file, _, err := r.FormFile("torrent")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(file)
+
+		tr.m.Lock()
+		tr.uploads = append(tr.uploads, r.Header.Get("Idempotency-Key"))
+		tr.m.Unlock()
+
+		t := tr.add(r.FormValue("name"), buf.Bytes())
+
+		if loseResponse {
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				panic(err)
+			}
+			conn.Close()
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Torrent uploaded successfully.",
+			"data":    fmt.Sprintf("%s/torrent/download/%d.rsskey", tr.URL, t.id),
+		})
+
+	case r.URL.Path == "/api/torrents/filter":
+		if failFilter {
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		type attributes struct {
+			Name         string `json:"name"`
+			InfoHash     string `json:"info_hash"`
+			DownloadLink string `json:"download_link"`
+		}
+
+		type result struct {
+			ID         int        `json:"id"`
+			Attributes attributes `json:"attributes"`
+		}
+
+		tr.m.Lock()
+		results := []result{}
+		for _, t := range tr.torrents {
+			if !strings.Contains(t.name, r.URL.Query().Get("name")) {
+				continue
+			}
+
+			metainfo, _ := bencode.ParseMetainfo(t.torrent)
+			results = append(results, result{
+				ID: t.id,
+				Attributes: attributes{
+					Name:         t.name,
+					InfoHash:     metainfo.InfoHash(),
+					DownloadLink: fmt.Sprintf("%s/torrent/download/%d.rsskey", tr.URL, t.id),
+				},
+			})
+		}
+		tr.m.Unlock()
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": results})
+
+	case strings.HasPrefix(r.URL.Path, "/torrent/download/"):
+		tr.m.Lock()
+		defer tr.m.Unlock()
+
+		for _, t := range tr.torrents {
+			if r.URL.Path == fmt.Sprintf("/torrent/download/%d.rsskey", t.id) {
+				w.Write(t.torrent)
+				return
+			}
+		}
+
+		http.NotFound(w, r)
+
+	default:
+		http.NotFound(w, r)
+	}
+
+}
+
+func newUnit3dTestDestination(tr *unit3dTestTracker) *Destination {
+	return &Destination{&destination.Destination{
+		UID:                "unit3d",
+		Name:               "Test Tracker",
+		Type:               destination.TypeUNIT3D,
+		APIURL:             tr.URL + "/api/torrents/upload",
+		TrackerAPIKey:      "token",
+		TrackerAnnounceURL: "https://tracker.example/announce",
+	}}
+}
+
+func newUnit3dTestRelease(t *testing.T) (*Release, []byte) {
+
+	r := &Release{
+		UID:      "r",
+		Name:     "Some.Movie.2020.1080p.BluRay.x264-GRP",
+		Category: string(category.Movie),
+	}
+
+	metainfo, err := bencode.Create(r.Name+".mkv", 16384, []*bencode.File{
+		{Path: []string{r.Name + ".mkv"}, Length: 20000},
+	}, bytes.Repeat([]byte{1}, 40))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r, metainfo.Bytes()
+
+}
+
+func TestUnit3dUploadLostResponse(t *testing.T) {
+
+	tr := newUnit3dTestTracker(t)
+	d := newUnit3dTestDestination(tr)
+	r, torrent := newUnit3dTestRelease(t)
+
+	u := release.NewUpload(r.UID, d.UID)
+	opts := &UploadOptions{Upload: u}
+
+	// the tracker stores the upload but the response gets lost
+	tr.set(true, false)
+	if _, err := d.UploadRelease(context.Background(), r, torrent, nil, opts); err == nil || !isLostResponse(err) {
+		t.Fatalf("expected a lost response, got %v", err)
+	}
+
+	if u.State != release.UploadStateUploading || u.AttemptKey == "" {
+		t.Fatalf("expected state UPLOADING with an attempt key, got %s %q", u.State, u.AttemptKey)
+	}
+
+	key := u.AttemptKey
+
+	// the retry finds the lost upload by its name although the tracker changed the infohash
+	tr.set(false, false)
+	res, err := d.UploadRelease(context.Background(), r, torrent, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !res.Reconciled || res.TorrentID != "1" {
+		t.Errorf("expected the upload to be reconciled with torrent 1, got %+v", res)
+	}
+
+	if res.Metainfo == nil || res.Metainfo.Source() != "UNIT3D" {
+		t.Error("expected the torrent of the tracker to be seeded")
+	}
+
+	if u.AttemptKey != key {
+		t.Errorf("expected the retry to keep the attempt key %s, got %s", key, u.AttemptKey)
+	}
+
+	if tr.uploadCount() != 1 {
+		t.Errorf("expected 1 upload, got %d", tr.uploadCount())
+	}
+
+}
+
+func TestUnit3dUploadChangedPayload(t *testing.T) {
+
+	tr := newUnit3dTestTracker(t)
+	d := newUnit3dTestDestination(tr)
+	r, torrent := newUnit3dTestRelease(t)
+
+	u := release.NewUpload(r.UID, d.UID)
+	opts := &UploadOptions{Upload: u}
+
+	tr.set(true, false)
+	if _, err := d.UploadRelease(context.Background(), r, torrent, nil, opts); err == nil {
+		t.Fatal("expected a lost response")
+	}
+
+	key := u.AttemptKey
+
+	// the category was changed during approval, the retry is a different upload
+	r.Category = string(category.Docu)
+
+	tr.set(false, false)
+	_, err := d.UploadRelease(context.Background(), r, torrent, nil, opts)
+
+	var dupeErr *DupeError
+	if !errors.As(err, &dupeErr) {
+		t.Fatalf("expected a dupe error, got %v", err)
+	}
+
+	if u.AttemptKey == key {
+		t.Error("expected a new attempt key for the changed payload")
+	}
+
+}
+
+func TestUnit3dUploadDupe(t *testing.T) {
+
+	tr := newUnit3dTestTracker(t)
+	d := newUnit3dTestDestination(tr)
+	r, torrent := newUnit3dTestRelease(t)
+
+	// another uploader was faster
+	other := tr.add(r.Name, torrent)
+
+	u := release.NewUpload(r.UID, d.UID)
+	_, err := d.UploadRelease(context.Background(), r, torrent, nil, &UploadOptions{Upload: u})
+
+	var dupeErr *DupeError
+	if !errors.As(err, &dupeErr) {
+		t.Fatalf("expected a dupe error, got %v", err)
+	}
+
+	if dupeErr.Existing.TorrentID != fmt.Sprint(other.id) {
+		t.Errorf("expected dupe of torrent %d, got %s", other.id, dupeErr.Existing.TorrentID)
+	}
+
+	if tr.uploadCount() != 0 {
+		t.Errorf("expected no upload, got %d", tr.uploadCount())
+	}
+
+	// the torrent id of an earlier attempt is reconciled
+	u.State = release.UploadStateUploadError
+	u.TorrentID = dupeErr.Existing.TorrentID
+
+	res, err := d.UploadRelease(context.Background(), r, torrent, nil, &UploadOptions{Upload: u})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !res.Reconciled {
+		t.Error("expected the upload to be reconciled by its torrent id")
+	}
+
+	// but not the torrent id of a dupe
+	u.State = release.UploadStateDupe
+	if _, err := d.UploadRelease(context.Background(), r, torrent, nil, &UploadOptions{Upload: u}); !errors.As(err, &dupeErr) {
+		t.Errorf("expected a dupe error, got %v", err)
+	}
+
+}
+
+func TestUnit3dUploadDupeCheckFailed(t *testing.T) {
+
+	tr := newUnit3dTestTracker(t)
+	d := newUnit3dTestDestination(tr)
+	r, torrent := newUnit3dTestRelease(t)
+
+	tr.set(false, true)
+
+	u := release.NewUpload(r.UID, d.UID)
+	if _, err := d.UploadRelease(context.Background(), r, torrent, nil, &UploadOptions{Upload: u}); err == nil {
+		t.Fatal("expected the failed dupe check to block the upload")
+	}
+
+	if tr.uploadCount() != 0 {
+		t.Fatalf("expected no upload, got %d", tr.uploadCount())
+	}
+
+	// the user overrides the dupe check
+	res, err := d.UploadRelease(context.Background(), r, torrent, nil, &UploadOptions{Upload: u, IgnoreDupeCheckErrors: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Reconciled || res.TorrentID != "1" || tr.uploadCount() != 1 {
+		t.Errorf("expected torrent 1 to be uploaded, got %+v", res)
+	}
+
+}
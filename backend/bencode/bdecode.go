@@ -17,6 +17,9 @@ type Dict struct {
 	Info    *Info  `bencode:"info" json:"info"`
 	Private int    `bencode:"private" json:"private"`
 	Bot     string `bencode:"bot" json:"bot"`
+
+	// v2 piece hashes of files larger than the piece length, keyed by the files pieces root (BEP 52)
+	PieceLayers map[string]string `bencode:"piece layers,omitempty" json:"-"`
 }
 
 type Info struct {
@@ -30,6 +33,17 @@ type Info struct {
 	// these are only present if the torrent is a multi file torrent
 	Files    []*File `bencode:"files" json:"files"`
 	UniqueID string  `bencode:"unique id" json:"uniqueId"`
+
+	// set by trackers like UNIT3D, part of the infohash
+	Source string `bencode:"source,omitempty" json:"source"`
+
+	// private flag (BEP 27), part of the infohash
+	Private int `bencode:"private,omitempty" json:"private"`
+
+	// MetaVersion is 2 for v2 and hybrid torrents (BEP 52). v2 only torrents have no pieces and files,
+	// their files are described by FileTree. Hybrid torrents contain both.
+	MetaVersion int                    `bencode:"meta version,omitempty" json:"metaVersion"`
+	FileTree    map[string]interface{} `bencode:"file tree,omitempty" json:"-"`
 }
 
 func BDecode(data []byte) (*Dict, error) {
@@ -51,6 +65,22 @@ func BDecode(data []byte) (*Dict, error) {
 type File struct {
 	Path   []string `bencode:"path" json:"path"`
 	Length int64    `bencode:"length" json:"length"`
+
+	// file attributes (BEP 47). Hybrid torrents align files to pieces with padding files (attr p)
+	Attr string `bencode:"attr,omitempty" json:"attr,omitempty"`
+
+	// root of the files v2 merkle tree. Only set for files of v2 only torrents
+	PiecesRoot []byte `bencode:"-" json:"-"`
+}
+
+// IsPadding returns true if the file is a padding file
+func (f *File) IsPadding() bool {
+	return strings.Contains(f.Attr, "p")
+}
+
+// IsV2Only returns true if the torrent has no v1 file list and pieces
+func (d *Dict) IsV2Only() bool {
+	return d.Info.MetaVersion == 2 && len(d.Info.Pieces) == 0
 }
 
 // GetSize returns the size of the torrent in bytes.
@@ -60,7 +90,7 @@ func (d *Dict) GetSize() int64 {
 	}
 
 	var size int64
-	for _, file := range d.GetFiles() {
+	for _, file := range d.GetContentFiles() {
 		size += file.Length
 	}
 	return size
@@ -68,12 +98,18 @@ func (d *Dict) GetSize() int64 {
 
 // GetFiles returns a list of files in the torrent.
 // single file torrents will return a list with a length of 1.
+// The index of a file in the list is the index used by torrent clients. Hybrid torrents contain
+// padding files, use GetContentFiles to get the files without them.
 func (d *Dict) GetFiles() []*File {
 
 	if len(d.Info.Files) > 0 {
 		return d.Info.Files
 	}
 
+	if d.IsV2Only() {
+		return getFileTreeFiles(d.Info.FileTree, nil)
+	}
+
 	return []*File{
 		{
 			Path:   []string{d.Info.Name},
@@ -82,6 +118,59 @@ func (d *Dict) GetFiles() []*File {
 	}
 }
 
+// GetContentFiles returns the files of the torrent without padding files
+func (d *Dict) GetContentFiles() []*File {
+
+	var files []*File
+	for _, f := range d.GetFiles() {
+		if !f.IsPadding() {
+			files = append(files, f)
+		}
+	}
+
+	return files
+
+}
+
+// getFileTreeFiles flattens a v2 file tree. Files are returned in the order of the encoded tree,
+// which is sorted by path as required by BEP 52.
+// Each file is a dict with an empty key containing its length and pieces root.
+func getFileTreeFiles(tree map[string]interface{}, parent []string) []*File {
+
+	names := make([]string, 0, len(tree))
+	for name := range tree {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var files []*File
+	for _, name := range names {
+		node, ok := tree[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		path := append(append([]string{}, parent...), name)
+
+		if leaf, ok := node[""].(map[string]interface{}); ok {
+			f := &File{
+				Path: path,
+			}
+			f.Length, _ = leaf["length"].(int64)
+			if root, ok := leaf["pieces root"].(string); ok {
+				f.PiecesRoot = []byte(root)
+			}
+			files = append(files, f)
+			continue
+		}
+
+		files = append(files, getFileTreeFiles(node, path)...)
+	}
+
+	return files
+
+}
+
 func SortFiles(files []*File) []*File {
 	sortedFiles := make([]*File, len(files))
 	copy(sortedFiles, files)
@@ -158,7 +247,7 @@ func (d *Dict) GetFilesHierarchical() *HierarchicalFolder {
 		Path: d.Info.Name,
 	}
 
-	for _, file := range d.GetFiles() {
+	for _, file := range d.GetContentFiles() {
 		progressFileRecursively(hs, file)
 	}
 
@@ -0,0 +1,54 @@
+package bencode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Create builds a new v1 torrent from a file list and its piece hashes. The torrent contains only the info dict,
+// announce and the other top level keys are set when the torrent is rewritten for a destination.
+// A single file whose path equals the name creates a single file torrent.
+func Create(name string, pieceLength int64, files []*File, pieces []byte) (*Metainfo, error) {
+
+	if name == "" {
+		return nil, errors.New("missing name")
+	}
+
+	if len(files) == 0 {
+		return nil, errors.New("missing files")
+	}
+
+	if pieceLength <= 0 {
+		return nil, fmt.Errorf("invalid piece length %d", pieceLength)
+	}
+
+	if len(pieces)%pieceHashLength != 0 {
+		return nil, fmt.Errorf("length of pieces (%d) is not a multiple of %d", len(pieces), pieceHashLength)
+	}
+
+	// keys are inserted in sorted order
+	info := NewDict()
+
+	if len(files) == 1 && len(files[0].Path) == 1 && files[0].Path[0] == name {
+		info.Set("length", NewInt(files[0].Length))
+	} else {
+		list := NewList()
+		for _, f := range files {
+			file := NewDict()
+			file.Set("length", NewInt(f.Length))
+			file.Set("path", NewStringList(f.Path))
+			list.List = append(list.List, file)
+		}
+		info.Set("files", list)
+	}
+
+	info.Set("name", NewString(name))
+	info.Set("piece length", NewInt(pieceLength))
+	info.Set("pieces", NewBytes(pieces))
+
+	root := NewDict()
+	root.Set("info", info)
+
+	return &Metainfo{Root: root}, nil
+
+}
@@ -0,0 +1,54 @@
+package bencode
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestCreate(t *testing.T) {
+
+	files := []*File{
+		{Path: []string{"a.mkv"}, Length: 40000},
+		{Path: []string{"Sample", "b.mkv"}, Length: 1000},
+	}
+	pieces := bytes.Repeat([]byte{1}, 3*pieceHashLength)
+
+	m, err := Create("test", blockSize, files, pieces)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dict, err := m.Dict()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r := Validate(dict, DefaultLimits); !r.Valid() {
+		t.Errorf("expected valid torrent, got %s", r.String())
+	}
+
+	if !reflect.DeepEqual(dict.GetFiles(), files) || !bytes.Equal(dict.Info.Pieces, pieces) {
+		t.Errorf("unexpected files %v", dict.GetFiles())
+	}
+
+	// the encoded torrent must be canonical, otherwise the infohash changes after decoding
+	if m2, err := ParseMetainfo(m.Bytes()); err != nil || m2.InfoHash() != m.InfoHash() {
+		t.Errorf("infohash changed after decoding: %v", err)
+	}
+
+	// -- single file
+	m, err = Create("a.mkv", blockSize, files[:1], pieces[:3*pieceHashLength])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Info().Get("files") != nil || m.Info().Get("length").Int != 40000 {
+		t.Errorf("expected single file torrent: %q", m.Bytes())
+	}
+
+	if _, err := Create("test", blockSize, files, pieces[:1]); err == nil {
+		t.Error("expected error for invalid pieces")
+	}
+
+}
@@ -14,12 +14,8 @@ func hashBuffer(buf bytes.Buffer) (string, error) {
 	return fmt.Sprintf("%x", hash.Sum(nil)), nil
 }
 
-func (dr DictRaw) GenHash() (string, error) {
-	var infoBuf bytes.Buffer
-	bencode.NewEncoder(&infoBuf).Encode(dr["info"])
-	return hashBuffer(infoBuf)
-}
-
+// GenHash hashes the info dict as it is encoded from the Info struct.
+// Keys unknown to Info are lost, use Metainfo.InfoHash for the hash of the original torrent.
 func (d *Dict) GenHash() (string, error) {
 	var infoBuf bytes.Buffer
 	bencode.NewEncoder(&infoBuf).Encode(d.Info)
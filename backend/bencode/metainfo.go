@@ -0,0 +1,205 @@
+package bencode
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// Metainfo is a lossless representation of a torrent file.
+// Unlike Dict, it keeps unknown keys and the original key order, so a torrent that is decoded and
+// encoded again is byte-identical and its infohash does not change.
+type Metainfo struct {
+	Root *Value
+}
+
+// ParseMetainfo decodes a torrent file
+func ParseMetainfo(data []byte) (*Metainfo, error) {
+
+	root, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if root.Kind != KindDict {
+		return nil, errors.New("torrent is not a dict")
+	}
+
+	if info := root.Get("info"); info == nil || info.Kind != KindDict {
+		return nil, errors.New("missing 'info' in dict")
+	}
+
+	return &Metainfo{Root: root}, nil
+
+}
+
+// Bytes returns the encoded torrent file
+func (m *Metainfo) Bytes() []byte {
+	return m.Root.Encode()
+}
+
+// Dict decodes the torrent into the Dict struct
+func (m *Metainfo) Dict() (*Dict, error) {
+	return BDecode(m.Bytes())
+}
+
+// Info returns the info dict
+func (m *Metainfo) Info() *Value {
+	return m.Root.Get("info")
+}
+
+// InfoBytes returns the encoded info dict as it is used for the infohash
+func (m *Metainfo) InfoBytes() []byte {
+	return m.Info().Encode()
+}
+
+// MetaVersion returns the meta version of the torrent. 1 for v1 torrents, 2 for v2 and hybrid torrents (BEP 52)
+func (m *Metainfo) MetaVersion() int64 {
+	if v := m.Info().Get("meta version"); v != nil && v.Kind == KindInt {
+		return v.Int
+	}
+	return 1
+}
+
+// IsHybrid returns true if the torrent contains v1 and v2 metadata
+func (m *Metainfo) IsHybrid() bool {
+	return m.MetaVersion() == 2 && m.Info().Get("pieces") != nil
+}
+
+// IsV2Only returns true if the torrent only contains v2 metadata
+func (m *Metainfo) IsV2Only() bool {
+	return m.MetaVersion() == 2 && m.Info().Get("pieces") == nil
+}
+
+// InfoHash returns the hex encoded SHA-1 hash of the info dict.
+// v2 only torrents have no v1 infohash, the v2 infohash truncated to 20 bytes is used
+// in its place by trackers and clients (BEP 52).
+func (m *Metainfo) InfoHash() string {
+	if m.IsV2Only() {
+		return m.InfoHashV2()[:2*sha1.Size]
+	}
+	return fmt.Sprintf("%x", sha1.Sum(m.InfoBytes()))
+}
+
+// InfoHashV2 returns the hex encoded SHA-256 hash of the info dict. Empty for v1 torrents
+func (m *Metainfo) InfoHashV2() string {
+	if m.MetaVersion() != 2 {
+		return ""
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(m.InfoBytes()))
+}
+
+// GetString returns the string value of a top level key
+func (m *Metainfo) GetString(key string) string {
+	return m.Root.Get(key).Str()
+}
+
+// SetString sets a top level key to a string value. Empty values remove the key.
+func (m *Metainfo) SetString(key, value string) {
+	if value == "" {
+		m.Root.Delete(key)
+		return
+	}
+	m.Root.Set(key, NewString(value))
+}
+
+// SetInt sets a top level key to an integer value
+func (m *Metainfo) SetInt(key string, value int64) {
+	m.Root.Set(key, NewInt(value))
+}
+
+// Announce returns the announce url
+func (m *Metainfo) Announce() string {
+	return m.GetString("announce")
+}
+
+// SetAnnounce sets the announce url
+func (m *Metainfo) SetAnnounce(announce string) {
+	m.SetString("announce", announce)
+}
+
+// AnnounceList returns the tiers of the announce-list (BEP 12)
+func (m *Metainfo) AnnounceList() [][]string {
+	v := m.Root.Get("announce-list")
+	if v == nil || v.Kind != KindList {
+		return nil
+	}
+
+	var tiers [][]string
+	for _, tier := range v.List {
+		if urls := tier.Strings(); len(urls) > 0 {
+			tiers = append(tiers, urls)
+		}
+	}
+	return tiers
+}
+
+// SetAnnounceList sets the tiers of the announce-list. An empty list removes the key.
+func (m *Metainfo) SetAnnounceList(tiers [][]string) {
+	if len(tiers) == 0 {
+		m.Root.Delete("announce-list")
+		return
+	}
+
+	l := NewList()
+	for _, tier := range tiers {
+		l.List = append(l.List, NewStringList(tier))
+	}
+	m.Root.Set("announce-list", l)
+}
+
+// URLList returns the web seeds (BEP 19). url-list is either a single string or a list of strings.
+func (m *Metainfo) URLList() []string {
+	v := m.Root.Get("url-list")
+	if v == nil {
+		return nil
+	}
+
+	if v.Kind == KindString {
+		if len(v.String) == 0 {
+			return nil
+		}
+		return []string{v.Str()}
+	}
+
+	return v.Strings()
+}
+
+// SetURLList sets the web seeds. An empty list removes the key.
+func (m *Metainfo) SetURLList(urls []string) {
+	if len(urls) == 0 {
+		m.Root.Delete("url-list")
+		return
+	}
+	m.Root.Set("url-list", NewStringList(urls))
+}
+
+// Source returns the source tag of the info dict. Trackers use it to make the infohash unique.
+func (m *Metainfo) Source() string {
+	return m.Info().Get("source").Str()
+}
+
+// SetSource sets the source tag of the info dict. This changes the infohash. An empty value removes the tag.
+func (m *Metainfo) SetSource(source string) {
+	if source == "" {
+		m.Info().Delete("source")
+		return
+	}
+	m.Info().Set("source", NewString(source))
+}
+
+// Private returns true if the private flag of the info dict is set (BEP 27)
+func (m *Metainfo) Private() bool {
+	v := m.Info().Get("private")
+	return v != nil && v.Kind == KindInt && v.Int == 1
+}
+
+// SetPrivate sets or removes the private flag of the info dict. This changes the infohash.
+func (m *Metainfo) SetPrivate(private bool) {
+	if !private {
+		m.Info().Delete("private")
+		return
+	}
+	m.Info().Set("private", NewInt(1))
+}
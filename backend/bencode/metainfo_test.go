@@ -0,0 +1,207 @@
+package bencode
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// keys are not sorted and contain values unknown to Dict
+var testTorrentUnsorted = []byte("d8:announce17:http://a/announce13:announce-listll17:http://a/announceel17:http://b/announceee7:comment4:test8:url-list15:http://seed/dir9:publisher3:foo4:infod4:name4:test6:lengthi1e12:piece lengthi65536e6:pieces20:\xdd\xfe\x163E\xd38\x19:\xbd\xc1\x83\xf8\xe9\xdc\xff\x90KC\x007:privatei1e6:source3:SRC1:x0:ee")
+
+func TestDecode_RoundTrip(t *testing.T) {
+
+	for _, data := range [][]byte{TestTorrent, testTorrentUnsorted, []byte("i-42e"), []byte("le"), []byte("de"), []byte("0:")} {
+		v, err := Decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if encoded := v.Encode(); !bytes.Equal(encoded, data) {
+			t.Errorf("round trip mismatch:\nexpected %q\ngot      %q", data, encoded)
+		}
+	}
+
+}
+
+func TestDecode_Invalid(t *testing.T) {
+
+	for _, data := range []string{"", "i01e", "i-0e", "ie", "i1", "01:a", "2:a", "l", "d1:ae", "di1ei1ee", "i1ei2e", "x"} {
+		if _, err := Decode([]byte(data)); err == nil {
+			t.Errorf("expected error for %q", data)
+		}
+	}
+
+}
+
+func TestMetainfo_InfoHash(t *testing.T) {
+
+	m, err := ParseMetainfo(TestTorrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Dict.GenHash differs because Dict adds an empty 'unique id' to the info dict
+	expected := "5ab3ce54648c453fa0a8fc433ce6402f99e43076"
+	if hash := m.InfoHash(); hash != expected {
+		t.Errorf("expected %s got %s", expected, hash)
+	}
+
+}
+
+func TestMetainfo_Accessors(t *testing.T) {
+
+	m, err := ParseMetainfo(testTorrentUnsorted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Announce() != "http://a/announce" {
+		t.Errorf("unexpected announce %s", m.Announce())
+	}
+
+	expectedTiers := [][]string{{"http://a/announce"}, {"http://b/announce"}}
+	if !reflect.DeepEqual(m.AnnounceList(), expectedTiers) {
+		t.Errorf("unexpected announce-list %v", m.AnnounceList())
+	}
+
+	if !reflect.DeepEqual(m.URLList(), []string{"http://seed/dir"}) {
+		t.Errorf("unexpected url-list %v", m.URLList())
+	}
+
+	if m.Source() != "SRC" || !m.Private() {
+		t.Errorf("unexpected source %s or private %v", m.Source(), m.Private())
+	}
+
+	hash := m.InfoHash()
+
+	// top level changes don't affect the infohash, unknown keys are kept
+	m.SetAnnounce("http://c/announce")
+	m.SetAnnounceList(nil)
+	m.SetURLList([]string{"http://seed/1", "http://seed/2"})
+
+	if m.InfoHash() != hash {
+		t.Error("infohash changed after top level changes")
+	}
+
+	m.SetSource("OTHER")
+	m.SetPrivate(false)
+
+	if m.InfoHash() == hash {
+		t.Error("infohash did not change after info changes")
+	}
+
+	m2, err := ParseMetainfo(m.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m2.Announce() != "http://c/announce" || m2.AnnounceList() != nil || len(m2.URLList()) != 2 {
+		t.Errorf("unexpected values after encoding: %q", m.Bytes())
+	}
+
+	if m2.Source() != "OTHER" || m2.Private() {
+		t.Errorf("unexpected info after encoding: %q", m.Bytes())
+	}
+
+	if m2.GetString("publisher") != "foo" || m2.Info().Get("x") == nil {
+		t.Errorf("unknown keys were dropped: %q", m.Bytes())
+	}
+
+}
+
+func FuzzDecode_RoundTrip(f *testing.F) {
+
+	f.Add(TestTorrent)
+	f.Add(testTorrentUnsorted)
+	f.Add([]byte("d1:ai0e1:bl1:ci-1eee"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		v, err := Decode(data)
+		if err != nil {
+			return
+		}
+
+		encoded := v.Encode()
+		if !bytes.Equal(encoded, data) {
+			t.Fatalf("round trip mismatch:\nexpected %q\ngot      %q", data, encoded)
+		}
+
+		v2, err := Decode(encoded)
+		if err != nil {
+			t.Fatalf("failed to decode encoded value: %s", err.Error())
+		}
+
+		if !reflect.DeepEqual(v, v2) {
+			t.Fatal("decoded values differ")
+		}
+	})
+
+}
+
+func TestMetainfo_HybridRewrite(t *testing.T) {
+
+	data := newV2TestTorrent(2*blockSize, map[string][]byte{"a": bytes.Repeat([]byte{1}, 70000)})
+
+	m, err := ParseMetainfo(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !m.IsV2Only() || m.InfoHash() != m.InfoHashV2()[:40] {
+		t.Fatalf("expected v2 only torrent with truncated v2 infohash, got %s", m.InfoHash())
+	}
+
+	// add v1 metadata with a padding file to make it a hybrid torrent
+	files := NewList()
+	for _, f := range []struct {
+		path   string
+		length int64
+		attr   string
+	}{{"a", 70000, ""}, {".pad", 28304, "p"}} {
+		file := NewDict()
+		if f.attr != "" {
+			file.Set("attr", NewString(f.attr))
+		}
+		file.Set("length", NewInt(f.length))
+		file.Set("path", NewStringList([]string{f.path}))
+		files.List = append(files.List, file)
+	}
+	m.Info().Set("files", files)
+	m.Info().Set("pieces", NewBytes(make([]byte, 3*20)))
+
+	if !m.IsHybrid() {
+		t.Fatal("expected hybrid torrent")
+	}
+
+	fileTree := m.Info().Get("file tree").Encode()
+	pieceLayers := m.Root.Get("piece layers").Encode()
+	hashV2 := m.InfoHashV2()
+
+	m.SetAnnounce("http://a/announce")
+	m.SetSource("DEST")
+	m.SetPrivate(true)
+
+	m2, err := ParseMetainfo(m.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !m2.IsHybrid() || m2.InfoHashV2() == hashV2 || m2.InfoHashV2() == "" {
+		t.Error("expected hybrid torrent with new v2 infohash")
+	}
+
+	if !bytes.Equal(m2.Info().Get("file tree").Encode(), fileTree) || !bytes.Equal(m2.Root.Get("piece layers").Encode(), pieceLayers) {
+		t.Error("v2 structure changed by rewrite")
+	}
+
+	dict, err := m2.Dict()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(dict.GetFiles()) != 2 || len(dict.GetContentFiles()) != 1 || dict.GetSize() != 70000 {
+		t.Errorf("unexpected files %v", dict.GetFiles())
+	}
+
+}
@@ -0,0 +1,267 @@
+package bencode
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "ERROR"   // the torrent must not be processed
+	SeverityWarning Severity = "WARNING" // the torrent is unusual but safe
+)
+
+// Issue is a single finding of Validate
+type Issue struct {
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+}
+
+// Report is the result of Validate
+type Report struct {
+	Issues []*Issue `json:"issues"`
+
+	Files  int   `json:"files"`
+	Pieces int64 `json:"pieces"`
+	Size   int64 `json:"size"`
+}
+
+// Valid returns true if the report contains no errors
+func (r *Report) Valid() bool {
+	return len(r.Errors()) == 0
+}
+
+// Errors returns all issues with SeverityError
+func (r *Report) Errors() []*Issue {
+	var issues []*Issue
+	for _, i := range r.Issues {
+		if i.Severity == SeverityError {
+			issues = append(issues, i)
+		}
+	}
+	return issues
+}
+
+// String returns a single line summary of the errors
+func (r *Report) String() string {
+	var messages []string
+	for _, i := range r.Errors() {
+		messages = append(messages, i.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (r *Report) add(severity Severity, code, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, &Issue{
+		Severity: severity,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Limits are the upper bounds Validate accepts
+type Limits struct {
+	MaxFiles       int
+	MaxPieces      int64
+	MaxPathDepth   int
+	MaxPathLength  int // length of the joined path in bytes
+	MinPieceLength int64
+	MaxPieceLength int64
+}
+
+var DefaultLimits = Limits{
+	MaxFiles:       10000,
+	MaxPieces:      1 << 20,
+	MaxPathDepth:   16,
+	MaxPathLength:  4096,
+	MinPieceLength: blockSize,
+	MaxPieceLength: 256 * 1024 * 1024,
+}
+
+// maximum length of a single path component, most filesystems don't allow longer names
+const maxComponentLength = 255
+
+// Validate checks the structure of a torrent before any of its data is used.
+// Paths are checked for components that would escape the download folder when they are joined.
+func Validate(d *Dict, limits Limits) *Report {
+
+	r := &Report{}
+	info := d.Info
+
+	checkComponent(r, "name", info.Name)
+
+	// -- pieces
+	pieceLength := info.PieceLength
+	switch {
+	case pieceLength <= 0:
+		r.add(SeverityError, "PIECE_LENGTH", "piece length %d is not positive", pieceLength)
+	case pieceLength < limits.MinPieceLength || pieceLength > limits.MaxPieceLength:
+		r.add(SeverityError, "PIECE_LENGTH", "piece length %d is out of range (%d - %d)", pieceLength, limits.MinPieceLength, limits.MaxPieceLength)
+	case pieceLength&(pieceLength-1) != 0:
+		// required for v2, only unusual for v1
+		severity := SeverityWarning
+		if info.MetaVersion == 2 {
+			severity = SeverityError
+		}
+		r.add(severity, "PIECE_LENGTH", "piece length %d is not a power of two", pieceLength)
+	}
+
+	if info.Length > 0 && len(info.Files) > 0 {
+		r.add(SeverityError, "LENGTH_AND_FILES", "torrent has a length and a file list")
+	}
+
+	// -- files
+	files := d.GetFiles()
+	r.Files = len(files)
+	if r.Files > limits.MaxFiles {
+		r.add(SeverityError, "TOO_MANY_FILES", "torrent has %d files, the limit is %d", r.Files, limits.MaxFiles)
+	}
+
+	// lists with more issues than this are most likely generated, one issue per file is enough
+	const maxFileIssues = 10
+
+	seen := make(map[string]bool, len(files))
+	seenFolded := make(map[string]bool, len(files))
+	var totalLength int64
+	fileIssues := len(r.Issues)
+	for i, f := range files {
+		if len(r.Issues)-fileIssues >= maxFileIssues {
+			r.add(SeverityError, "TOO_MANY_ISSUES", "validation of the file list stopped after %d issues", maxFileIssues)
+			break
+		}
+
+		if f.Length < 0 {
+			r.add(SeverityError, "NEGATIVE_LENGTH", "file %d has a negative length", i)
+			continue
+		}
+		totalLength += f.Length
+
+		if !f.IsPadding() {
+			r.Size += f.Length
+		}
+
+		if !checkPath(r, i, f.Path, limits) {
+			continue
+		}
+
+		p := strings.Join(f.Path, "/")
+		if seen[p] {
+			r.add(SeverityError, "DUPLICATE_PATH", "path %q exists more than once", p)
+			continue
+		}
+		seen[p] = true
+
+		if folded := strings.ToLower(p); seenFolded[folded] {
+			r.add(SeverityWarning, "DUPLICATE_PATH", "path %q differs from another path only in case", p)
+		} else {
+			seenFolded[folded] = true
+		}
+
+		if d.IsV2Only() && f.Length > 0 {
+			checkPiecesRoot(r, d, f)
+		}
+	}
+
+	if r.Size == 0 {
+		r.add(SeverityError, "EMPTY", "torrent contains no data")
+	}
+
+	// -- v1 pieces
+	if !d.IsV2Only() && pieceLength > 0 {
+		if len(info.Pieces)%pieceHashLength != 0 {
+			r.add(SeverityError, "PIECES_LENGTH", "length of pieces (%d) is not a multiple of %d", len(info.Pieces), pieceHashLength)
+		}
+
+		r.Pieces = int64(len(info.Pieces) / pieceHashLength)
+		if expected := (totalLength + pieceLength - 1) / pieceLength; r.Pieces != expected {
+			r.add(SeverityError, "PIECE_COUNT", "torrent has %d pieces, %d are expected for %d bytes", r.Pieces, expected, totalLength)
+		}
+	} else if pieceLength > 0 {
+		r.Pieces = (totalLength + pieceLength - 1) / pieceLength
+	}
+
+	if r.Pieces > limits.MaxPieces {
+		r.add(SeverityError, "TOO_MANY_PIECES", "torrent has %d pieces, the limit is %d", r.Pieces, limits.MaxPieces)
+	}
+
+	return r
+
+}
+
+// checkPath checks the components of a file path. Returns false if the path is unsafe
+func checkPath(r *Report, index int, path []string, limits Limits) bool {
+
+	if len(path) == 0 {
+		r.add(SeverityError, "UNSAFE_PATH", "file %d has an empty path", index)
+		return false
+	}
+
+	if len(path) > limits.MaxPathDepth {
+		r.add(SeverityError, "PATH_TOO_DEEP", "path of file %d has %d components, the limit is %d", index, len(path), limits.MaxPathDepth)
+		return false
+	}
+
+	if l := len(strings.Join(path, "/")); l > limits.MaxPathLength {
+		r.add(SeverityError, "PATH_TOO_LONG", "path of file %d is %d bytes long, the limit is %d", index, l, limits.MaxPathLength)
+		return false
+	}
+
+	for _, c := range path {
+		if !checkComponent(r, fmt.Sprintf("path of file %d", index), c) {
+			return false
+		}
+	}
+
+	return true
+
+}
+
+// checkComponent checks a single file or folder name. Returns false if the name is unsafe
+func checkComponent(r *Report, what, c string) bool {
+
+	switch {
+	case c == "":
+		r.add(SeverityError, "UNSAFE_PATH", "%s contains an empty name", what)
+	case c == "." || c == "..":
+		r.add(SeverityError, "UNSAFE_PATH", "%s contains %q", what, c)
+	case strings.ContainsAny(c, "/\\"):
+		r.add(SeverityError, "UNSAFE_PATH", "%s contains a path separator in %q", what, c)
+	case strings.IndexFunc(c, func(r rune) bool { return r < 0x20 || r == 0x7f }) >= 0:
+		r.add(SeverityError, "UNSAFE_PATH", "%s contains control characters in %q", what, c)
+	case len(c) > maxComponentLength:
+		r.add(SeverityError, "PATH_TOO_LONG", "%s contains a name longer than %d bytes", what, maxComponentLength)
+	case len(c) == 2 && c[1] == ':':
+		r.add(SeverityError, "UNSAFE_PATH", "%s contains the drive letter %q", what, c)
+	default:
+		if !utf8.ValidString(c) {
+			r.add(SeverityWarning, "ENCODING", "%s is not valid UTF-8: %q", what, c)
+		}
+		return true
+	}
+
+	return false
+
+}
+
+// checkPiecesRoot checks that a file of a v2 only torrent has a pieces root and a complete piece layer
+func checkPiecesRoot(r *Report, d *Dict, f *File) {
+
+	p := strings.Join(f.Path, "/")
+	if len(f.PiecesRoot) != pieceHashLength2 {
+		r.add(SeverityError, "PIECES_ROOT", "file %q has no valid pieces root", p)
+		return
+	}
+
+	if d.Info.PieceLength <= 0 || f.Length <= d.Info.PieceLength {
+		return
+	}
+
+	expected := (f.Length + d.Info.PieceLength - 1) / d.Info.PieceLength * pieceHashLength2
+	if l := int64(len(d.PieceLayers[string(f.PiecesRoot)])); l != expected {
+		r.add(SeverityError, "PIECE_LAYER", "piece layer of file %q is %d bytes long, %d are expected", p, l, expected)
+	}
+
+}
@@ -0,0 +1,86 @@
+package bencode
+
+import (
+	"testing"
+)
+
+func hasIssue(r *Report, code string) bool {
+	for _, i := range r.Issues {
+		if i.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidate(t *testing.T) {
+
+	dict, err := BDecode(TestTorrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r := Validate(dict, DefaultLimits); !r.Valid() {
+		t.Errorf("expected valid torrent, got %s", r.String())
+	}
+
+	newDict := func(files ...*File) *Dict {
+		var size int64
+		for _, f := range files {
+			size += f.Length
+		}
+		pieces := (size + blockSize - 1) / blockSize
+		return &Dict{Info: &Info{
+			Name:        "test",
+			PieceLength: blockSize,
+			Pieces:      make([]byte, pieces*pieceHashLength),
+			Files:       files,
+		}}
+	}
+
+	tests := []struct {
+		name string
+		dict *Dict
+		code string
+	}{
+		{"traversal", newDict(&File{Path: []string{"..", "etc", "passwd"}, Length: 1}), "UNSAFE_PATH"},
+		{"separator", newDict(&File{Path: []string{"a/../../b"}, Length: 1}), "UNSAFE_PATH"},
+		{"empty path", newDict(&File{Path: []string{}, Length: 1}), "UNSAFE_PATH"},
+		{"duplicate", newDict(&File{Path: []string{"a"}, Length: 1}, &File{Path: []string{"a"}, Length: 1}), "DUPLICATE_PATH"},
+		{"negative length", newDict(&File{Path: []string{"a"}, Length: -1}), "NEGATIVE_LENGTH"},
+		{"empty", newDict(&File{Path: []string{"a"}, Length: 0}), "EMPTY"},
+	}
+
+	for _, test := range tests {
+		r := Validate(test.dict, DefaultLimits)
+		if r.Valid() || !hasIssue(r, test.code) {
+			t.Errorf("%s: expected %s, got %v", test.name, test.code, r.String())
+		}
+	}
+
+	// pieces
+	d := newDict(&File{Path: []string{"a"}, Length: 3 * blockSize})
+	d.Info.Pieces = d.Info.Pieces[:len(d.Info.Pieces)-1]
+	if r := Validate(d, DefaultLimits); !hasIssue(r, "PIECES_LENGTH") || !hasIssue(r, "PIECE_COUNT") {
+		t.Errorf("expected pieces issues, got %s", r.String())
+	}
+
+	d = newDict(&File{Path: []string{"a"}, Length: 1})
+	d.Info.PieceLength = 0
+	if r := Validate(d, DefaultLimits); !hasIssue(r, "PIECE_LENGTH") {
+		t.Errorf("expected piece length issue, got %s", r.String())
+	}
+
+	// limits
+	limits := DefaultLimits
+	limits.MaxFiles = 1
+	if r := Validate(newDict(&File{Path: []string{"a"}, Length: 1}, &File{Path: []string{"b"}, Length: 1}), limits); !hasIssue(r, "TOO_MANY_FILES") {
+		t.Errorf("expected file limit issue, got %s", r.String())
+	}
+
+	// case-insensitive duplicates are only a warning
+	if r := Validate(newDict(&File{Path: []string{"a"}, Length: 1}, &File{Path: []string{"A"}, Length: 1}), DefaultLimits); !r.Valid() || !hasIssue(r, "DUPLICATE_PATH") {
+		t.Errorf("expected duplicate warning, got %v", r.Issues)
+	}
+
+}
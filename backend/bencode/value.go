@@ -0,0 +1,353 @@
+package bencode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Kind is the type of a bencoded value
+type Kind int
+
+const (
+	KindInt Kind = iota
+	KindString
+	KindList
+	KindDict
+)
+
+// maxDepth limits the nesting of lists and dicts. Real torrents rarely nest deeper than 5 levels.
+const maxDepth = 128
+
+// Value is a bencoded value that keeps the order of dict keys.
+// Values decoded with Decode are encoded to the exact same bytes by Encode, including dicts
+// with unsorted keys and keys that are not known to this package.
+type Value struct {
+	Kind Kind
+
+	Int    int64
+	String []byte
+	List   []*Value
+
+	// entries in the order they appear in the encoded data
+	Dict []*Entry
+}
+
+// Entry is a key-value pair of a dict
+type Entry struct {
+	Key   string
+	Value *Value
+}
+
+// NewInt returns an integer value
+func NewInt(i int64) *Value {
+	return &Value{Kind: KindInt, Int: i}
+}
+
+// NewString returns a string value
+func NewString(s string) *Value {
+	return &Value{Kind: KindString, String: []byte(s)}
+}
+
+// NewBytes returns a string value containing binary data
+func NewBytes(b []byte) *Value {
+	return &Value{Kind: KindString, String: b}
+}
+
+// NewList returns a list value
+func NewList(values ...*Value) *Value {
+	return &Value{Kind: KindList, List: values}
+}
+
+// NewStringList returns a list of string values
+func NewStringList(s []string) *Value {
+	l := NewList()
+	for _, v := range s {
+		l.List = append(l.List, NewString(v))
+	}
+	return l
+}
+
+// NewDict returns an empty dict value
+func NewDict() *Value {
+	return &Value{Kind: KindDict}
+}
+
+// Get returns the value of the key or nil if the value is not a dict or the key does not exist
+func (v *Value) Get(key string) *Value {
+	if v == nil || v.Kind != KindDict {
+		return nil
+	}
+
+	for _, e := range v.Dict {
+		if e.Key == key {
+			return e.Value
+		}
+	}
+
+	return nil
+}
+
+// Set sets the value of the key. Existing keys keep their position, new keys are inserted
+// in sorted order as required by the specification.
+func (v *Value) Set(key string, value *Value) {
+	if v.Kind != KindDict {
+		return
+	}
+
+	for _, e := range v.Dict {
+		if e.Key == key {
+			e.Value = value
+			return
+		}
+	}
+
+	i := sort.Search(len(v.Dict), func(i int) bool {
+		return v.Dict[i].Key > key
+	})
+
+	v.Dict = append(v.Dict, nil)
+	copy(v.Dict[i+1:], v.Dict[i:])
+	v.Dict[i] = &Entry{Key: key, Value: value}
+}
+
+// Delete removes the key from the dict
+func (v *Value) Delete(key string) {
+	if v.Kind != KindDict {
+		return
+	}
+
+	for i, e := range v.Dict {
+		if e.Key == key {
+			v.Dict = append(v.Dict[:i], v.Dict[i+1:]...)
+			return
+		}
+	}
+}
+
+// Str returns the value as string. Returns an empty string if the value is not a string
+func (v *Value) Str() string {
+	if v == nil || v.Kind != KindString {
+		return ""
+	}
+	return string(v.String)
+}
+
+// Strings returns the string values of a list. Values of other types are skipped
+func (v *Value) Strings() []string {
+	if v == nil || v.Kind != KindList {
+		return nil
+	}
+
+	var s []string
+	for _, item := range v.List {
+		if item.Kind == KindString {
+			s = append(s, string(item.String))
+		}
+	}
+	return s
+}
+
+// Decode decodes a single bencoded value. Trailing data is not allowed.
+// The decoder is strict, integers with leading zeros or negative zero are rejected
+// because they could not be encoded to the same bytes again.
+func Decode(data []byte) (*Value, error) {
+	d := &decoder{data: data}
+
+	v, err := d.decode(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.pos != len(data) {
+		return nil, fmt.Errorf("trailing data at offset %d", d.pos)
+	}
+
+	return v, nil
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) decode(depth int) (*Value, error) {
+
+	if depth > maxDepth {
+		return nil, errors.New("maximum nesting depth exceeded")
+	}
+
+	if d.pos >= len(d.data) {
+		return nil, errors.New("unexpected end of data")
+	}
+
+	switch c := d.data[d.pos]; {
+	case c == 'i':
+		d.pos++
+		i, err := d.readInt('e')
+		if err != nil {
+			return nil, err
+		}
+		return NewInt(i), nil
+
+	case c >= '0' && c <= '9':
+		s, err := d.readString()
+		if err != nil {
+			return nil, err
+		}
+		return NewBytes(s), nil
+
+	case c == 'l':
+		d.pos++
+		v := NewList()
+		for {
+			if d.pos >= len(d.data) {
+				return nil, errors.New("unexpected end of data in list")
+			}
+			if d.data[d.pos] == 'e' {
+				d.pos++
+				return v, nil
+			}
+
+			item, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			v.List = append(v.List, item)
+		}
+
+	case c == 'd':
+		d.pos++
+		v := NewDict()
+		for {
+			if d.pos >= len(d.data) {
+				return nil, errors.New("unexpected end of data in dict")
+			}
+			if d.data[d.pos] == 'e' {
+				d.pos++
+				return v, nil
+			}
+
+			if c := d.data[d.pos]; c < '0' || c > '9' {
+				return nil, fmt.Errorf("dict key at offset %d is not a string", d.pos)
+			}
+
+			key, err := d.readString()
+			if err != nil {
+				return nil, err
+			}
+
+			item, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			v.Dict = append(v.Dict, &Entry{Key: string(key), Value: item})
+		}
+	}
+
+	return nil, fmt.Errorf("invalid character %q at offset %d", d.data[d.pos], d.pos)
+
+}
+
+// readInt reads a canonical integer terminated by delim
+func (d *decoder) readInt(delim byte) (int64, error) {
+
+	start := d.pos
+	end := bytes.IndexByte(d.data[start:], delim)
+	if end < 0 {
+		return 0, fmt.Errorf("unterminated integer at offset %d", start)
+	}
+
+	raw := d.data[start : start+end]
+	digits := raw
+	if len(digits) > 0 && digits[0] == '-' {
+		digits = digits[1:]
+	}
+
+	if len(digits) == 0 {
+		return 0, fmt.Errorf("empty integer at offset %d", start)
+	}
+
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid integer %q at offset %d", raw, start)
+		}
+	}
+
+	if (len(digits) > 1 && digits[0] == '0') || (len(raw) != len(digits) && digits[0] == '0') {
+		return 0, fmt.Errorf("non-canonical integer %q at offset %d", raw, start)
+	}
+
+	i, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer %q at offset %d: %s", raw, start, err.Error())
+	}
+
+	d.pos = start + end + 1
+	return i, nil
+
+}
+
+func (d *decoder) readString() ([]byte, error) {
+
+	start := d.pos
+	length, err := d.readInt(':')
+	if err != nil {
+		return nil, err
+	}
+
+	if length < 0 {
+		return nil, fmt.Errorf("negative string length at offset %d", start)
+	}
+
+	if length > int64(len(d.data)-d.pos) {
+		return nil, fmt.Errorf("string at offset %d exceeds data", start)
+	}
+
+	s := d.data[d.pos : d.pos+int(length)]
+	d.pos += int(length)
+	return s, nil
+
+}
+
+// Encode encodes the value. Dict keys are written in the order of the entries.
+func (v *Value) Encode() []byte {
+	var buf bytes.Buffer
+	v.encode(&buf)
+	return buf.Bytes()
+}
+
+func (v *Value) encode(buf *bytes.Buffer) {
+	switch v.Kind {
+	case KindInt:
+		buf.WriteByte('i')
+		buf.WriteString(strconv.FormatInt(v.Int, 10))
+		buf.WriteByte('e')
+
+	case KindString:
+		writeString(buf, v.String)
+
+	case KindList:
+		buf.WriteByte('l')
+		for _, item := range v.List {
+			item.encode(buf)
+		}
+		buf.WriteByte('e')
+
+	case KindDict:
+		buf.WriteByte('d')
+		for _, e := range v.Dict {
+			writeString(buf, []byte(e.Key))
+			e.Value.encode(buf)
+		}
+		buf.WriteByte('e')
+	}
+}
+
+func writeString(buf *bytes.Buffer, s []byte) {
+	buf.WriteString(strconv.Itoa(len(s)))
+	buf.WriteByte(':')
+	buf.Write(s)
+}
@@ -0,0 +1,102 @@
+package bencode
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const pieceHashLength = 20
+
+// GetFileOffset returns the offset of the file with the given index in the torrent's data stream.
+func (d *Dict) GetFileOffset(index int) (int64, error) {
+
+	files := d.GetFiles()
+	if index < 0 || index >= len(files) {
+		return 0, fmt.Errorf("file index %d out of range", index)
+	}
+
+	var offset int64
+	for _, f := range files[:index] {
+		offset += f.Length
+	}
+
+	return offset, nil
+
+}
+
+// VerifyFile checks the data of the file with the given index against the torrent's piece hashes.
+// Only pieces that lie completely within the file can be verified. If the file does not start at a piece
+// boundary, no piece can be checked without the data of the previous file and 0 is returned.
+// Returns the number of verified pieces.
+func (d *Dict) VerifyFile(index int, data io.ReaderAt) (int, error) {
+
+	files := d.GetFiles()
+	if index < 0 || index >= len(files) {
+		return 0, fmt.Errorf("file index %d out of range", index)
+	}
+
+	// v2 only torrents have no v1 pieces, their files are verified against the merkle trees
+	if d.IsV2Only() {
+		return d.verifyFileV2(files[index], data)
+	}
+
+	pieceLength := d.Info.PieceLength
+	if pieceLength <= 0 {
+		return 0, errors.New("invalid piece length")
+	}
+
+	if len(d.Info.Pieces)%pieceHashLength != 0 {
+		return 0, errors.New("invalid pieces length")
+	}
+
+	offset, err := d.GetFileOffset(index)
+	if err != nil {
+		return 0, err
+	}
+
+	// the file is not piece-aligned
+	if offset%pieceLength != 0 {
+		return 0, nil
+	}
+
+	fileLength := files[index].Length
+	isLastFile := index == len(files)-1
+	sumPieces := int64(len(d.Info.Pieces) / pieceHashLength)
+
+	buf := make([]byte, pieceLength)
+	verified := 0
+	for pos := int64(0); pos < fileLength; pos += pieceLength {
+
+		length := pieceLength
+		if pos+length > fileLength {
+			// the last piece of the file contains data of the next file
+			if !isLastFile {
+				break
+			}
+			length = fileLength - pos
+		}
+
+		pieceIndex := (offset + pos) / pieceLength
+		if pieceIndex >= sumPieces {
+			return verified, fmt.Errorf("piece %d out of range", pieceIndex)
+		}
+
+		if _, err := data.ReadAt(buf[:length], pos); err != nil {
+			return verified, fmt.Errorf("could not read piece %d: %s", pieceIndex, err)
+		}
+
+		sum := sha1.Sum(buf[:length])
+		expected := d.Info.Pieces[pieceIndex*pieceHashLength : (pieceIndex+1)*pieceHashLength]
+		if !bytes.Equal(sum[:], expected) {
+			return verified, fmt.Errorf("hash mismatch for piece %d", pieceIndex)
+		}
+
+		verified++
+	}
+
+	return verified, nil
+
+}
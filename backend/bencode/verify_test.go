@@ -0,0 +1,88 @@
+package bencode
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"testing"
+)
+
+func newVerifyTestDict(pieceLength int64, files ...[]byte) *Dict {
+
+	var stream []byte
+	info := &Info{
+		Name:        "test",
+		PieceLength: pieceLength,
+	}
+
+	for i, f := range files {
+		stream = append(stream, f...)
+		info.Files = append(info.Files, &File{
+			Path:   []string{string(rune('a' + i))},
+			Length: int64(len(f)),
+		})
+	}
+
+	for pos := int64(0); pos < int64(len(stream)); pos += pieceLength {
+		end := pos + pieceLength
+		if end > int64(len(stream)) {
+			end = int64(len(stream))
+		}
+		sum := sha1.Sum(stream[pos:end])
+		info.Pieces = append(info.Pieces, sum[:]...)
+	}
+
+	return &Dict{Info: info}
+
+}
+
+func TestVerifyFile(t *testing.T) {
+
+	first := bytes.Repeat([]byte{1}, 32)
+	second := bytes.Repeat([]byte{2}, 20)
+	dict := newVerifyTestDict(16, first, second)
+
+	// first file: two full pieces
+	verified, err := dict.VerifyFile(0, bytes.NewReader(first))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if verified != 2 {
+		t.Errorf("expected 2 verified pieces, got %d", verified)
+	}
+
+	// second file: one full piece and the last (short) piece of the torrent
+	verified, err = dict.VerifyFile(1, bytes.NewReader(second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if verified != 2 {
+		t.Errorf("expected 2 verified pieces, got %d", verified)
+	}
+
+	// corrupted data
+	corrupted := append([]byte{}, first...)
+	corrupted[20] = 0
+	if _, err := dict.VerifyFile(0, bytes.NewReader(corrupted)); err == nil {
+		t.Error("expected hash mismatch")
+	}
+
+}
+
+func TestVerifyFile_NotAligned(t *testing.T) {
+
+	first := bytes.Repeat([]byte{1}, 10)
+	second := bytes.Repeat([]byte{2}, 40)
+	dict := newVerifyTestDict(16, first, second)
+
+	verified, err := dict.VerifyFile(1, bytes.NewReader(second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if verified != 0 {
+		t.Errorf("expected 0 verified pieces, got %d", verified)
+	}
+
+}
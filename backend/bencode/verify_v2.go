@@ -0,0 +1,145 @@
+package bencode
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// v2 merkle trees are built from SHA-256 hashes of 16 KiB blocks (BEP 52)
+const (
+	blockSize        = 16 * 1024
+	pieceHashLength2 = sha256.Size
+)
+
+// verifyFileV2 checks the data of a file of a v2 only torrent against its merkle tree.
+// Files that are not larger than the piece length are verified against their pieces root,
+// larger files against the hashes of their piece layer. Returns the number of verified pieces.
+func (d *Dict) verifyFileV2(f *File, data io.ReaderAt) (int, error) {
+
+	// empty files have no pieces root
+	if f.Length == 0 {
+		return 0, nil
+	}
+
+	if len(f.PiecesRoot) != pieceHashLength2 {
+		return 0, errors.New("missing pieces root")
+	}
+
+	pieceLength := d.Info.PieceLength
+	if pieceLength < blockSize || pieceLength%blockSize != 0 {
+		return 0, errors.New("invalid piece length")
+	}
+
+	if f.Length <= pieceLength {
+		buf := make([]byte, f.Length)
+		if _, err := data.ReadAt(buf, 0); err != nil {
+			return 0, fmt.Errorf("could not read file: %s", err)
+		}
+
+		blocks := (f.Length + blockSize - 1) / blockSize
+		if root := merkleRoot(blockHashes(buf), nextPowerOfTwo(blocks), nil); !bytes.Equal(root, f.PiecesRoot) {
+			return 0, errors.New("hash mismatch for pieces root")
+		}
+
+		return 1, nil
+	}
+
+	layer := []byte(d.PieceLayers[string(f.PiecesRoot)])
+	sumPieces := (f.Length + pieceLength - 1) / pieceLength
+	if int64(len(layer)) != sumPieces*pieceHashLength2 {
+		return 0, errors.New("invalid piece layer")
+	}
+
+	// the piece layer has to match the pieces root, missing pieces are hashes of a piece of zero hashes
+	var pieceHashes [][]byte
+	for i := int64(0); i < sumPieces; i++ {
+		pieceHashes = append(pieceHashes, layer[i*pieceHashLength2:(i+1)*pieceHashLength2])
+	}
+
+	padPiece := merkleRoot(nil, pieceLength/blockSize, nil)
+	if root := merkleRoot(pieceHashes, nextPowerOfTwo(sumPieces), padPiece); !bytes.Equal(root, f.PiecesRoot) {
+		return 0, errors.New("piece layer does not match pieces root")
+	}
+
+	buf := make([]byte, pieceLength)
+	verified := 0
+	for pieceIndex := int64(0); pieceIndex < sumPieces; pieceIndex++ {
+
+		pos := pieceIndex * pieceLength
+		length := pieceLength
+		if pos+length > f.Length {
+			length = f.Length - pos
+		}
+
+		if _, err := data.ReadAt(buf[:length], pos); err != nil {
+			return verified, fmt.Errorf("could not read piece %d: %s", pieceIndex, err)
+		}
+
+		// the last piece is padded with zero hashes to a full piece
+		root := merkleRoot(blockHashes(buf[:length]), pieceLength/blockSize, nil)
+		expected := layer[pieceIndex*pieceHashLength2 : (pieceIndex+1)*pieceHashLength2]
+		if !bytes.Equal(root, expected) {
+			return verified, fmt.Errorf("hash mismatch for piece %d", pieceIndex)
+		}
+
+		verified++
+	}
+
+	return verified, nil
+
+}
+
+// blockHashes returns the SHA-256 hashes of the 16 KiB blocks of data. The last block may be shorter
+func blockHashes(data []byte) [][]byte {
+	var hashes [][]byte
+	for pos := 0; pos < len(data); pos += blockSize {
+		end := pos + blockSize
+		if end > len(data) {
+			end = len(data)
+		}
+		sum := sha256.Sum256(data[pos:end])
+		hashes = append(hashes, sum[:])
+	}
+	return hashes
+}
+
+// merkleRoot returns the root of a merkle tree with the given number of leaves, which must be a power of two.
+// Missing leaves are set to pad, or zero hashes if pad is nil
+func merkleRoot(hashes [][]byte, leaves int64, pad []byte) []byte {
+
+	if pad == nil {
+		pad = make([]byte, pieceHashLength2)
+	}
+
+	layer := make([][]byte, leaves)
+	for i := range layer {
+		if i < len(hashes) {
+			layer[i] = hashes[i]
+		} else {
+			layer[i] = pad
+		}
+	}
+
+	for len(layer) > 1 {
+		next := make([][]byte, len(layer)/2)
+		for i := range next {
+			sum := sha256.Sum256(append(append([]byte{}, layer[2*i]...), layer[2*i+1]...))
+			next[i] = sum[:]
+		}
+		layer = next
+	}
+
+	return layer[0]
+
+}
+
+func nextPowerOfTwo(n int64) int64 {
+	p := int64(1)
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
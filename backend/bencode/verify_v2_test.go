@@ -0,0 +1,96 @@
+package bencode
+
+import (
+	"bytes"
+	"testing"
+)
+
+// newV2TestTorrent builds a v2 only torrent with the given files in its root folder
+func newV2TestTorrent(pieceLength int64, files map[string][]byte) []byte {
+
+	tree := NewDict()
+	layers := NewDict()
+
+	for name, data := range files {
+		leaf := NewDict()
+		leaf.Set("length", NewInt(int64(len(data))))
+
+		if len(data) > 0 {
+			var root []byte
+			if int64(len(data)) <= pieceLength {
+				blocks := (int64(len(data)) + blockSize - 1) / blockSize
+				root = merkleRoot(blockHashes(data), nextPowerOfTwo(blocks), nil)
+			} else {
+				var layer []byte
+				var pieceHashes [][]byte
+				for pos := int64(0); pos < int64(len(data)); pos += pieceLength {
+					end := pos + pieceLength
+					if end > int64(len(data)) {
+						end = int64(len(data))
+					}
+					h := merkleRoot(blockHashes(data[pos:end]), pieceLength/blockSize, nil)
+					pieceHashes = append(pieceHashes, h)
+					layer = append(layer, h...)
+				}
+				pad := merkleRoot(nil, pieceLength/blockSize, nil)
+				root = merkleRoot(pieceHashes, nextPowerOfTwo(int64(len(pieceHashes))), pad)
+				layers.Set(string(root), NewBytes(layer))
+			}
+			leaf.Set("pieces root", NewBytes(root))
+		}
+
+		node := NewDict()
+		node.Set("", leaf)
+		tree.Set(name, node)
+	}
+
+	info := NewDict()
+	info.Set("file tree", tree)
+	info.Set("meta version", NewInt(2))
+	info.Set("name", NewString("test"))
+	info.Set("piece length", NewInt(pieceLength))
+
+	root := NewDict()
+	root.Set("info", info)
+	root.Set("piece layers", layers)
+
+	return root.Encode()
+
+}
+
+func TestVerifyFileV2(t *testing.T) {
+
+	small := bytes.Repeat([]byte{1}, 100)
+	big := bytes.Repeat([]byte{2}, 70000)
+
+	dict, err := BDecode(newV2TestTorrent(2*blockSize, map[string][]byte{"a": small, "b": big}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := dict.GetFiles()
+	if len(files) != 2 || files[0].Path[0] != "a" || files[1].Length != int64(len(big)) {
+		t.Fatalf("unexpected files %v", files)
+	}
+
+	if dict.GetSize() != int64(len(small)+len(big)) {
+		t.Errorf("unexpected size %d", dict.GetSize())
+	}
+
+	verified, err := dict.VerifyFile(0, bytes.NewReader(small))
+	if err != nil || verified != 1 {
+		t.Errorf("expected 1 verified piece, got %d (%v)", verified, err)
+	}
+
+	verified, err = dict.VerifyFile(1, bytes.NewReader(big))
+	if err != nil || verified != 3 {
+		t.Errorf("expected 3 verified pieces, got %d (%v)", verified, err)
+	}
+
+	corrupted := append([]byte{}, big...)
+	corrupted[40000] = 0
+	if _, err := dict.VerifyFile(1, bytes.NewReader(corrupted)); err == nil {
+		t.Error("expected hash mismatch for corrupted data")
+	}
+
+}
@@ -31,12 +31,16 @@ type Category struct {
 	Includes []string
 	Excludes []string
 	MaxSize  int64
+
+	// releases of this category have to be approved before they are uploaded
+	ApprovalRequired bool
 }
 
 const categoryEnabledConfigKey = "FILTERS__CATEGORY_%s_ENABLED"
 const categoryIncludesConfigKey = "FILTERS__CATEGORY_%s_INCLUDES"
 const categoryExcludesConfigKey = "FILTERS__CATEGORY_%s_EXCLUDES"
 const categoryMaxSizeConfigKey = "FILTERS__CATEGORY_%s_MAX_SIZE"
+const categoryApprovalRequiredConfigKey = "FILTERS__CATEGORY_%s_APPROVAL_REQUIRED"
 
 func Get(name Name) (*Category, error) {
 
@@ -57,6 +61,8 @@ func Get(name Name) (*Category, error) {
 		Name:    name,
 		Enabled: config.GetBool(fmt.Sprintf(categoryEnabledConfigKey, name)),
 		MaxSize: config.GetInt64(fmt.Sprintf(categoryMaxSizeConfigKey, name)),
+
+		ApprovalRequired: config.GetBool(fmt.Sprintf(categoryApprovalRequiredConfigKey, name)),
 	}
 
 	// includes
@@ -111,6 +117,7 @@ func (c *Category) Save() error {
 	config.Set(fmt.Sprintf(categoryIncludesConfigKey, c.Name), string(includesBytes))
 	config.Set(fmt.Sprintf(categoryExcludesConfigKey, c.Name), string(excludesBytes))
 	config.Set(fmt.Sprintf(categoryMaxSizeConfigKey, c.Name), c.MaxSize)
+	config.Set(fmt.Sprintf(categoryApprovalRequiredConfigKey, c.Name), c.ApprovalRequired)
 
 	return nil
 
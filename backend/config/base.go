@@ -15,8 +15,9 @@ var BaseFolder = GetEnv("BASE_FOLDER", "./atus_data")
 var Base *baseConfig
 
 type baseConfigFolders struct {
-	WWW  string `json:"www"`
-	Data string `json:"data"`
+	WWW   string `json:"www"`
+	Data  string `json:"data"`
+	Cache string `json:"cache"` // resized images, can be deleted at any time
 }
 
 type baseConfigAuth struct {
@@ -61,8 +62,9 @@ func getBaseConfigDefaults() (*baseConfig, error) {
 	return &baseConfig{
 		SQLiteDSN: "./atus_data/db.sqlite3?cache=shared&mode=rwc",
 		Folders: &baseConfigFolders{
-			WWW:  "./www",
-			Data: "./atus_data/data",
+			WWW:   "./www",
+			Data:  "./atus_data/data",
+			Cache: "./atus_data/cache",
 		},
 		Auth: &baseConfigAuth{
 			JWTSecret:        jwtSecret,
@@ -146,6 +148,11 @@ func init() {
 
 	Base = baseConfig
 
+	// config files created before the cache folder existed
+	if Base.Folders.Cache == "" {
+		Base.Folders.Cache = path.Join(BaseFolder, "cache")
+	}
+
 	// create data folder if it doesn't exist
 	if _, err := os.Stat(Base.Folders.Data); os.IsNotExist(err) {
 		if err := os.MkdirAll(Base.Folders.Data, 0755); err != nil {
@@ -24,6 +24,8 @@ var configDefaults = map[string]interface{}{
 	"API__AUTH_TOKEN": "",
 
 	// -- Upload ----------------------------------
+	// upload settings are stored per destination.
+	// these keys are only read to migrate installations that were set up before destinations existed
 	"UPLOAD__USER_ID":              "0",
 	"UPLOAD__USER_ANNOUNCE_URL":    "",
 	"UPLOAD__TRACKER_ANNOUNCE_URL": "",
@@ -31,64 +33,108 @@ var configDefaults = map[string]interface{}{
 	"UPLOAD__CREATED_BY":           "ATUS",
 	"UPLOAD__COMMENT":              "Torrent created by ATUS",
 
+	// releases stop at AWAITING_APPROVAL until they are approved in the web interface
+	"UPLOAD__APPROVAL_REQUIRED": false,
+
 	// --------------------------------------------
 	"FILESERVER__DOWNLOAD_LABEL":    "ATUS Download",
 	"FILESERVER__UPLOAD_LABEL":      "ATUS Upload",
 	"FILESERVER__ALLOCATION_METHOD": "MOST_FREE",
+	"FILESERVER__DOWNLOAD_TIMEOUT":  int64(300),  // in seconds, per meta file
+	"FILESERVER__HASH_TIMEOUT":      int64(1800), // in seconds, per re-created torrent
 
 	// -- Samples ---------------------------------
-	"SAMPLES__ENABLED":         true,
-	"SAMPLES__SUM_SCREENSHOTS": int64(3),
-	"SAMPLES__MIN_SIZE":        int64(helpers.MiB * 2),
-	"SAMPLES__MAX_SIZE":        int64(helpers.MiB * 200),
+	"SAMPLES__ENABLED":               true,
+	"SAMPLES__SUM_SCREENSHOTS":       int64(3),
+	"SAMPLES__SCREENSHOT_CANDIDATES": int64(3), // frames scored per screenshot, the best one is kept
+	"SAMPLES__FROM_RELEASE_VIDEO":    true,     // releases without sample get screenshots and technical info from the main video on the fileserver
+	"SAMPLES__CONTACT_SHEET_COLUMNS": int64(4), // 0 disables the contact sheet
+	"SAMPLES__CONTACT_SHEET_ROWS":    int64(4),
+	"SAMPLES__MIN_SIZE":              int64(helpers.MiB * 2),
+	"SAMPLES__MAX_SIZE":              int64(helpers.MiB * 200),
+	"SAMPLES__WORKERS":               int64(1),    // number of samples converted in parallel, requires a restart
+	"SAMPLES__OUTPUT_FORMAT":         "DASH",      // DASH, HLS or DASH_HLS. DASH_HLS packages every sample twice
+	"SAMPLES__PACKAGER":              "BENTO4",    // BENTO4 or FFMPEG
+	"SAMPLES__PROFILE":               "",          // json encoded video.Profile, video.DefaultProfile if empty
+	"SAMPLES__TIMEOUT":               int64(1800), // in seconds, per sample job
+
+	// -- NFO -------------------------------------
+	// nfos are rendered as png, trackers can show them as image
+	"NFO__RENDER_IMAGE": true,
+
+	// -- Images ----------------------------------
+	// default quality of resized images, can be overridden by the q query param
+	"IMAGES__JPEG_QUALITY": int64(85),
+	"IMAGES__WEBP_QUALITY": int64(80),
+
+	// -- Metadata --------------------------------
+	// accepted movies and shows are looked up on TVmaze and TMDB, see metadata.Providers
+	"METADATA__ENABLED":         true,
+	"METADATA__TVMAZE_ENABLED":  true,
+	"METADATA__TMDB_API_KEY":    "", // v3 api key or v4 read access token, TMDB is disabled if empty
+	"METADATA__LANGUAGE":        "en-US",
+	"METADATA__CACHE_TTL":       int64(168), // in hours, 0 disables the cache
+	"METADATA__DOWNLOAD_POSTER": true,
 
 	// -- Filters ---------------------------------
 	"FILTERS__MAX_AGE": int64(0),
 
-	"FILTERS__CATEGORY_MOVIE_ENABLED":  true,
-	"FILTERS__CATEGORY_MOVIE_INCLUDES": "[]",
-	"FILTERS__CATEGORY_MOVIE_EXCLUDES": "[]",
-	"FILTERS__CATEGORY_MOVIE_MAX_SIZE": int64(0),
-
-	"FILTERS__CATEGORY_TV_ENABLED":  true,
-	"FILTERS__CATEGORY_TV_INCLUDES": "[]",
-	"FILTERS__CATEGORY_TV_EXCLUDES": "[]",
-	"FILTERS__CATEGORY_TV_MAX_SIZE": int64(0),
-
-	"FILTERS__CATEGORY_DOCU_ENABLED":  true,
-	"FILTERS__CATEGORY_DOCU_INCLUDES": "[]",
-	"FILTERS__CATEGORY_DOCU_EXCLUDES": "[]",
-	"FILTERS__CATEGORY_DOCU_MAX_SIZE": int64(0),
-
-	"FILTERS__CATEGORY_APP_ENABLED":  true,
-	"FILTERS__CATEGORY_APP_INCLUDES": "[]",
-	"FILTERS__CATEGORY_APP_EXCLUDES": "[]",
-	"FILTERS__CATEGORY_APP_MAX_SIZE": int64(0),
-
-	"FILTERS__CATEGORY_GAME_ENABLED":  true,
-	"FILTERS__CATEGORY_GAME_INCLUDES": "[]",
-	"FILTERS__CATEGORY_GAME_EXCLUDES": "[]",
-	"FILTERS__CATEGORY_GAME_MAX_SIZE": int64(0),
-
-	"FILTERS__CATEGORY_AUDIO_ENABLED":  true,
-	"FILTERS__CATEGORY_AUDIO_INCLUDES": "[]",
-	"FILTERS__CATEGORY_AUDIO_EXCLUDES": "[]",
-	"FILTERS__CATEGORY_AUDIO_MAX_SIZE": int64(0),
-
-	"FILTERS__CATEGORY_EBOOK_ENABLED":  true,
-	"FILTERS__CATEGORY_EBOOK_INCLUDES": "[]",
-	"FILTERS__CATEGORY_EBOOK_EXCLUDES": "[]",
-	"FILTERS__CATEGORY_EBOOK_MAX_SIZE": int64(0),
-
-	"FILTERS__CATEGORY_XXX_ENABLED":  true,
-	"FILTERS__CATEGORY_XXX_INCLUDES": "[]",
-	"FILTERS__CATEGORY_XXX_EXCLUDES": "[]",
-	"FILTERS__CATEGORY_XXX_MAX_SIZE": int64(0),
-
-	"FILTERS__CATEGORY_UNKNOWN_ENABLED":  true,
-	"FILTERS__CATEGORY_UNKNOWN_INCLUDES": "[]",
-	"FILTERS__CATEGORY_UNKNOWN_EXCLUDES": "[]",
-	"FILTERS__CATEGORY_UNKNOWN_MAX_SIZE": int64(0),
+	// torrents that fail bencode.Validate are saved as QUARANTINED instead of being dropped
+	"FILTERS__QUARANTINE_INVALID_TORRENTS": true,
+
+	"FILTERS__CATEGORY_MOVIE_ENABLED":           true,
+	"FILTERS__CATEGORY_MOVIE_INCLUDES":          "[]",
+	"FILTERS__CATEGORY_MOVIE_EXCLUDES":          "[]",
+	"FILTERS__CATEGORY_MOVIE_MAX_SIZE":          int64(0),
+	"FILTERS__CATEGORY_MOVIE_APPROVAL_REQUIRED": false,
+
+	"FILTERS__CATEGORY_TV_ENABLED":           true,
+	"FILTERS__CATEGORY_TV_INCLUDES":          "[]",
+	"FILTERS__CATEGORY_TV_EXCLUDES":          "[]",
+	"FILTERS__CATEGORY_TV_MAX_SIZE":          int64(0),
+	"FILTERS__CATEGORY_TV_APPROVAL_REQUIRED": false,
+
+	"FILTERS__CATEGORY_DOCU_ENABLED":           true,
+	"FILTERS__CATEGORY_DOCU_INCLUDES":          "[]",
+	"FILTERS__CATEGORY_DOCU_EXCLUDES":          "[]",
+	"FILTERS__CATEGORY_DOCU_MAX_SIZE":          int64(0),
+	"FILTERS__CATEGORY_DOCU_APPROVAL_REQUIRED": false,
+
+	"FILTERS__CATEGORY_APP_ENABLED":           true,
+	"FILTERS__CATEGORY_APP_INCLUDES":          "[]",
+	"FILTERS__CATEGORY_APP_EXCLUDES":          "[]",
+	"FILTERS__CATEGORY_APP_MAX_SIZE":          int64(0),
+	"FILTERS__CATEGORY_APP_APPROVAL_REQUIRED": false,
+
+	"FILTERS__CATEGORY_GAME_ENABLED":           true,
+	"FILTERS__CATEGORY_GAME_INCLUDES":          "[]",
+	"FILTERS__CATEGORY_GAME_EXCLUDES":          "[]",
+	"FILTERS__CATEGORY_GAME_MAX_SIZE":          int64(0),
+	"FILTERS__CATEGORY_GAME_APPROVAL_REQUIRED": false,
+
+	"FILTERS__CATEGORY_AUDIO_ENABLED":           true,
+	"FILTERS__CATEGORY_AUDIO_INCLUDES":          "[]",
+	"FILTERS__CATEGORY_AUDIO_EXCLUDES":          "[]",
+	"FILTERS__CATEGORY_AUDIO_MAX_SIZE":          int64(0),
+	"FILTERS__CATEGORY_AUDIO_APPROVAL_REQUIRED": false,
+
+	"FILTERS__CATEGORY_EBOOK_ENABLED":           true,
+	"FILTERS__CATEGORY_EBOOK_INCLUDES":          "[]",
+	"FILTERS__CATEGORY_EBOOK_EXCLUDES":          "[]",
+	"FILTERS__CATEGORY_EBOOK_MAX_SIZE":          int64(0),
+	"FILTERS__CATEGORY_EBOOK_APPROVAL_REQUIRED": false,
+
+	"FILTERS__CATEGORY_XXX_ENABLED":           true,
+	"FILTERS__CATEGORY_XXX_INCLUDES":          "[]",
+	"FILTERS__CATEGORY_XXX_EXCLUDES":          "[]",
+	"FILTERS__CATEGORY_XXX_MAX_SIZE":          int64(0),
+	"FILTERS__CATEGORY_XXX_APPROVAL_REQUIRED": false,
+
+	"FILTERS__CATEGORY_UNKNOWN_ENABLED":           true,
+	"FILTERS__CATEGORY_UNKNOWN_INCLUDES":          "[]",
+	"FILTERS__CATEGORY_UNKNOWN_EXCLUDES":          "[]",
+	"FILTERS__CATEGORY_UNKNOWN_MAX_SIZE":          int64(0),
+	"FILTERS__CATEGORY_UNKNOWN_APPROVAL_REQUIRED": false,
 }
 
 var cache = sync.Map{}
@@ -0,0 +1,105 @@
+package description
+
+import (
+	"atus/backend/release"
+	"bytes"
+	"fmt"
+	"text/template"
+	"time"
+)
+
+// Data is passed to description templates
+type Data struct {
+	Name     string
+	Pre      time.Time
+	Category string // category as it is used by the tracker
+	Size     int64
+
+	// Attributes parsed from the release name
+	Attributes *release.NameAttributes
+
+	// IMDb, TVmaze, TVDB and TMDB ids. Unknown ids are empty strings
+	ExternalIDs *release.ExternalIDs
+
+	// poster, plot, genres, rating and runtime of the movie or show. nil if no metadata provider knows it
+	Metadata *release.Metadata
+
+	// URL of the poster. Empty if the release has none
+	Poster string
+
+	// NFO decoded to UTF-8. Empty if the release has no nfo
+	NFO string
+
+	// URL of the nfo rendered as png. Empty if the release has none
+	NFOImage string
+
+	// URLs of screenshots and other images
+	Screenshots []string
+	Images      []string
+
+	// URL of the contact sheet of the sample. Empty if the release has none
+	ContactSheet string
+
+	// nil if the release has no processed sample
+	Sample *Sample
+
+	Files []*File
+}
+
+type Sample struct {
+	URL      string // DASH manifest, HLS playlist if no DASH manifest was written
+	HLSURL   string // empty if no HLS playlist was written
+	Duration time.Duration
+	Width    int
+	Height   int
+}
+
+// Resolution returns the resolution of the sample (e.g. 1920x1080)
+func (s *Sample) Resolution() string {
+	if s.Width == 0 || s.Height == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%dx%d", s.Width, s.Height)
+}
+
+type File struct {
+	Path string
+	Size int64
+}
+
+// DefaultTemplate is used by destinations without a template of their own
+const DefaultTemplate = `{{ if .NFO }}{{ code .NFO }}{{ else }}{{ .Name }}{{ end }}
+{{- if .Screenshots }}
+
+{{ range $i, $s := .Screenshots }}{{ if $i }} {{ end }}{{ img $s }}{{ end }}
+{{- end }}
+{{- if .Sample }}
+
+{{ b "Sample" }}: {{ .Sample.Duration | duration }}{{ with .Sample.Resolution }} @ {{ . }}{{ end }}
+{{- end }}`
+
+// Parse parses a description template
+func Parse(tpl string) (*template.Template, error) {
+	if tpl == "" {
+		tpl = DefaultTemplate
+	}
+
+	return template.New("description").Funcs(funcMap).Parse(tpl)
+}
+
+// Render renders a description template. An empty template renders the DefaultTemplate
+func Render(tpl string, data *Data) (string, error) {
+
+	t, err := Parse(tpl)
+	if err != nil {
+		return "", err
+	}
+
+	buf := new(bytes.Buffer)
+	if err := t.Execute(buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+
+}
@@ -0,0 +1,103 @@
+package description
+
+import (
+	"atus/backend/helpers"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var funcMap = template.FuncMap{
+	// -- generic ---------------------------------------------------------------------------------
+	"join":     strings.Join,
+	"upper":    strings.ToUpper,
+	"lower":    strings.ToLower,
+	"trim":     strings.TrimSpace,
+	"bytes":    formatBytes,
+	"duration": formatDuration,
+	"date": func(layout string, t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(layout)
+	},
+
+	// -- bbcode ----------------------------------------------------------------------------------
+	"b":      bbTag("b"),
+	"i":      bbTag("i"),
+	"u":      bbTag("u"),
+	"code":   bbTag("code"),
+	"quote":  bbTag("quote"),
+	"center": bbTag("center"),
+	"img":    bbTag("img"),
+	"url": func(url, text string) string {
+		return "[url=" + url + "]" + text + "[/url]"
+	},
+	"size": func(size int, s string) string {
+		return fmt.Sprintf("[size=%d]%s[/size]", size, s)
+	},
+	"spoiler": func(title, s string) string {
+		return "[spoiler=" + title + "]" + s + "[/spoiler]"
+	},
+
+	// -- markdown --------------------------------------------------------------------------------
+	"mdBold":   func(s string) string { return "**" + s + "**" },
+	"mdItalic": func(s string) string { return "_" + s + "_" },
+	"mdCode": func(s string) string {
+		return "```\n" + strings.TrimRight(s, "\n") + "\n```"
+	},
+	"mdImg": func(url string) string {
+		return "![](" + url + ")"
+	},
+	"mdURL": func(url, text string) string {
+		return "[" + text + "](" + url + ")"
+	},
+	"mdQuote": func(s string) string {
+		return "> " + strings.ReplaceAll(strings.TrimRight(s, "\n"), "\n", "\n> ")
+	},
+}
+
+// bbTag returns a template function that wraps its argument in the BBCode tag
+func bbTag(tag string) func(string) string {
+	return func(s string) string {
+		return "[" + tag + "]" + s + "[/" + tag + "]"
+	}
+}
+
+// formatBytes returns a human readable size (e.g. 1.40 GiB)
+func formatBytes(size int64) string {
+	units := []struct {
+		name string
+		size int64
+	}{
+		{"PiB", helpers.PiB},
+		{"TiB", helpers.TiB},
+		{"GiB", helpers.GiB},
+		{"MiB", helpers.MiB},
+		{"KiB", helpers.KiB},
+	}
+
+	for _, u := range units {
+		if size >= u.size {
+			return fmt.Sprintf("%.2f %s", float64(size)/float64(u.size), u.name)
+		}
+	}
+
+	return fmt.Sprintf("%d B", size)
+}
+
+// formatDuration returns a duration as h:mm:ss or m:ss
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+
+	h := int(d / time.Hour)
+	m := int(d % time.Hour / time.Minute)
+	s := int(d % time.Minute / time.Second)
+
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+
+	return fmt.Sprintf("%d:%02d", m, s)
+}
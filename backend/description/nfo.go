@@ -0,0 +1,157 @@
+package description
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Charset is the encoding of an nfo file
+type Charset string
+
+const (
+	CharsetAuto        Charset = "" // detected by DetectCharset
+	CharsetCP437       Charset = "CP437"
+	CharsetCP866       Charset = "CP866" // cyrillic DOS, same box-drawing characters as CP437
+	CharsetUTF8        Charset = "UTF-8"
+	CharsetUTF16LE     Charset = "UTF-16LE"
+	CharsetUTF16BE     Charset = "UTF-16BE"
+	CharsetWindows1252 Charset = "WINDOWS-1252"
+)
+
+// Charsets can be set as override for nfo files
+var Charsets = []Charset{
+	CharsetCP437,
+	CharsetCP866,
+	CharsetUTF8,
+	CharsetUTF16LE,
+	CharsetUTF16BE,
+	CharsetWindows1252,
+}
+
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16LE = []byte{0xff, 0xfe}
+	bomUTF16BE = []byte{0xfe, 0xff}
+)
+
+// ParseCharset returns an error for unknown charsets. An empty string is CharsetAuto
+func ParseCharset(s string) (Charset, error) {
+
+	c := Charset(strings.ToUpper(s))
+	if c == CharsetAuto {
+		return c, nil
+	}
+
+	for _, known := range Charsets {
+		if c == known {
+			return c, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown charset: %s", s)
+
+}
+
+// DetectCharset guesses the encoding of an nfo file.
+// A byte order mark wins, valid UTF-8 with multibyte sequences is UTF-8. Everything else is
+// CP437 unless the non-ASCII bytes look like accented letters in words rather than box-drawing art
+func DetectCharset(nfo []byte) Charset {
+
+	switch {
+	case bytes.HasPrefix(nfo, bomUTF8):
+		return CharsetUTF8
+	case bytes.HasPrefix(nfo, bomUTF16LE):
+		return CharsetUTF16LE
+	case bytes.HasPrefix(nfo, bomUTF16BE):
+		return CharsetUTF16BE
+	}
+
+	ascii := true
+	for _, b := range nfo {
+		if b >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+
+	// plain ASCII looks the same in all charsets
+	if ascii {
+		return CharsetCP437
+	}
+
+	if utf8.Valid(nfo) {
+		return CharsetUTF8
+	}
+
+	// box-drawing and block characters (0xB0-0xDF, 0xFE in CP437) come in runs or next to spaces,
+	// accented letters in WINDOWS-1252 are surrounded by letters
+	var art, letters int
+	for i, b := range nfo {
+		if b < 0x80 {
+			continue
+		}
+
+		var prev, next byte = ' ', ' '
+		if i > 0 {
+			prev = nfo[i-1]
+		}
+		if i < len(nfo)-1 {
+			next = nfo[i+1]
+		}
+
+		isArt := (b >= 0xb0 && b <= 0xdf) || b == 0xfe
+		if isArt && (prev >= 0x80 || next >= 0x80 || prev == ' ' || next == ' ') {
+			art++
+		} else if isLetter(prev) || isLetter(next) {
+			letters++
+		}
+	}
+
+	if letters > art {
+		return CharsetWindows1252
+	}
+
+	return CharsetCP437
+
+}
+
+// DecodeNFO converts an nfo file to UTF-8. The charset is detected if it is CharsetAuto
+func DecodeNFO(nfo []byte, charset Charset) string {
+
+	if charset == CharsetAuto {
+		charset = DetectCharset(nfo)
+	}
+
+	var enc encoding.Encoding
+	switch charset {
+	case CharsetUTF8:
+		return string(bytes.TrimPrefix(nfo, bomUTF8))
+	case CharsetUTF16LE:
+		enc = unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)
+	case CharsetUTF16BE:
+		enc = unicode.UTF16(unicode.BigEndian, unicode.UseBOM)
+	case CharsetCP866:
+		enc = charmap.CodePage866
+	case CharsetWindows1252:
+		enc = charmap.Windows1252
+	default:
+		enc = charmap.CodePage437
+	}
+
+	decoded, err := enc.NewDecoder().Bytes(nfo)
+	if err != nil {
+		return string(nfo)
+	}
+	return string(decoded)
+
+}
+
+func isLetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
@@ -0,0 +1,121 @@
+package description
+
+import (
+	"testing"
+)
+
+var nfoTests = []struct {
+	name    string
+	nfo     []byte
+	charset Charset
+	decoded string
+}{
+	{
+		name:    "ascii",
+		nfo:     []byte("Release.Name-GRP\r\nSize: 1.4 GB"),
+		charset: CharsetCP437,
+		decoded: "Release.Name-GRP\r\nSize: 1.4 GB",
+	},
+	{
+		name:    "utf-8 bom",
+		nfo:     []byte("\xef\xbb\xbfRelease \xe2\x96\x91\xe2\x96\x92\xe2\x96\x93"),
+		charset: CharsetUTF8,
+		decoded: "Release ░▒▓",
+	},
+	{
+		name:    "utf-16le bom",
+		nfo:     []byte("\xff\xfeN\x00F\x00O\x00 \x00\x54\x25"),
+		charset: CharsetUTF16LE,
+		decoded: "NFO ╔",
+	},
+	{
+		name:    "utf-16be bom",
+		nfo:     []byte("\xfe\xff\x00N\x00F\x00O\x00 \x25\x54"),
+		charset: CharsetUTF16BE,
+		decoded: "NFO ╔",
+	},
+	{
+		name:    "utf-8",
+		nfo:     []byte("Gr\xc3\xb6\xc3\x9fe: 1.4 GB\n\xe2\x96\x88\xe2\x96\x93"),
+		charset: CharsetUTF8,
+		decoded: "Größe: 1.4 GB\n█▓",
+	},
+	{
+		name:    "cp437 box",
+		nfo:     []byte("\xc9\xcd\xcd\xcd\xcd\xbb\n\xba GRP \xba\n\xc8\xcd\xcd\xcd\xcd\xbc"),
+		charset: CharsetCP437,
+		decoded: "╔════╗\n║ GRP ║\n╚════╝",
+	},
+	{
+		name:    "cp437 blocks",
+		nfo:     []byte("\xdb\xdb\xb2\xb1\xb0 \xdf\xdc\xfe"),
+		charset: CharsetCP437,
+		decoded: "██▓▒░ ▀▄■",
+	},
+	{
+		name:    "cp437 art outweighs accented letters",
+		nfo:     []byte("\xdb\xdb\xdb Caf\xe9 \xdb\xdb\xdb"),
+		charset: CharsetCP437,
+		decoded: "███ CafΘ ███",
+	},
+	{
+		name:    "windows-1252",
+		nfo:     []byte("Caf\xe9 cr\xe8me br\xfbl\xe9e"),
+		charset: CharsetWindows1252,
+		decoded: "Café crème brûlée",
+	},
+	{
+		name:    "windows-1252 with a box-drawing byte in a word",
+		nfo:     []byte("\xc9t\xe9 en fran\xe7ais"),
+		charset: CharsetWindows1252,
+		decoded: "Été en français",
+	},
+}
+
+func TestDetectCharset(t *testing.T) {
+
+	for _, tt := range nfoTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if charset := DetectCharset(tt.nfo); charset != tt.charset {
+				t.Errorf("expected %s, got %s", tt.charset, charset)
+			}
+		})
+	}
+
+}
+
+func TestDecodeNFO(t *testing.T) {
+
+	for _, tt := range nfoTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if decoded := DecodeNFO(tt.nfo, CharsetAuto); decoded != tt.decoded {
+				t.Errorf("expected %q, got %q", tt.decoded, decoded)
+			}
+		})
+	}
+
+}
+
+func TestDecodeNFO_Override(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		nfo     []byte
+		charset Charset
+		decoded string
+	}{
+		{"cp437 as windows-1252", []byte("\xc9\xcd\xbb"), CharsetWindows1252, "ÉÍ»"},
+		{"windows-1252 as cp437", []byte("Caf\xe9"), CharsetCP437, "CafΘ"},
+		{"cp866", []byte("\x8f\xe0\xa8\xa2\xa5\xe2 \xc9\xcd\xbb"), CharsetCP866, "Привет ╔═╗"},
+		{"utf-8 bom is stripped", []byte("\xef\xbb\xbfNFO"), CharsetUTF8, "NFO"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if decoded := DecodeNFO(tt.nfo, tt.charset); decoded != tt.decoded {
+				t.Errorf("expected %q, got %q", tt.decoded, decoded)
+			}
+		})
+	}
+
+}
@@ -0,0 +1,486 @@
+package destination
+
+import (
+	"atus/backend/category"
+	"atus/backend/helpers"
+	"atus/backend/release"
+	"atus/backend/sqlite"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Type is the upload protocol a destination speaks
+type Type string
+
+const (
+	TypeATUS    Type = "ATUS"    // atus-tracker-api plugin
+	TypeUNIT3D  Type = "UNIT3D"  // UNIT3D /api/torrents/upload
+	TypeGazelle Type = "GAZELLE" // Gazelle upload.php form
+)
+
+// Destination is a tracker releases are uploaded to
+type Destination struct {
+	UID     string
+	Name    string
+	Enabled bool
+	Type    Type
+
+	// URL of the atus-tracker-api plugin, the UNIT3D api endpoint or the Gazelle upload.php
+	APIURL string
+
+	// key used to authenticate at the tracker. Not used by TypeATUS
+	TrackerAPIKey string
+
+	// upload releases anonymously if the tracker supports it
+	Anonymous bool
+
+	// token sent to the tracker. The tracker uses the same token to access files through the api
+	APIAuthToken string
+
+	// announce url written into the uploaded torrent, without passkey
+	TrackerAnnounceURL string
+
+	// announce url used to seed the release, with the bots passkey
+	UserAnnounceURL string
+
+	UserID    string
+	Comment   string
+	CreatedBy string
+
+	// CategoryMapping maps internal category names to the categories used by the tracker.
+	// Categories without a mapping are sent as they are
+	CategoryMapping map[category.Name]string
+
+	// AttributeMapping maps parsed release attributes to the ids used by the tracker
+	AttributeMapping *AttributeMapping
+
+	Filters *Filters
+
+	// DescriptionTemplate is a text/template used to build the torrent description
+	// An empty template uses description.DefaultTemplate
+	DescriptionTemplate string
+
+	// FileBaseURL is the public url screenshots and samples are linked with in descriptions
+	// (e.g. a reverse proxy on the tracker that forwards to /api/data/). Defaults to /api/data
+	FileBaseURL string
+
+	// SourceTag is written into the info dict of uploaded torrents (info.source), which gives the torrent
+	// an infohash that differs from the one on the source tracker. An empty tag uses the destinations name
+	SourceTag string
+
+	// RecreateTorrent uploads a new torrent created from the data on the fileserver instead of the rewritten
+	// source torrent. The piece length is chosen by PieceSizePolicy, DefaultPieceSizePolicy if empty
+	RecreateTorrent bool
+	PieceSizePolicy []*PieceSize
+
+	// ContactSheetField is the form field the contact sheet of the sample is attached as.
+	// Empty doesn't attach the contact sheet
+	ContactSheetField string
+
+	// DryRun runs the whole upload pipeline but writes the payload to disk instead of posting it
+	DryRun bool
+
+	SumUploads int64
+}
+
+// AttributeMapping maps attributes parsed from the release name (see release.ParseName) to tracker ids
+type AttributeMapping struct {
+	// Types maps release sources (e.g. BLURAY, WEB, HDTV) to the trackers type ids
+	Types map[string]string
+
+	// Resolutions maps resolutions (e.g. 1080p) to the trackers resolution ids
+	Resolutions map[string]string
+}
+
+// Filters decide which releases are uploaded to a destination
+type Filters struct {
+	// Categories that are uploaded. Empty = all categories
+	Categories []category.Name
+	Includes   []string
+	Excludes   []string
+	MaxSize    int64
+
+	// ids a release must have, e.g. only releases with an IMDb id. Empty = no ids required
+	ExternalIDs []release.ExternalIDType
+}
+
+func New() *Destination {
+	return &Destination{
+		UID:             sqlite.GenerateUID("destinations"),
+		Type:            TypeATUS,
+		APIAuthToken:    helpers.GetUUID(),
+		UserID:          "0",
+		CreatedBy:       "ATUS",
+		Comment:         "Torrent created by ATUS",
+		CategoryMapping: map[category.Name]string{},
+		AttributeMapping: &AttributeMapping{
+			Types:       map[string]string{},
+			Resolutions: map[string]string{},
+		},
+		Filters: &Filters{
+			Categories: []category.Name{},
+			Includes:   []string{},
+			Excludes:   []string{},
+		},
+	}
+}
+
+// GetAll returns all destinations from the database
+// Do NOT call this function directly, use atus.GetAllDestinations() instead
+func GetAll() ([]*Destination, error) {
+
+	rows, err := sqlite.Conn.Query(
+		`SELECT
+			uid,
+			name,
+			enabled,
+			type,
+			api_url,
+			tracker_api_key,
+			anonymous,
+			api_auth_token,
+			tracker_announce_url,
+			user_announce_url,
+			user_id,
+			comment,
+			created_by,
+			category_mapping,
+			attribute_mapping,
+			filters,
+			description_template,
+			file_base_url,
+			source_tag,
+			recreate_torrent,
+			piece_size_policy,
+			contact_sheet_field,
+			dry_run,
+			sum_uploads
+		FROM destinations
+		ORDER BY name ASC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var destinations []*Destination
+	for rows.Next() {
+		d := &Destination{}
+		var categoryMapping, attributeMapping, filters, pieceSizePolicy []byte
+
+		if err := rows.Scan(
+			&d.UID,
+			&d.Name,
+			&d.Enabled,
+			&d.Type,
+			&d.APIURL,
+			&d.TrackerAPIKey,
+			&d.Anonymous,
+			&d.APIAuthToken,
+			&d.TrackerAnnounceURL,
+			&d.UserAnnounceURL,
+			&d.UserID,
+			&d.Comment,
+			&d.CreatedBy,
+			&categoryMapping,
+			&attributeMapping,
+			&filters,
+			&d.DescriptionTemplate,
+			&d.FileBaseURL,
+			&d.SourceTag,
+			&d.RecreateTorrent,
+			&pieceSizePolicy,
+			&d.ContactSheetField,
+			&d.DryRun,
+			&d.SumUploads,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning destinations: %s", err)
+		}
+
+		if err := json.Unmarshal(categoryMapping, &d.CategoryMapping); err != nil {
+			return nil, fmt.Errorf("error unmarshalling category mapping: %s", err)
+		}
+
+		if err := json.Unmarshal(attributeMapping, &d.AttributeMapping); err != nil {
+			return nil, fmt.Errorf("error unmarshalling attribute mapping: %s", err)
+		}
+
+		if err := json.Unmarshal(filters, &d.Filters); err != nil {
+			return nil, fmt.Errorf("error unmarshalling filters: %s", err)
+		}
+
+		if err := json.Unmarshal(pieceSizePolicy, &d.PieceSizePolicy); err != nil {
+			return nil, fmt.Errorf("error unmarshalling piece size policy: %s", err)
+		}
+
+		if d.AttributeMapping == nil {
+			d.AttributeMapping = &AttributeMapping{}
+		}
+
+		if d.Filters == nil {
+			d.Filters = &Filters{}
+		}
+
+		destinations = append(destinations, d)
+	}
+
+	return destinations, nil
+
+}
+
+// Save saves the destination to the database
+// If the destination already exists, it will be updated
+func (d *Destination) Save() error {
+
+	if d.CategoryMapping == nil {
+		d.CategoryMapping = map[category.Name]string{}
+	}
+
+	if d.AttributeMapping == nil {
+		d.AttributeMapping = &AttributeMapping{}
+	}
+
+	if d.Filters == nil {
+		d.Filters = &Filters{}
+	}
+
+	if d.Type == "" {
+		d.Type = TypeATUS
+	}
+
+	categoryMapping, err := json.Marshal(d.CategoryMapping)
+	if err != nil {
+		return err
+	}
+
+	attributeMapping, err := json.Marshal(d.AttributeMapping)
+	if err != nil {
+		return err
+	}
+
+	filters, err := json.Marshal(d.Filters)
+	if err != nil {
+		return err
+	}
+
+	if d.PieceSizePolicy == nil {
+		d.PieceSizePolicy = []*PieceSize{}
+	}
+
+	pieceSizePolicy, err := json.Marshal(d.PieceSizePolicy)
+	if err != nil {
+		return err
+	}
+
+	_, err = sqlite.Conn.Exec(
+		`INSERT INTO destinations
+			(
+				uid,
+				name,
+				enabled,
+				type,
+				api_url,
+				tracker_api_key,
+				anonymous,
+				api_auth_token,
+				tracker_announce_url,
+				user_announce_url,
+				user_id,
+				comment,
+				created_by,
+				category_mapping,
+				attribute_mapping,
+				filters,
+				description_template,
+				file_base_url,
+				source_tag,
+				recreate_torrent,
+				piece_size_policy,
+				contact_sheet_field,
+				dry_run,
+				sum_uploads
+			) VALUES
+			(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(uid) DO UPDATE SET
+			name = ?,
+			enabled = ?,
+			type = ?,
+			api_url = ?,
+			tracker_api_key = ?,
+			anonymous = ?,
+			api_auth_token = ?,
+			tracker_announce_url = ?,
+			user_announce_url = ?,
+			user_id = ?,
+			comment = ?,
+			created_by = ?,
+			category_mapping = ?,
+			attribute_mapping = ?,
+			filters = ?,
+			description_template = ?,
+			file_base_url = ?,
+			source_tag = ?,
+			recreate_torrent = ?,
+			piece_size_policy = ?,
+			contact_sheet_field = ?,
+			dry_run = ?,
+			sum_uploads = ?`,
+		d.UID,
+		d.Name,
+		d.Enabled,
+		d.Type,
+		d.APIURL,
+		d.TrackerAPIKey,
+		d.Anonymous,
+		d.APIAuthToken,
+		d.TrackerAnnounceURL,
+		d.UserAnnounceURL,
+		d.UserID,
+		d.Comment,
+		d.CreatedBy,
+		categoryMapping,
+		attributeMapping,
+		filters,
+		d.DescriptionTemplate,
+		d.FileBaseURL,
+		d.SourceTag,
+		d.RecreateTorrent,
+		pieceSizePolicy,
+		d.ContactSheetField,
+		d.DryRun,
+		d.SumUploads,
+		d.Name,
+		d.Enabled,
+		d.Type,
+		d.APIURL,
+		d.TrackerAPIKey,
+		d.Anonymous,
+		d.APIAuthToken,
+		d.TrackerAnnounceURL,
+		d.UserAnnounceURL,
+		d.UserID,
+		d.Comment,
+		d.CreatedBy,
+		categoryMapping,
+		attributeMapping,
+		filters,
+		d.DescriptionTemplate,
+		d.FileBaseURL,
+		d.SourceTag,
+		d.RecreateTorrent,
+		pieceSizePolicy,
+		d.ContactSheetField,
+		d.DryRun,
+		d.SumUploads,
+	)
+
+	return err
+
+}
+
+// Delete deletes the destination and its upload states from the database
+// Do NOT call this function directly, use atus.DeleteDestination() instead
+func (d *Destination) Delete() error {
+
+	if _, err := sqlite.Conn.Exec(`DELETE FROM destinations WHERE uid = ?`, d.UID); err != nil {
+		return err
+	}
+
+	_, err := sqlite.Conn.Exec(`DELETE FROM release_uploads WHERE destination_uid = ?`, d.UID)
+	return err
+
+}
+
+// GetCategory returns the trackers category for the given internal category
+func (d *Destination) GetCategory(name category.Name) string {
+	if c, ok := d.CategoryMapping[name]; ok && c != "" {
+		return c
+	}
+	return string(name)
+}
+
+// GetType returns the trackers type id for the release source
+func (d *Destination) GetType(source string) string {
+	if d.AttributeMapping == nil {
+		return ""
+	}
+	return d.AttributeMapping.Types[source]
+}
+
+// GetResolution returns the trackers resolution id for the resolution
+func (d *Destination) GetResolution(resolution string) string {
+	if d.AttributeMapping == nil {
+		return ""
+	}
+	return d.AttributeMapping.Resolutions[resolution]
+}
+
+// Accepts checks if a release passes the destinations filters
+func (d *Destination) Accepts(categoryName category.Name, rlsName string, rlsSize int64, externalIDs *release.ExternalIDs) (bool, error) {
+
+	if d.Filters == nil {
+		return true, nil
+	}
+
+	if len(d.Filters.Categories) > 0 {
+		found := false
+		for _, c := range d.Filters.Categories {
+			if c == categoryName {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false, fmt.Errorf("category %s is not uploaded to this destination", categoryName)
+		}
+	}
+
+	if d.Filters.MaxSize > 0 && rlsSize > d.Filters.MaxSize {
+		return false, fmt.Errorf("release exceeds max size (%dGiB > %dGiB)", rlsSize/helpers.GiB, d.Filters.MaxSize/helpers.GiB)
+	}
+
+	for _, t := range d.Filters.ExternalIDs {
+		if externalIDs.Get(t) == "" {
+			return false, fmt.Errorf("release has no %s id", t)
+		}
+	}
+
+	lowerRlsName := strings.ToLower(rlsName)
+
+	for _, include := range d.Filters.Includes {
+		if strings.Contains(lowerRlsName, strings.ToLower(include)) {
+			return true, nil
+		}
+	}
+
+	for _, exclude := range d.Filters.Excludes {
+		if strings.Contains(lowerRlsName, strings.ToLower(exclude)) {
+			return false, fmt.Errorf("excluded by filter %s", exclude)
+		}
+	}
+
+	return true, nil
+
+}
+
+// IsAuthToken returns true if the token belongs to an enabled destination
+func IsAuthToken(token string) bool {
+
+	if token == "" {
+		return false
+	}
+
+	var found bool
+	err := sqlite.Conn.QueryRow(`SELECT 1 FROM destinations WHERE api_auth_token = ? AND enabled = 1 LIMIT 1`, token).Scan(&found)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false
+	}
+
+	return found
+
+}
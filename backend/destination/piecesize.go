@@ -0,0 +1,52 @@
+package destination
+
+import (
+	"atus/backend/helpers"
+	"sort"
+)
+
+// PieceSize is a rule of a piece size policy. Torrents with a total size up to MaxSize get PieceLength
+type PieceSize struct {
+	MaxSize     int64
+	PieceLength int64
+}
+
+// DefaultPieceSizePolicy is used if a destination has no policy. Torrents larger than the last rule get its piece length
+var DefaultPieceSizePolicy = []*PieceSize{
+	{MaxSize: 64 * helpers.MiB, PieceLength: 64 * helpers.KiB},
+	{MaxSize: 256 * helpers.MiB, PieceLength: 256 * helpers.KiB},
+	{MaxSize: 1 * helpers.GiB, PieceLength: 1 * helpers.MiB},
+	{MaxSize: 4 * helpers.GiB, PieceLength: 2 * helpers.MiB},
+	{MaxSize: 16 * helpers.GiB, PieceLength: 4 * helpers.MiB},
+	{MaxSize: 64 * helpers.GiB, PieceLength: 8 * helpers.MiB},
+	{MaxSize: 0, PieceLength: 16 * helpers.MiB},
+}
+
+// GetPieceLength returns the piece length the destinations policy assigns to a torrent of the given size.
+// Rules are checked in ascending order of MaxSize, a MaxSize of 0 matches all sizes.
+func (d *Destination) GetPieceLength(size int64) int64 {
+
+	policy := d.PieceSizePolicy
+	if len(policy) == 0 {
+		policy = DefaultPieceSizePolicy
+	}
+
+	rules := make([]*PieceSize, len(policy))
+	copy(rules, policy)
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].MaxSize == 0 || rules[j].MaxSize == 0 {
+			return rules[j].MaxSize == 0 && rules[i].MaxSize != 0
+		}
+		return rules[i].MaxSize < rules[j].MaxSize
+	})
+
+	for _, rule := range rules {
+		if rule.MaxSize == 0 || size <= rule.MaxSize {
+			return rule.PieceLength
+		}
+	}
+
+	return rules[len(rules)-1].PieceLength
+
+}
@@ -1,42 +1,175 @@
 package fileserver
 
 import (
+	"atus/backend/request"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 	"os"
 )
 
+// DownloadOptions are used to check a downloaded file before it is moved to its final path
+type DownloadOptions struct {
+	// ExpectedSize is the size of the file according to the torrent. 0 = unknown
+	ExpectedSize int64
+
+	// Verify is called with the completed temp file. Returning an error discards the file
+	Verify func(f *os.File) error
+}
+
+// FileURL returns the url DownloadFile requests a file of the torrent from.
+// The fileserver supports range requests, ffprobe and ffmpeg can read parts of the file without downloading it
+func (s *Fileserver) FileURL(hash string, index int) string {
+
+	u := *s.URL
+	v := u.Query()
+	v.Set("action", "downloadFile")
+	v.Set("hash", hash)
+	v.Set("index", fmt.Sprintf("%d", index))
+	u.RawQuery = v.Encode()
+
+	return u.String()
+
+}
+
 // DownloadFile downloads a file from the fileserver
-func (s *Fileserver) DownloadFile(index int, hash, savePath string) error {
+// The file is written to a temp file next to savePath and renamed once it is complete and verified.
+// If a previous download was interrupted, the download is resumed using a range request.
+func (s *Fileserver) DownloadFile(ctx context.Context, index int, hash, savePath string, opts *DownloadOptions) error {
 
-	out, err := os.Create(savePath)
+	if opts == nil {
+		opts = &DownloadOptions{}
+	}
+
+	tempPath := savePath + ".part"
+
+	out, err := os.OpenFile(tempPath, os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		return err
 	}
 	defer out.Close()
 
+	stat, err := out.Stat()
+	if err != nil {
+		return err
+	}
+
+	offset := stat.Size()
+
+	// the temp file is larger than the file we expect, start over
+	if opts.ExpectedSize > 0 && offset > opts.ExpectedSize {
+		offset = 0
+	}
+
+	// skip the request if the temp file is already complete but was never verified
+	if opts.ExpectedSize == 0 || offset < opts.ExpectedSize {
+		if err := s.downloadToFile(ctx, index, hash, out, offset); err != nil {
+			// keep the temp file so the download can be resumed
+			return err
+		}
+	}
+
+	if err := out.Sync(); err != nil {
+		return err
+	}
+
+	stat, err = out.Stat()
+	if err != nil {
+		return err
+	}
+
+	// the connection was closed before the file was complete, keep the temp file so the download can be resumed
+	if opts.ExpectedSize > 0 && stat.Size() < opts.ExpectedSize {
+		return fmt.Errorf("incomplete download. Expected: %d bytes, Got: %d bytes", opts.ExpectedSize, stat.Size())
+	}
+
+	// -- check file --------------------------------------------------------------------------------
+	if err := s.checkDownloadedFile(tempPath, opts); err != nil {
+		// the data is corrupt, resuming would not help
+		os.Remove(tempPath)
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tempPath, savePath)
+
+}
+
+// downloadToFile writes the file to out, starting at offset.
+// The fileserver answers 416 if the offset is the end of the file, the file is already complete then
+func (s *Fileserver) downloadToFile(ctx context.Context, index int, hash string, out *os.File, offset int64) error {
+
 	var query url.Values = map[string][]string{
 		"action": {"downloadFile"},
 		"hash":   {hash},
 		"index":  {fmt.Sprintf("%d", index)},
 	}
 
-	req, err := s.buildRequest(context.Background(), "GET", query, nil)
+	req, err := s.buildRequest(ctx, "GET", query, nil)
 	if err != nil {
 		return err
 	}
 
+	if offset > 0 {
+		req.Raw.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
 	resp, err := req.Do()
 	if err != nil {
+		var statusErr *request.StatusError
+		if offset > 0 && errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+			return nil
+		}
 		return err
 	}
 
 	defer resp.Body.Close()
 
-	_, err = io.Copy(out, resp.Body)
+	// the fileserver ignored the range request, start over
+	if resp.StatusCode != http.StatusPartialContent {
+		offset = 0
+	}
+
+	if err := out.Truncate(offset); err != nil {
+		return err
+	}
 
+	if _, err := out.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, resp.Body)
 	return err
 
 }
+
+func (s *Fileserver) checkDownloadedFile(tempPath string, opts *DownloadOptions) error {
+
+	f, err := os.Open(tempPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if opts.ExpectedSize > 0 && stat.Size() != opts.ExpectedSize {
+		return fmt.Errorf("size mismatch. Expected: %d, Got: %d", opts.ExpectedSize, stat.Size())
+	}
+
+	if opts.Verify != nil {
+		return opts.Verify(f)
+	}
+
+	return nil
+
+}
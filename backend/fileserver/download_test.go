@@ -0,0 +1,182 @@
+package fileserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestFileserver serves content like the fileserver. Range requests are ignored if ranges is false
+func newTestFileserver(t *testing.T, content []byte, ranges bool) (*Fileserver, *[]string) {
+
+	var rangeHeaders []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("action") != "downloadFile" {
+			http.NotFound(w, r)
+			return
+		}
+
+		rangeHeaders = append(rangeHeaders, r.Header.Get("Range"))
+
+		if !ranges {
+			r.Header.Del("Range")
+		}
+
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+	}))
+
+	t.Cleanup(ts.Close)
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Fileserver{URL: u}, &rangeHeaders
+
+}
+
+func TestDownloadFile(t *testing.T) {
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+
+	tests := []struct {
+		name           string
+		part           []byte // content of the temp file of a previous attempt, nil if there is none
+		ranges         bool
+		expectedSize   int64
+		verifyErr      error
+		expectedRanges []string // range headers of the requests
+		expectErr      bool
+		expectPart     bool // the temp file is kept
+	}{
+		{
+			name:           "new download",
+			ranges:         true,
+			expectedSize:   int64(len(content)),
+			expectedRanges: []string{""},
+		},
+		{
+			name:           "resume",
+			part:           content[:4000],
+			ranges:         true,
+			expectedSize:   int64(len(content)),
+			expectedRanges: []string{"bytes=4000-"},
+		},
+		{
+			name:           "resume of unknown size",
+			part:           content[:4000],
+			ranges:         true,
+			expectedRanges: []string{"bytes=4000-"},
+		},
+		{
+			name:           "range ignored by the server",
+			part:           []byte("corrupt"),
+			expectedSize:   int64(len(content)),
+			expectedRanges: []string{"bytes=7-"},
+		},
+		{
+			name:           "complete temp file of known size",
+			part:           content,
+			ranges:         true,
+			expectedSize:   int64(len(content)),
+			expectedRanges: nil,
+		},
+		{
+			name:           "complete temp file of unknown size",
+			part:           content,
+			ranges:         true,
+			expectedRanges: []string{"bytes=10000-"},
+		},
+		{
+			name:           "temp file larger than expected",
+			part:           append(append([]byte{}, content...), "garbage"...),
+			ranges:         true,
+			expectedSize:   int64(len(content)),
+			expectedRanges: []string{""},
+		},
+		{
+			name:           "verification failed",
+			ranges:         true,
+			verifyErr:      errors.New("hash mismatch"),
+			expectedRanges: []string{""},
+			expectErr:      true,
+		},
+		{
+			name:           "incomplete download",
+			ranges:         true,
+			expectedSize:   int64(len(content)) + 10,
+			expectedRanges: []string{""},
+			expectErr:      true,
+			expectPart:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, rangeHeaders := newTestFileserver(t, content, tt.ranges)
+
+			savePath := filepath.Join(t.TempDir(), "file.mkv")
+			if tt.part != nil {
+				if err := os.WriteFile(savePath+".part", tt.part, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var verified bool
+			err := s.DownloadFile(context.Background(), 0, "hash", savePath, &DownloadOptions{
+				ExpectedSize: tt.expectedSize,
+				Verify: func(f *os.File) error {
+					verified = true
+					return tt.verifyErr
+				},
+			})
+
+			if tt.expectErr != (err != nil) {
+				t.Fatalf("expected error: %t, got %v", tt.expectErr, err)
+			}
+
+			if len(*rangeHeaders) != len(tt.expectedRanges) {
+				t.Fatalf("expected range headers %q, got %q", tt.expectedRanges, *rangeHeaders)
+			}
+
+			for i := range tt.expectedRanges {
+				if (*rangeHeaders)[i] != tt.expectedRanges[i] {
+					t.Errorf("expected range headers %q, got %q", tt.expectedRanges, *rangeHeaders)
+				}
+			}
+
+			if _, err := os.Stat(savePath + ".part"); tt.expectPart != (err == nil) {
+				t.Errorf("expected temp file: %t, got %v", tt.expectPart, err)
+			}
+
+			if tt.expectErr {
+				if _, err := os.Stat(savePath); err == nil {
+					t.Error("expected no file after a failed download")
+				}
+				return
+			}
+
+			if !verified {
+				t.Error("expected the file to be verified")
+			}
+
+			buf, err := os.ReadFile(savePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(buf, content) {
+				t.Errorf("expected %d bytes of content, got %d bytes", len(content), len(buf))
+			}
+		})
+	}
+
+}
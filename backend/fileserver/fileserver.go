@@ -1,10 +1,12 @@
 package fileserver
 
 import (
+	"atus/backend/category"
 	"atus/backend/helpers"
 	"atus/backend/request"
 	"atus/backend/sqlite"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/url"
@@ -21,6 +23,20 @@ type Fileserver struct {
 	Statistics         *Statistics
 	StatisticsInterval time.Duration
 	MinFreeDiskSpace   int64
+
+	// Weight is used by the WEIGHTED allocation method. A server with a weight of 2 will receive
+	// twice as many releases as a server with a weight of 1
+	Weight int64
+
+	// MaxConcurrentDownloads limits the number of releases downloading at the same time. 0 = unlimited
+	MaxConcurrentDownloads int64
+
+	// MaxServerLoad is the 1 minute load average above which no new releases are assigned. 0 = disabled
+	MaxServerLoad float64
+
+	// Categories pins categories to this fileserver.
+	// If set, the server will only receive releases of the given categories
+	Categories []category.Name
 }
 
 func New(u *url.URL) *Fileserver {
@@ -30,6 +46,8 @@ func New(u *url.URL) *Fileserver {
 		ListInterval:       time.Second * 5,
 		StatisticsInterval: time.Second * 10,
 		MinFreeDiskSpace:   25 * helpers.GiB,
+		Weight:             1,
+		Categories:         []category.Name{},
 	}
 }
 
@@ -68,7 +86,11 @@ func GetAll() ([]*Fileserver, error) {
 			list_interval,
 			statistics_interval,
 			sum_files_downloaded,
-			min_free_disk_space
+			min_free_disk_space,
+			weight,
+			max_concurrent_downloads,
+			max_server_load,
+			categories
 		FROM fileservers
 		ORDER BY name ASC`)
 
@@ -81,6 +103,7 @@ func GetAll() ([]*Fileserver, error) {
 	for rows.Next() {
 		s := &Fileserver{}
 		var urlRaw string
+		var categoriesRaw []byte
 
 		err := rows.Scan(
 			&s.UID,
@@ -91,12 +114,20 @@ func GetAll() ([]*Fileserver, error) {
 			&s.StatisticsInterval,
 			&s.SumFilesDownloaded,
 			&s.MinFreeDiskSpace,
+			&s.Weight,
+			&s.MaxConcurrentDownloads,
+			&s.MaxServerLoad,
+			&categoriesRaw,
 		)
 
 		if err != nil {
 			return nil, fmt.Errorf("error scanning sources: %s", err)
 		}
 
+		if err := json.Unmarshal(categoriesRaw, &s.Categories); err != nil {
+			return nil, fmt.Errorf("error unmarshalling categories: %s", err)
+		}
+
 		u, err := url.Parse(urlRaw)
 		if err != nil {
 			return nil, fmt.Errorf("error parsing url: %s", err)
@@ -129,7 +160,16 @@ func (s *Fileserver) Delete() error {
 
 func (s *Fileserver) Save() error {
 
-	_, err := sqlite.Conn.Exec(
+	if s.Categories == nil {
+		s.Categories = []category.Name{}
+	}
+
+	categories, err := json.Marshal(s.Categories)
+	if err != nil {
+		return err
+	}
+
+	_, err = sqlite.Conn.Exec(
 		`INSERT INTO fileservers
 			(
 				uid,
@@ -138,9 +178,13 @@ func (s *Fileserver) Save() error {
 				list_interval,
 				statistics_interval,
 				sum_files_downloaded,
-				min_free_disk_space
+				min_free_disk_space,
+				weight,
+				max_concurrent_downloads,
+				max_server_load,
+				categories
 			) VALUES
-			(?, ?, ?, ?, ?, ?, ?)
+			(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(uid) DO UPDATE SET
 			name = ?,
 			url = ?,
@@ -148,6 +192,10 @@ func (s *Fileserver) Save() error {
 			statistics_interval = ?,
 			sum_files_downloaded = ?,
 			min_free_disk_space = ?,
+			weight = ?,
+			max_concurrent_downloads = ?,
+			max_server_load = ?,
+			categories = ?,
 			enabled = ?`,
 		s.UID,
 		s.Name,
@@ -156,12 +204,20 @@ func (s *Fileserver) Save() error {
 		s.StatisticsInterval,
 		s.SumFilesDownloaded,
 		s.MinFreeDiskSpace,
+		s.Weight,
+		s.MaxConcurrentDownloads,
+		s.MaxServerLoad,
+		categories,
 		s.Name,
 		s.URL.String(),
 		s.ListInterval,
 		s.StatisticsInterval,
 		s.SumFilesDownloaded,
 		s.MinFreeDiskSpace,
+		s.Weight,
+		s.MaxConcurrentDownloads,
+		s.MaxServerLoad,
+		categories,
 		s.Enabled,
 	)
 
@@ -0,0 +1,58 @@
+package fileserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// PieceHashes is the file list and the v1 piece hashes of a torrents data on the fileserver
+type PieceHashes struct {
+	Files []*HashedFile
+
+	// concatenated SHA-1 hashes of all pieces (base64 encoded in the response)
+	Pieces []byte
+}
+
+type HashedFile struct {
+	Path   []string
+	Length int64
+}
+
+// HashPieces asks the fileserver to hash the downloaded data of the torrent with the given piece length.
+// Files are hashed in the order of the torrents file list. The fileserver responds once all pieces are hashed,
+// large releases can take several minutes.
+func (s *Fileserver) HashPieces(ctx context.Context, hash string, pieceLength int64) (*PieceHashes, error) {
+
+	var query url.Values = map[string][]string{
+		"action":      {"hashPieces"},
+		"hash":        {hash},
+		"pieceLength": {fmt.Sprintf("%d", pieceLength)},
+	}
+
+	req, err := s.buildRequest(ctx, "GET", query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := req.Do()
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	var r *PieceHashes
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("failed to decode piece hashes: %s", err.Error())
+	}
+
+	if r == nil {
+		return nil, errors.New("empty response")
+	}
+
+	return r, nil
+
+}
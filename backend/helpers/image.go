@@ -28,6 +28,37 @@ func CreateSquareImage(size int) *Image {
 
 }
 
+// CreateImage returns an image of the given size filled with bg
+func CreateImage(width, height int, bg color.Color) *Image {
+
+	img := &Image{
+		image.NewRGBA(image.Rect(0, 0, width, height)),
+	}
+
+	draw.Draw(img, img.Bounds(), image.NewUniform(bg), image.Point{0, 0}, draw.Src)
+
+	return img
+
+}
+
+// AddText draws text with its top left corner at x, y. Every character is 8x16 pixels
+func (img *Image) AddText(text string, c color.RGBA, x, y int) {
+
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: inconsolata.Bold8x16,
+	}
+
+	d.Dot = fixed.Point26_6{
+		X: fixed.I(x),
+		Y: fixed.I(y) + d.Face.Metrics().Ascent,
+	}
+
+	d.DrawString(text)
+
+}
+
 func (img *Image) AddLabel(label string, c color.RGBA, offsetY int) {
 
 	imageWidth := img.Bounds().Max.X
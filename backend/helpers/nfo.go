@@ -0,0 +1,118 @@
+package helpers
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+const (
+	// every character of an nfo is a cell of the DOS 8x16 text mode
+	nfoCellWidth  = 8
+	nfoCellHeight = 16
+
+	// larger nfos are cut off, the image would get too large for trackers
+	maxNFOColumns = 200
+	maxNFOLines   = 1000
+)
+
+// the glyphs of the CP437 control codes, charmap.CodePage437 decodes them as ASCII control characters
+const cp437ControlGlyphs = "\x00☺☻♥♦♣♠•◘○◙♂♀♪♫☼►◄↕‼¶§▬↨↑↓→←∟↔▲▼"
+
+// nfoFontIndex maps runes to their glyph in nfoFont
+var nfoFontIndex = func() map[rune]byte {
+
+	index := make(map[rune]byte, 256)
+	for i, r := range []rune(cp437ControlGlyphs) {
+		index[r] = byte(i)
+	}
+
+	for b := 0x20; b < 0x100; b++ {
+		index[charmap.CodePage437.DecodeByte(byte(b))] = byte(b)
+	}
+
+	// a house in the VGA font, DEL in CP437
+	index['⌂'] = 0x7f
+
+	return index
+
+}()
+
+// RenderNFO renders a decoded nfo like a DOS terminal would, every character is drawn with the VGA font.
+// Characters that are not part of CP437 are drawn as '?'
+func RenderNFO(nfo string, fg, bg color.RGBA) *Image {
+
+	lines := strings.Split(strings.ReplaceAll(nfo, "\r", ""), "\n")
+
+	// drop trailing empty lines
+	for len(lines) > 1 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	if len(lines) > maxNFOLines {
+		lines = lines[:maxNFOLines]
+	}
+
+	grid := make([][]rune, len(lines))
+	columns := 1
+	for i, line := range lines {
+		row := []rune(strings.ReplaceAll(line, "\t", "        "))
+		if len(row) > maxNFOColumns {
+			row = row[:maxNFOColumns]
+		}
+
+		grid[i] = row
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+
+	// one cell of padding on every side
+	img := CreateImage((columns+2)*nfoCellWidth, (len(grid)+2)*nfoCellHeight, bg)
+
+	for y, row := range grid {
+		for x, r := range row {
+			if r == ' ' {
+				continue
+			}
+
+			img.drawNFOGlyph(r, (x+1)*nfoCellWidth, (y+1)*nfoCellHeight, fg)
+		}
+	}
+
+	return img
+
+}
+
+// EncodePNG returns the image as png
+func (img *Image) EncodePNG() ([]byte, error) {
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+
+}
+
+// drawNFOGlyph draws the glyph of r into the cell at x, y
+func (img *Image) drawNFOGlyph(r rune, x, y int, c color.RGBA) {
+
+	code, ok := nfoFontIndex[r]
+	if !ok {
+		code = '?'
+	}
+
+	for py, row := range nfoFont[code] {
+		for px := 0; px < nfoCellWidth; px++ {
+			if row&(0x80>>px) != 0 {
+				img.SetRGBA(x+px, y+py, c)
+			}
+		}
+	}
+
+}
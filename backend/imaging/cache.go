@@ -0,0 +1,183 @@
+package imaging
+
+import (
+	"atus/backend/config"
+	"atus/backend/logger"
+	"atus/backend/video"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	_ "golang.org/x/image/webp"
+)
+
+// extensions of the files that can be resized
+var imageExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".webp": true,
+}
+
+// limits the number of images that are decoded and encoded at the same time,
+// the browse page requests a lot of thumbnails at once
+var workers = make(chan struct{}, runtime.NumCPU())
+
+// images with more pixels are not decoded, a decoded image takes 4 bytes per pixel
+const maxSourcePixels = 64 * 1024 * 1024
+
+// ErrImageTooLarge is returned by Get if the original image has more than maxSourcePixels
+var ErrImageTooLarge = errors.New("image is too large to be resized")
+
+// IsImage returns true if the file can be resized
+func IsImage(file string) bool {
+	return imageExtensions[strings.ToLower(filepath.Ext(file))]
+}
+
+// Get returns the path of the resized image for a file in the data folder and the format it was encoded with.
+// Resized images are cached per release folder, the cache key contains the
+// modification time of the original so changed files are resized again
+func Get(ctx context.Context, relPath string, o *Options) (string, Format, error) {
+
+	file := path.Join(config.Base.Folders.Data, relPath)
+
+	stat, err := os.Stat(file)
+	if err != nil {
+		return "", "", err
+	}
+
+	// webp is encoded by ffmpeg, fall back to jpeg if it is not installed or was built without libwebp
+	format := o.Format
+	if format == FormatWebP && !video.WebPSupported() {
+		format = FormatJPEG
+	}
+
+	cacheFile := getCacheFile(relPath, stat, o, format)
+	if _, err := os.Stat(cacheFile); err == nil {
+		return cacheFile, format, nil
+	}
+
+	select {
+	case workers <- struct{}{}:
+		defer func() { <-workers }()
+	case <-ctx.Done():
+		return "", "", ctx.Err()
+	}
+
+	buf, encoded, err := resizeFile(ctx, file, o, format)
+	if err != nil {
+		return "", "", err
+	}
+
+	if encoded != format {
+		format = encoded
+		cacheFile = getCacheFile(relPath, stat, o, format)
+	}
+
+	if err := os.MkdirAll(path.Dir(cacheFile), 0755); err != nil {
+		return "", "", err
+	}
+
+	// write to a temp file first, concurrent requests for the same image must not read a partial file
+	tmp, err := os.CreateTemp(path.Dir(cacheFile), "tmp-*")
+	if err != nil {
+		return "", "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		return "", "", err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return "", "", err
+	}
+
+	if err := os.Rename(tmp.Name(), cacheFile); err != nil {
+		return "", "", err
+	}
+
+	return cacheFile, format, nil
+
+}
+
+// RemoveCache deletes all resized images of a data folder, e.g. a release
+func RemoveCache(dir string) error {
+	return os.RemoveAll(cacheDir(dir))
+}
+
+func cacheDir(dir string) string {
+	return path.Join(config.Base.Folders.Cache, "images", dir)
+}
+
+// getCacheFile returns the path of the resized image in the cache
+func getCacheFile(relPath string, stat os.FileInfo, o *Options, format Format) string {
+	h := sha1.Sum([]byte(fmt.Sprintf("%s|%d|%d|%s|%s", relPath, stat.Size(), stat.ModTime().UnixNano(), o.Key(), format)))
+	return path.Join(cacheDir(path.Dir(relPath)), hex.EncodeToString(h[:])+format.Extension())
+}
+
+// resizeFile returns the resized image and the format it was encoded with.
+// Images that fail to encode as webp are encoded as jpeg, later requests try webp again
+func resizeFile(ctx context.Context, file string, o *Options, format Format) ([]byte, Format, error) {
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	// the header is read first, the size of the decoded image is only known afterwards
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if int64(cfg.Width)*int64(cfg.Height) > maxSourcePixels {
+		return nil, "", ErrImageTooLarge
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, "", err
+	}
+
+	img = Resize(img, o)
+
+	if format == FormatWebP {
+		buf, err := video.EncodeWebP(ctx, img, o.Quality)
+		if err == nil {
+			return buf, FormatWebP, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, "", ctx.Err()
+		}
+
+		logger.Warningf("error encoding %s as webp, serving jpeg: %s", file, err)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: o.Quality}); err != nil {
+		return nil, "", err
+	}
+
+	return buf.Bytes(), FormatJPEG, nil
+
+}
@@ -0,0 +1,136 @@
+package imaging
+
+import (
+	"atus/backend/config"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// images are never resized to more than this width or height
+const maxDimension = 3840
+
+// Fit defines how an image is resized if both width and height are set
+type Fit string
+
+const (
+	FitContain Fit = "contain" // scale to fit into the box, keeps the aspect ratio
+	FitCover   Fit = "cover"   // scale to fill the box and crop the overflow, keeps the aspect ratio
+	FitFill    Fit = "fill"    // stretch to the box
+)
+
+// Format is the output format of a resized image
+type Format string
+
+const (
+	FormatJPEG Format = "jpeg"
+	FormatWebP Format = "webp"
+)
+
+func (f Format) Extension() string {
+	if f == FormatWebP {
+		return ".webp"
+	}
+	return ".jpg"
+}
+
+func (f Format) MIME() string {
+	if f == FormatWebP {
+		return "image/webp"
+	}
+	return "image/jpeg"
+}
+
+// Options describe how an image is resized and encoded
+type Options struct {
+	Width   int // 0 keeps the aspect ratio
+	Height  int // 0 keeps the aspect ratio
+	Fit     Fit
+	Format  Format
+	Quality int // 1-100
+}
+
+// ParseOptions reads the options from the query params w, h, fit, format and q.
+// Returns nil if neither w nor h is set, the original image should be served in that case
+func ParseOptions(query url.Values) (*Options, error) {
+
+	if query.Get("w") == "" && query.Get("h") == "" {
+		return nil, nil
+	}
+
+	o := &Options{
+		Fit:    FitContain,
+		Format: FormatJPEG,
+	}
+
+	var err error
+	if o.Width, err = parseDimension(query.Get("w")); err != nil {
+		return nil, fmt.Errorf("invalid value for parameter 'w': %s", err)
+	}
+
+	if o.Height, err = parseDimension(query.Get("h")); err != nil {
+		return nil, fmt.Errorf("invalid value for parameter 'h': %s", err)
+	}
+
+	if q := strings.ToLower(query.Get("fit")); q != "" {
+		switch fit := Fit(q); fit {
+		case FitContain, FitCover, FitFill:
+			o.Fit = fit
+		default:
+			return nil, fmt.Errorf("invalid value for parameter 'fit'")
+		}
+	}
+
+	if q := strings.ToLower(query.Get("format")); q != "" {
+		switch format := Format(q); format {
+		case FormatJPEG, FormatWebP:
+			o.Format = format
+		case "jpg":
+			o.Format = FormatJPEG
+		default:
+			return nil, fmt.Errorf("invalid value for parameter 'format'")
+		}
+	}
+
+	if o.Format == FormatWebP {
+		o.Quality = int(config.GetInt64("IMAGES__WEBP_QUALITY"))
+	} else {
+		o.Quality = int(config.GetInt64("IMAGES__JPEG_QUALITY"))
+	}
+
+	if q := query.Get("q"); q != "" {
+		n, err := strconv.Atoi(q)
+		if err != nil || n < 1 || n > 100 {
+			return nil, fmt.Errorf("invalid value for parameter 'q'")
+		}
+		o.Quality = n
+	}
+
+	return o, nil
+
+}
+
+// Key identifies the options in cache file names
+func (o *Options) Key() string {
+	return fmt.Sprintf("%dx%d-%s-q%d%s", o.Width, o.Height, o.Fit, o.Quality, o.Format.Extension())
+}
+
+func parseDimension(s string) (int, error) {
+
+	if s == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+
+	if n < 1 || n > maxDimension {
+		return 0, fmt.Errorf("must be between 1 and %d", maxDimension)
+	}
+
+	return n, nil
+
+}
@@ -0,0 +1,68 @@
+package imaging
+
+import (
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// Resize scales img as set by the options. Images are never upscaled, FitFill shrinks each side on its own
+func Resize(img image.Image, o *Options) image.Image {
+
+	b := img.Bounds()
+	srcW, srcH := b.Dx(), b.Dy()
+
+	dstW, dstH := o.Width, o.Height
+	src := b
+
+	switch {
+	case dstW == 0:
+		dstW = srcW * dstH / srcH
+	case dstH == 0:
+		dstH = srcH * dstW / srcW
+	case o.Fit == FitContain:
+		// the side that has to shrink more defines the scale
+		if srcW*dstH > srcH*dstW {
+			dstH = srcH * dstW / srcW
+		} else {
+			dstW = srcW * dstH / srcH
+		}
+	case o.Fit == FitCover:
+		// crop the source to the aspect ratio of the box, centered
+		if srcW*dstH > srcH*dstW {
+			cropW := srcH * dstW / dstH
+			src.Min.X += (srcW - cropW) / 2
+			src.Max.X = src.Min.X + cropW
+		} else {
+			cropH := srcW * dstH / dstW
+			src.Min.Y += (srcH - cropH) / 2
+			src.Max.Y = src.Min.Y + cropH
+		}
+	}
+
+	// never upscale, the cropped source is used as is
+	if o.Fit == FitFill && o.Width > 0 && o.Height > 0 {
+		if dstW > src.Dx() {
+			dstW = src.Dx()
+		}
+		if dstH > src.Dy() {
+			dstH = src.Dy()
+		}
+	} else if dstW >= src.Dx() || dstH >= src.Dy() {
+		dstW, dstH = src.Dx(), src.Dy()
+	}
+
+	if dstW < 1 {
+		dstW = 1
+	}
+
+	if dstH < 1 {
+		dstH = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, src, draw.Src, nil)
+
+	return dst
+
+}
@@ -33,9 +33,10 @@ func new() *LogEntry {
 type RefType string
 
 const (
-	RefRelease    RefType = "RELEASE"
-	RefFileserver RefType = "FILESERVER"
-	RefSource     RefType = "SOURCE"
+	RefRelease     RefType = "RELEASE"
+	RefFileserver  RefType = "FILESERVER"
+	RefSource      RefType = "SOURCE"
+	RefDestination RefType = "DESTINATION"
 )
 
 func Ref(refType RefType, uid string) *LogEntry {
@@ -67,6 +68,7 @@ const (
 	TypeSample     LogType = "SAMPLE"
 	TypeRelease    LogType = "RELEASE"
 	TypeSource     LogType = "SOURCE"
+	TypeUpload     LogType = "UPLOAD"
 )
 
 func Type(logType LogType) *LogEntry {
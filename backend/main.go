@@ -88,7 +88,7 @@ func main() {
 		for _, c := range getClientsForReleaseUpadte(r.UID) {
 			c.MarshalAndSend("RELEASE__DETAILS__META_FILES", map[string]interface{}{
 				"uid":  r.UID,
-				"data": r.MetaFiles,
+				"data": r.GetMetaFiles(),
 			})
 		}
 	}
@@ -119,6 +119,12 @@ func main() {
 		}
 	}
 
+	atusInstance.OnSampleJobUpdated = func(j *atus.SampleJob) {
+		for _, c := range clientHub.GetClientsByPage("settings_samples", nil) {
+			c.MarshalAndSend("SETTINGS__SAMPLES_QUEUE__JOB", j)
+		}
+	}
+
 	logger.OnLog = func(le logger.LogEntry, severity logger.LogSeverity, message ...string) {
 		for _, client := range clientHub.GetClientsByPage("debug", nil) {
 			client.MarshalAndSend("DEBUG_ENTRY", le)
@@ -182,11 +188,22 @@ func main() {
 	// -- samples ---------------------------------
 	clientHub.SetEventHandler("SETTINGS__SAMPLES_MANAGE__GET_ALL", websocketEvents.Settings__SamplesManage_GetAll)
 	clientHub.SetEventHandler("SETTINGS__SAMPLES_MANAGE__SAVE", websocketEvents.Settings__SamplesManage_Save)
-
-	// -- upload ----------------------------------
-	clientHub.SetEventHandler("SETTINGS__UPLOAD__GET_ALL", websocketEvents.Settings__Upload_GetAll)
-	clientHub.SetEventHandler("SETTINGS__UPLOAD__SAVE", websocketEvents.Settings__Upload_Save)
-	clientHub.SetEventHandler("SETTINGS__UPLOAD__UPLOAD_TEST_TORRENT", websocketEvents.Settings__Upload_UploadTestTorrent)
+	clientHub.SetEventHandler("SETTINGS__SAMPLES_QUEUE__GET_ALL", websocketEvents.Settings__SamplesQueue_GetAll)
+	clientHub.SetEventHandler("SETTINGS__SAMPLES_QUEUE__RETRY", websocketEvents.Settings__SamplesQueue_Retry)
+	clientHub.SetEventHandler("SETTINGS__SAMPLES_QUEUE__CANCEL", websocketEvents.Settings__SamplesQueue_Cancel)
+
+	// -- metadata --------------------------------
+	clientHub.SetEventHandler("SETTINGS__METADATA_MANAGE__GET_ALL", websocketEvents.Settings__MetadataManage_GetAll)
+	clientHub.SetEventHandler("SETTINGS__METADATA_MANAGE__SAVE", websocketEvents.Settings__MetadataManage_Save)
+
+	// -- destinations ----------------------------
+	clientHub.SetEventHandler("SETTINGS__DESTINATIONS_MANAGE__GET_ALL", websocketEvents.Settings__DestinationsManage_GetAll)
+	clientHub.SetEventHandler("SETTINGS__DESTINATIONS_MANAGE__DELETE", websocketEvents.Settings__DestinationsManage_Delete)
+	clientHub.SetEventHandler("SETTINGS__DESTINATIONS_MANAGE__TOGGLE", websocketEvents.Settings__DestinationsManage_Toggle)
+	clientHub.SetEventHandler("SETTINGS__DESTINATIONS_EDIT__GET", websocketEvents.Settings__DestinationsEdit_Get)
+	clientHub.SetEventHandler("SETTINGS__DESTINATIONS_EDIT__SAVE", websocketEvents.Settings__DestinationsEdit_Save)
+	clientHub.SetEventHandler("SETTINGS__DESTINATIONS_EDIT__UPLOAD_TEST_TORRENT", websocketEvents.Settings__DestinationsEdit_UploadTestTorrent)
+	clientHub.SetEventHandler("SETTINGS__DESTINATIONS_EDIT__PREVIEW_DESCRIPTION", websocketEvents.Settings__DestinationsEdit_PreviewDescription)
 
 	// -- users -----------------------------------
 	clientHub.SetEventHandler("SETTINGS__USERS__GET", websocketEvents.Settings__Users_Get)
@@ -209,6 +226,10 @@ func main() {
 	clientHub.SetEventHandler("RELEASE__DETAILS__GET_FILES", websocketEvents.Release__GetFiles)
 	clientHub.SetEventHandler("RELEASE__DELETE", websocketEvents.Release__Delete)
 	clientHub.SetEventHandler("RELEASE__UPLOAD", websocketEvents.Release__Upload)
+	clientHub.SetEventHandler("RELEASE__UPLOAD_PREVIEW", websocketEvents.Release__GetUploadPreview)
+	clientHub.SetEventHandler("RELEASE__APPROVE", websocketEvents.Release__Approve)
+	clientHub.SetEventHandler("RELEASE__REJECT", websocketEvents.Release__Reject)
+	clientHub.SetEventHandler("RELEASE__SET_NFO_CHARSET", websocketEvents.Release__SetNFOCharset)
 
 	// --- log ------------------------------------
 
@@ -241,7 +262,8 @@ func main() {
 	apiSR.Use(routes.MiddlewareHeaders)
 	apiSR.Use(routes.MiddlewareAPIAuth)
 	apiSR.HandleFunc("/releases", routes.API__Releases(atusInstance)).Methods("GET")
-	apiSR.PathPrefix("/data/").HandlerFunc(routes.API__ServeFile)
+	// files are streamed, the data routes replace the write timeout of the server
+	apiSR.PathPrefix("/data/").Handler(routes.MiddlewareDataTimeouts(http.HandlerFunc(routes.API__ServeFile)))
 
 	// catch all
 	r.PathPrefix("/").HandlerFunc(routes.CatchAll)
@@ -261,6 +283,7 @@ func main() {
 		ReadHeaderTimeout: 5 * time.Second,
 		ReadTimeout:       5 * time.Second,
 		WriteTimeout:      5 * time.Second,
+		ConnContext:       routes.ConnContext,
 	}
 
 	logger.ForceConsole().Debugf("ATUS is listening on %s", listenAddr)
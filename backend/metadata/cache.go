@@ -0,0 +1,100 @@
+package metadata
+
+import (
+	"atus/backend/config"
+	"atus/backend/logger"
+	"atus/backend/release"
+	"atus/backend/sqlite"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// misses are kept for a day at most, new shows are added to the providers before they air
+const maxMissTTL = time.Hour * 24
+
+// getCacheTTL returns how long matches are cached. 0 disables the cache
+func getCacheTTL() time.Duration {
+	return time.Duration(config.GetInt64("METADATA__CACHE_TTL")) * time.Hour
+}
+
+func getMissTTL() time.Duration {
+	if ttl := getCacheTTL(); ttl < maxMissTTL {
+		return ttl
+	}
+	return maxMissTTL
+}
+
+// getCached returns a cached lookup. ok is false if the key isn't cached or expired, m is nil for cached misses
+func getCached(key string) (m *release.Metadata, ok bool) {
+
+	ttl := getCacheTTL()
+	if ttl <= 0 {
+		return nil, false
+	}
+
+	var data, addedRaw string
+	err := sqlite.Conn.QueryRow(`SELECT data, added FROM metadata_cache WHERE key = ?`, key).Scan(&data, &addedRaw)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			logger.Errorf("failed to read metadata cache: %s", err.Error())
+		}
+		return nil, false
+	}
+
+	added, err := time.Parse(time.RFC3339, addedRaw)
+	if err != nil {
+		return nil, false
+	}
+
+	if data == "" {
+		return nil, time.Since(added) < getMissTTL()
+	}
+
+	if time.Since(added) >= ttl {
+		return nil, false
+	}
+
+	m = &release.Metadata{}
+	if err := json.Unmarshal([]byte(data), m); err != nil {
+		return nil, false
+	}
+
+	return m, true
+
+}
+
+// setCached caches a lookup, pass nil to cache a miss. Expired entries are removed
+func setCached(key string, m *release.Metadata) {
+
+	ttl := getCacheTTL()
+	if ttl <= 0 {
+		return
+	}
+
+	var data string
+	if m != nil {
+		buf, err := json.Marshal(m)
+		if err != nil {
+			return
+		}
+		data = string(buf)
+	}
+
+	now := time.Now()
+
+	if _, err := sqlite.Conn.Exec(
+		`INSERT INTO metadata_cache (key, data, added)
+		VALUES (?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET data = excluded.data, added = excluded.added`,
+		key, data, now.Format(time.RFC3339),
+	); err != nil {
+		logger.Errorf("failed to write metadata cache: %s", err.Error())
+		return
+	}
+
+	if _, err := sqlite.Conn.Exec(`DELETE FROM metadata_cache WHERE added < ?`, now.Add(-ttl).Format(time.RFC3339)); err != nil {
+		logger.Errorf("failed to clean up metadata cache: %s", err.Error())
+	}
+
+}
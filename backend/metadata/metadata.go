@@ -0,0 +1,189 @@
+package metadata
+
+import (
+	"atus/backend/config"
+	"atus/backend/release"
+	"atus/backend/request"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/h2non/filetype"
+)
+
+// ErrNotFound is returned by providers that don't know the movie or show
+var ErrNotFound = errors.New("no metadata found")
+
+// Query describes the movie or show of a release. Providers prefer the external ids and fall back to the title
+type Query struct {
+	ExternalIDs *release.ExternalIDs
+	Title       string
+	Year        int  // 0 if unknown
+	TV          bool // the release is an episode or season of a show
+}
+
+// ids returns the external ids of the query, never nil
+func (q *Query) ids() *release.ExternalIDs {
+	if q.ExternalIDs == nil {
+		return &release.ExternalIDs{}
+	}
+	return q.ExternalIDs
+}
+
+// HasIDs returns true if the query has an external id. Matches of title searches can be wrong
+func (q *Query) HasIDs() bool {
+	ids := q.ids()
+	return ids.IMDb != "" || ids.TVmaze != "" || ids.TVDB != "" || ids.TMDB != ""
+}
+
+// empty returns true if the query has neither ids nor a title
+func (q *Query) empty() bool {
+	return !q.HasIDs() && q.Title == ""
+}
+
+// key identifies the query in the cache
+func (q *Query) key() string {
+	ids := q.ids()
+	return fmt.Sprintf("%s|%s|%s|%s/%s|%s|%d|%t", ids.IMDb, ids.TVmaze, ids.TVDB, ids.TMDBType, ids.TMDB, strings.ToLower(q.Title), q.Year, q.TV)
+}
+
+// Provider looks up movies and shows in an online database
+type Provider interface {
+	Name() string
+
+	// Supports returns false if the provider can't answer the query, e.g. movies on a tv database
+	Supports(q *Query) bool
+
+	// Lookup returns ErrNotFound if the provider doesn't know the movie or show
+	Lookup(ctx context.Context, q *Query) (*release.Metadata, error)
+}
+
+// Providers returns the enabled providers. TVmaze needs no api key and is asked first for shows
+func Providers() []Provider {
+
+	var providers []Provider
+
+	if config.GetBool("METADATA__TVMAZE_ENABLED") {
+		providers = append(providers, NewTVmaze())
+	}
+
+	if key := config.GetString("METADATA__TMDB_API_KEY"); key != "" {
+		providers = append(providers, NewTMDB(key, config.GetString("METADATA__LANGUAGE")))
+	}
+
+	return providers
+
+}
+
+// Lookup asks the providers in order and returns the first match.
+// Matches and misses of every provider are cached, see METADATA__CACHE_TTL
+func Lookup(ctx context.Context, providers []Provider, q *Query) (*release.Metadata, error) {
+
+	if q.empty() {
+		return nil, ErrNotFound
+	}
+
+	var errs []string
+	for _, p := range providers {
+		if !p.Supports(q) {
+			continue
+		}
+
+		key := p.Name() + "|" + q.key()
+		if m, ok := getCached(key); ok {
+			if m != nil {
+				return m, nil
+			}
+			continue
+		}
+
+		m, err := p.Lookup(ctx, q)
+		if errors.Is(err, ErrNotFound) {
+			setCached(key, nil)
+			continue
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", p.Name(), err.Error()))
+			continue
+		}
+
+		setCached(key, m)
+		return m, nil
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "; "))
+	}
+
+	return nil, ErrNotFound
+
+}
+
+// GetPoster downloads a poster. Returns the image and its extension (e.g. ".jpg")
+func GetPoster(ctx context.Context, posterURL string) ([]byte, string, error) {
+
+	req, err := request.NewWithContext(ctx, "GET", posterURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resp, err := req.Do()
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer resp.Body.Close()
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !filetype.IsImage(buf) {
+		return nil, "", errors.New("poster is not an image")
+	}
+
+	kind, err := filetype.Match(buf)
+	if err != nil {
+		return nil, "", fmt.Errorf("error getting filetype: %s", err)
+	}
+
+	return buf, "." + kind.Extension, nil
+
+}
+
+// getJSON decodes the json response of a provider into v. A 404 response is ErrNotFound
+func getJSON(ctx context.Context, url string, header http.Header, v interface{}) error {
+
+	req, err := request.NewWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	for k := range header {
+		req.Raw.Header.Set(k, header.Get(k))
+	}
+
+	resp, err := req.Do()
+	if err != nil {
+		var statusErr *request.StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("error unmarshalling json: %s", err)
+	}
+
+	return nil
+
+}
@@ -0,0 +1,257 @@
+package metadata
+
+import (
+	"atus/backend/release"
+	"atus/backend/sqlite"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+
+	// the cache and the config are read from sqlite
+	if err := sqlite.Connect(":memory:"); err != nil {
+		panic(err)
+	}
+
+	if err := sqlite.Prepare(); err != nil {
+		panic(err)
+	}
+
+	os.Exit(m.Run())
+
+}
+
+// newTestServer serves the json responses by path and query, all other requests are answered with 404
+func newTestServer(t *testing.T, responses map[string]string) (*httptest.Server, *int) {
+
+	requests := 0
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		query := r.URL.Query()
+		query.Del("api_key")
+		query.Del("language")
+
+		key := r.URL.Path
+		if len(query) > 0 {
+			key += "?" + query.Encode()
+		}
+
+		body, ok := responses[key]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+
+	t.Cleanup(s.Close)
+
+	return s, &requests
+
+}
+
+func newTestTMDB(s *httptest.Server) *TMDB {
+	tmdb := NewTMDB("key", "en-US")
+	tmdb.BaseURL = s.URL
+	tmdb.ImageBaseURL = "https://image.example"
+	return tmdb
+}
+
+func newTestTVmaze(s *httptest.Server) *TVmaze {
+	tvmaze := NewTVmaze()
+	tvmaze.BaseURL = s.URL
+	return tvmaze
+}
+
+const tmdbMatrix = `{
+	"id": 603,
+	"title": "The Matrix",
+	"release_date": "1999-03-30",
+	"overview": "Set in the 22nd century.",
+	"genres": [{"id": 28, "name": "Action"}, {"id": 878, "name": "Science Fiction"}],
+	"vote_average": 8.2,
+	"vote_count": 24000,
+	"runtime": 136,
+	"poster_path": "/matrix.jpg",
+	"external_ids": {"imdb_id": "tt0133093", "tvdb_id": null}
+}`
+
+func TestTMDBLookupByIMDbID(t *testing.T) {
+
+	s, _ := newTestServer(t, map[string]string{
+		"/find/tt0133093?external_source=imdb_id":    `{"movie_results": [{"id": 603}], "tv_results": []}`,
+		"/movie/603?append_to_response=external_ids": tmdbMatrix,
+	})
+
+	m, err := newTestTMDB(s).Lookup(context.Background(), &Query{
+		ExternalIDs: &release.ExternalIDs{IMDb: "tt0133093"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &release.Metadata{
+		Provider:  "TMDB",
+		ID:        "603",
+		URL:       "https://www.themoviedb.org/movie/603",
+		Title:     "The Matrix",
+		Year:      1999,
+		Plot:      "Set in the 22nd century.",
+		Genres:    []string{"Action", "Science Fiction"},
+		Rating:    8.2,
+		Votes:     24000,
+		Runtime:   136,
+		PosterURL: "https://image.example/matrix.jpg",
+		ExternalIDs: &release.ExternalIDs{
+			IMDb:     "tt0133093",
+			TMDB:     "603",
+			TMDBType: "movie",
+		},
+	}
+
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("expected %+v, got %+v", expected, m)
+	}
+
+}
+
+func TestTMDBLookupByTitle(t *testing.T) {
+
+	s, _ := newTestServer(t, map[string]string{
+		"/search/tv?first_air_date_year=2011&query=Game+of+Thrones": `{"results": [{"id": 1399}]}`,
+		"/tv/1399?append_to_response=external_ids": `{
+			"id": 1399,
+			"name": "Game of Thrones",
+			"first_air_date": "2011-04-17",
+			"episode_run_time": [60],
+			"genres": [{"name": "Drama"}],
+			"external_ids": {"imdb_id": "tt0944947", "tvdb_id": 121361}
+		}`,
+	})
+
+	m, err := newTestTMDB(s).Lookup(context.Background(), &Query{Title: "Game of Thrones", Year: 2011, TV: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Title != "Game of Thrones" || m.Year != 2011 || m.Runtime != 60 || m.URL != "https://www.themoviedb.org/tv/1399" {
+		t.Errorf("unexpected metadata %+v", m)
+	}
+
+	if m.ExternalIDs.TVDB != "121361" || m.ExternalIDs.TMDBType != "tv" {
+		t.Errorf("unexpected external ids %+v", m.ExternalIDs)
+	}
+
+	if m.PosterURL != "" {
+		t.Errorf("expected no poster, got %s", m.PosterURL)
+	}
+
+}
+
+func TestTVmazeLookup(t *testing.T) {
+
+	show := `{
+		"id": 82,
+		"url": "https://www.tvmaze.com/shows/82/game-of-thrones",
+		"name": "Game of Thrones",
+		"premiered": "2011-04-17",
+		"summary": "<p>Based on the bestselling book series <i>A Song of Ice and Fire</i> by George R.R. Martin &amp; more.</p>",
+		"genres": ["Drama", "Adventure", "Fantasy"],
+		"runtime": null,
+		"averageRuntime": 61,
+		"rating": {"average": 8.9},
+		"image": {"medium": "https://static.example/medium.jpg", "original": "https://static.example/original.jpg"},
+		"externals": {"tvrage": 24493, "thetvdb": 121361, "imdb": "tt0944947"}
+	}`
+
+	s, _ := newTestServer(t, map[string]string{
+		"/lookup/shows?imdb=tt0944947": show,
+		"/search/shows?q=Doctor+Who": `[
+			{"score": 0.9, "show": {"id": 210, "name": "Doctor Who", "premiered": "1963-11-23"}},
+			{"score": 0.8, "show": {"id": 766, "name": "Doctor Who", "premiered": "2005-03-26"}}
+		]`,
+	})
+
+	tvmaze := newTestTVmaze(s)
+
+	// the unknown tvmaze id falls back to the imdb id
+	m, err := tvmaze.Lookup(context.Background(), &Query{
+		ExternalIDs: &release.ExternalIDs{IMDb: "tt0944947", TVmaze: "999"},
+		TV:          true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.ID != "82" || m.Runtime != 61 || m.Rating != 8.9 || m.PosterURL != "https://static.example/original.jpg" {
+		t.Errorf("unexpected metadata %+v", m)
+	}
+
+	if expected := "Based on the bestselling book series A Song of Ice and Fire by George R.R. Martin & more."; m.Plot != expected {
+		t.Errorf("expected plot %q, got %q", expected, m.Plot)
+	}
+
+	if m.ExternalIDs.TVDB != "121361" || m.ExternalIDs.TVmaze != "82" {
+		t.Errorf("unexpected external ids %+v", m.ExternalIDs)
+	}
+
+	// the year of the release name picks the remake
+	m, err = tvmaze.Lookup(context.Background(), &Query{Title: "Doctor Who", Year: 2005, TV: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.ID != "766" {
+		t.Errorf("expected show 766, got %s", m.ID)
+	}
+
+	if _, err := tvmaze.Lookup(context.Background(), &Query{Title: "Doctor Who", Year: 2023, TV: true}); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if tvmaze.Supports(&Query{Title: "The Matrix"}) {
+		t.Error("expected tvmaze not to support movies")
+	}
+
+}
+
+func TestLookupCache(t *testing.T) {
+
+	tvmazeServer, tvmazeRequests := newTestServer(t, map[string]string{})
+	tmdbServer, tmdbRequests := newTestServer(t, map[string]string{
+		"/search/tv?query=Severance":                `{"results": [{"id": 95396}]}`,
+		"/tv/95396?append_to_response=external_ids": `{"id": 95396, "name": "Severance", "first_air_date": "2022-02-17"}`,
+	})
+
+	providers := []Provider{newTestTVmaze(tvmazeServer), newTestTMDB(tmdbServer)}
+	q := &Query{Title: "Severance", TV: true}
+
+	for i := 0; i < 2; i++ {
+		m, err := Lookup(context.Background(), providers, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if m.Provider != "TMDB" || m.Title != "Severance" {
+			t.Errorf("unexpected metadata %+v", m)
+		}
+	}
+
+	// the miss on tvmaze and the match on tmdb are cached
+	if *tvmazeRequests != 1 || *tmdbRequests != 2 {
+		t.Errorf("expected 1 tvmaze and 2 tmdb requests, got %d and %d", *tvmazeRequests, *tmdbRequests)
+	}
+
+	if _, err := Lookup(context.Background(), providers, &Query{TV: true}); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound for an empty query, got %v", err)
+	}
+
+}
@@ -0,0 +1,235 @@
+package metadata
+
+import (
+	"atus/backend/release"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	tmdbTypeMovie = "movie"
+	tmdbTypeTV    = "tv"
+)
+
+// TMDB looks up movies and shows on themoviedb.org
+type TMDB struct {
+	// v3 api key or v4 read access token
+	APIKey string
+
+	// e.g. en-US. Plots and titles are returned in this language if they are translated
+	Language string
+
+	BaseURL      string // e.g. https://api.themoviedb.org/3
+	ImageBaseURL string // e.g. https://image.tmdb.org/t/p/original
+	SiteURL      string // e.g. https://www.themoviedb.org
+}
+
+func NewTMDB(apiKey, language string) *TMDB {
+	return &TMDB{
+		APIKey:       apiKey,
+		Language:     language,
+		BaseURL:      "https://api.themoviedb.org/3",
+		ImageBaseURL: "https://image.tmdb.org/t/p/original",
+		SiteURL:      "https://www.themoviedb.org",
+	}
+}
+
+func (t *TMDB) Name() string {
+	return "TMDB"
+}
+
+func (t *TMDB) Supports(q *Query) bool {
+	return true
+}
+
+// Lookup finds the TMDB id by the TMDB, IMDb or TVDB id of the query, by title and year if it has none
+func (t *TMDB) Lookup(ctx context.Context, q *Query) (*release.Metadata, error) {
+
+	mediaType, id, err := t.findID(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.getDetails(ctx, mediaType, id)
+
+}
+
+func (t *TMDB) findID(ctx context.Context, q *Query) (string, string, error) {
+
+	mediaType := tmdbTypeMovie
+	if q.TV {
+		mediaType = tmdbTypeTV
+	}
+
+	ids := q.ids()
+	if ids.TMDB != "" {
+		if ids.TMDBType != "" {
+			mediaType = ids.TMDBType
+		}
+		return mediaType, ids.TMDB, nil
+	}
+
+	// ids of other databases are resolved with /find, the id can be a movie or a show
+	for _, ext := range []struct{ id, source string }{
+		{ids.IMDb, "imdb_id"},
+		{ids.TVDB, "tvdb_id"},
+	} {
+		if ext.id == "" {
+			continue
+		}
+
+		var resp struct {
+			MovieResults []struct{ ID int } `json:"movie_results"`
+			TVResults    []struct{ ID int } `json:"tv_results"`
+		}
+
+		err := t.get(ctx, "/find/"+url.PathEscape(ext.id), url.Values{"external_source": {ext.source}}, &resp)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return "", "", err
+		}
+
+		movies, shows := resp.MovieResults, resp.TVResults
+		switch {
+		case len(shows) > 0 && (q.TV || len(movies) == 0):
+			return tmdbTypeTV, strconv.Itoa(shows[0].ID), nil
+		case len(movies) > 0:
+			return tmdbTypeMovie, strconv.Itoa(movies[0].ID), nil
+		}
+	}
+
+	if q.Title == "" {
+		return "", "", ErrNotFound
+	}
+
+	query := url.Values{"query": {q.Title}}
+	if q.Year > 0 {
+		if mediaType == tmdbTypeTV {
+			query.Set("first_air_date_year", strconv.Itoa(q.Year))
+		} else {
+			query.Set("year", strconv.Itoa(q.Year))
+		}
+	}
+
+	var resp struct {
+		Results []struct{ ID int }
+	}
+
+	if err := t.get(ctx, "/search/"+mediaType, query, &resp); err != nil {
+		return "", "", err
+	}
+
+	if len(resp.Results) == 0 {
+		return "", "", ErrNotFound
+	}
+
+	return mediaType, strconv.Itoa(resp.Results[0].ID), nil
+
+}
+
+type tmdbDetails struct {
+	ID int `json:"id"`
+
+	// movies have a title and a release date, shows a name and a first air date
+	Title        string `json:"title"`
+	Name         string `json:"name"`
+	ReleaseDate  string `json:"release_date"`
+	FirstAirDate string `json:"first_air_date"`
+
+	Overview       string                  `json:"overview"`
+	Genres         []struct{ Name string } `json:"genres"`
+	VoteAverage    float64                 `json:"vote_average"`
+	VoteCount      int                     `json:"vote_count"`
+	Runtime        int                     `json:"runtime"`
+	EpisodeRunTime []int                   `json:"episode_run_time"`
+	PosterPath     string                  `json:"poster_path"`
+
+	ExternalIDs struct {
+		IMDbID string `json:"imdb_id"`
+		TVDBID int    `json:"tvdb_id"`
+	} `json:"external_ids"`
+}
+
+func (t *TMDB) getDetails(ctx context.Context, mediaType, id string) (*release.Metadata, error) {
+
+	if mediaType != tmdbTypeMovie && mediaType != tmdbTypeTV {
+		return nil, fmt.Errorf("unknown tmdb type: %s", mediaType)
+	}
+
+	var d tmdbDetails
+	if err := t.get(ctx, "/"+mediaType+"/"+url.PathEscape(id), url.Values{"append_to_response": {"external_ids"}}, &d); err != nil {
+		return nil, err
+	}
+
+	m := &release.Metadata{
+		Provider: t.Name(),
+		ID:       strconv.Itoa(d.ID),
+		URL:      fmt.Sprintf("%s/%s/%d", strings.TrimRight(t.SiteURL, "/"), mediaType, d.ID),
+		Title:    d.Title,
+		Year:     parseYear(d.ReleaseDate),
+		Plot:     strings.TrimSpace(d.Overview),
+		Genres:   []string{},
+		Rating:   d.VoteAverage,
+		Votes:    d.VoteCount,
+		Runtime:  d.Runtime,
+		ExternalIDs: &release.ExternalIDs{
+			IMDb:     d.ExternalIDs.IMDbID,
+			TMDB:     strconv.Itoa(d.ID),
+			TMDBType: mediaType,
+		},
+	}
+
+	if mediaType == tmdbTypeTV {
+		m.Title = d.Name
+		m.Year = parseYear(d.FirstAirDate)
+		if len(d.EpisodeRunTime) > 0 {
+			m.Runtime = d.EpisodeRunTime[0]
+		}
+	}
+
+	if d.ExternalIDs.TVDBID > 0 {
+		m.ExternalIDs.TVDB = strconv.Itoa(d.ExternalIDs.TVDBID)
+	}
+
+	for _, g := range d.Genres {
+		m.Genres = append(m.Genres, g.Name)
+	}
+
+	if d.PosterPath != "" {
+		m.PosterURL = strings.TrimRight(t.ImageBaseURL, "/") + d.PosterPath
+	}
+
+	return m, nil
+
+}
+
+// get requests an api endpoint. v4 read access tokens are sent as bearer token, v3 api keys as query param
+func (t *TMDB) get(ctx context.Context, endpoint string, query url.Values, v interface{}) error {
+
+	header := http.Header{}
+	if strings.Contains(t.APIKey, ".") {
+		header.Set("Authorization", "Bearer "+t.APIKey)
+	} else {
+		query.Set("api_key", t.APIKey)
+	}
+
+	if t.Language != "" {
+		query.Set("language", t.Language)
+	}
+
+	return getJSON(ctx, strings.TrimRight(t.BaseURL, "/")+endpoint+"?"+query.Encode(), header, v)
+
+}
+
+// parseYear returns the year of a date like 2022-05-31, 0 if the date is empty
+func parseYear(date string) int {
+	if len(date) < 4 {
+		return 0
+	}
+	year, _ := strconv.Atoi(date[:4])
+	return year
+}
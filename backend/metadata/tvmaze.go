@@ -0,0 +1,151 @@
+package metadata
+
+import (
+	"atus/backend/release"
+	"context"
+	"errors"
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var htmlTagRegExp = regexp.MustCompile(`<[^>]*>`)
+
+// TVmaze looks up shows on tvmaze.com. The api is free and needs no key
+type TVmaze struct {
+	BaseURL string // e.g. https://api.tvmaze.com
+}
+
+func NewTVmaze() *TVmaze {
+	return &TVmaze{
+		BaseURL: "https://api.tvmaze.com",
+	}
+}
+
+func (t *TVmaze) Name() string {
+	return "TVmaze"
+}
+
+// Supports returns false for movies, TVmaze only knows shows
+func (t *TVmaze) Supports(q *Query) bool {
+	return q.TV
+}
+
+type tvmazeShow struct {
+	ID             int      `json:"id"`
+	URL            string   `json:"url"`
+	Name           string   `json:"name"`
+	Premiered      string   `json:"premiered"`
+	Summary        string   `json:"summary"` // html
+	Genres         []string `json:"genres"`
+	Runtime        int      `json:"runtime"`
+	AverageRuntime int      `json:"averageRuntime"`
+	Rating         struct {
+		Average float64 `json:"average"`
+	} `json:"rating"`
+	Image *struct {
+		Medium   string `json:"medium"`
+		Original string `json:"original"`
+	} `json:"image"`
+	Externals struct {
+		TheTVDB int    `json:"thetvdb"`
+		IMDb    string `json:"imdb"`
+	} `json:"externals"`
+}
+
+// Lookup finds the show by the TVmaze, IMDb or TVDB id of the query, by title and year if none of them is known
+func (t *TVmaze) Lookup(ctx context.Context, q *Query) (*release.Metadata, error) {
+
+	ids := q.ids()
+	baseURL := strings.TrimRight(t.BaseURL, "/")
+
+	// an unknown id falls through to the next one, imdb ids of nfos are sometimes the id of an episode
+	var endpoints []string
+	if ids.TVmaze != "" {
+		endpoints = append(endpoints, "/shows/"+url.PathEscape(ids.TVmaze))
+	}
+	if ids.IMDb != "" {
+		endpoints = append(endpoints, "/lookup/shows?"+url.Values{"imdb": {ids.IMDb}}.Encode())
+	}
+	if ids.TVDB != "" {
+		endpoints = append(endpoints, "/lookup/shows?"+url.Values{"thetvdb": {ids.TVDB}}.Encode())
+	}
+
+	for _, endpoint := range endpoints {
+		var show tvmazeShow
+		err := getJSON(ctx, baseURL+endpoint, nil, &show)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return t.toMetadata(&show), nil
+	}
+
+	if q.Title == "" {
+		return nil, ErrNotFound
+	}
+
+	var results []struct {
+		Show *tvmazeShow `json:"show"`
+	}
+
+	if err := getJSON(ctx, baseURL+"/search/shows?"+url.Values{"q": {q.Title}}.Encode(), nil, &results); err != nil {
+		return nil, err
+	}
+
+	// results are sorted by relevance. A year in the release name tells remakes apart
+	for _, r := range results {
+		if r.Show != nil && (q.Year == 0 || parseYear(r.Show.Premiered) == q.Year) {
+			return t.toMetadata(r.Show), nil
+		}
+	}
+
+	return nil, ErrNotFound
+
+}
+
+func (t *TVmaze) toMetadata(show *tvmazeShow) *release.Metadata {
+
+	m := &release.Metadata{
+		Provider: t.Name(),
+		ID:       strconv.Itoa(show.ID),
+		URL:      show.URL,
+		Title:    show.Name,
+		Year:     parseYear(show.Premiered),
+		Plot:     strings.TrimSpace(html.UnescapeString(htmlTagRegExp.ReplaceAllString(show.Summary, ""))),
+		Genres:   show.Genres,
+		Rating:   show.Rating.Average,
+		Runtime:  show.Runtime,
+		ExternalIDs: &release.ExternalIDs{
+			IMDb:   show.Externals.IMDb,
+			TVmaze: strconv.Itoa(show.ID),
+		},
+	}
+
+	if m.Genres == nil {
+		m.Genres = []string{}
+	}
+
+	// shows with varying episode lengths only have an average runtime
+	if m.Runtime == 0 {
+		m.Runtime = show.AverageRuntime
+	}
+
+	if show.Externals.TheTVDB > 0 {
+		m.ExternalIDs.TVDB = strconv.Itoa(show.Externals.TheTVDB)
+	}
+
+	if show.Image != nil {
+		m.PosterURL = show.Image.Original
+		if m.PosterURL == "" {
+			m.PosterURL = show.Image.Medium
+		}
+	}
+
+	return m
+
+}
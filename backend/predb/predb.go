@@ -9,6 +9,9 @@ type Pre struct {
 	At          time.Time
 	Category    *Category
 	CategoryRaw string
+
+	// link to imdb, tvmaze etc. Empty if predb.ovh doesn't know one
+	URL string
 }
 
 // predb.ovh allows for a maximum of 30 requests per minute
@@ -41,6 +44,7 @@ func GetRelease(ctx context.Context, rlsName string) (*Pre, error) {
 		At:          time.Unix(externalData.PreAt, 0),
 		Category:    category,
 		CategoryRaw: externalData.Cat,
+		URL:         externalData.URL,
 	}
 
 	return pre, nil
@@ -0,0 +1,183 @@
+package release
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// NameAttributes are attributes parsed from a scene release name
+// Fields are empty if the attribute is not part of the name
+type NameAttributes struct {
+	Title      string `json:"title"`
+	Year       int    `json:"year"`
+	Season     int    `json:"season"`
+	Episode    int    `json:"episode"`
+	Resolution string `json:"resolution"` // e.g. 1080p
+	Source     string `json:"source"`     // e.g. BLURAY, WEB, HDTV
+	VideoCodec string `json:"videoCodec"` // e.g. X264, H265
+	AudioCodec string `json:"audioCodec"` // e.g. DTS, AC3
+	Language   string `json:"language"`   // e.g. GERMAN
+	Group      string `json:"group"`
+}
+
+var (
+	attributeYearRegExp       = regexp.MustCompile(`^(19|20)\d{2}$`)
+	attributeEpisodeRegExp    = regexp.MustCompile(`(?i)^s(\d{1,3})(e(\d{1,3}))?$`)
+	attributeResolutionRegExp = regexp.MustCompile(`(?i)^(240|360|480|576|720|1080|2160|4320)[pi]$`)
+
+	// the first matching source wins, so more specific sources have to be listed first
+	attributeSources = []struct {
+		name   string
+		tokens []string
+	}{
+		{"UHD_BLURAY", []string{"uhd.bluray", "uhdbd"}},
+		{"BLURAY", []string{"bluray", "blu-ray", "bdrip", "brrip", "bd25", "bd50"}},
+		{"WEBRIP", []string{"webrip", "web-rip"}},
+		{"WEB", []string{"web", "web-dl", "webdl"}},
+		{"HDDVD", []string{"hddvd", "hd-dvd"}},
+		{"HDTV", []string{"hdtv", "hdtvrip"}},
+		{"DVDRIP", []string{"dvdrip"}},
+		{"DVD", []string{"dvdr", "dvd5", "dvd9", "dvd"}},
+		{"SDTV", []string{"sdtv", "pdtv", "dsr"}},
+	}
+
+	attributeVideoCodecs = map[string]string{
+		"x264": "X264",
+		"x265": "X265",
+		"h264": "H264",
+		"h265": "H265",
+		"hevc": "H265",
+		"avc":  "H264",
+		"xvid": "XVID",
+		"divx": "DIVX",
+		"av1":  "AV1",
+		"vc1":  "VC1",
+	}
+
+	attributeAudioCodecs = map[string]string{
+		"dts":    "DTS",
+		"dtshd":  "DTS-HD",
+		"dts-hd": "DTS-HD",
+		"ac3":    "AC3",
+		"dd5":    "AC3",
+		"dd":     "AC3",
+		"ddp":    "EAC3",
+		"ddp5":   "EAC3",
+		"eac3":   "EAC3",
+		"aac":    "AAC",
+		"truehd": "TRUEHD",
+		"atmos":  "ATMOS",
+		"flac":   "FLAC",
+		"mp3":    "MP3",
+	}
+
+	attributeLanguages = []string{
+		"german", "french", "spanish", "italian", "dutch", "swedish", "danish", "norwegian",
+		"finnish", "polish", "russian", "czech", "hungarian", "portuguese", "japanese", "korean",
+	}
+)
+
+// ParseName parses common attributes from a scene release name
+//
+//	The.Movie.2022.1080p.BluRay.x264-GROUP
+//	The.Show.S01E02.German.720p.WEB.h264-GROUP
+func ParseName(name string) *NameAttributes {
+
+	a := &NameAttributes{}
+
+	// group
+	if i := strings.LastIndex(name, "-"); i > 0 && i < len(name)-1 {
+		a.Group = name[i+1:]
+		name = name[:i]
+	}
+
+	tokens := strings.FieldsFunc(name, func(r rune) bool {
+		return r == '.' || r == '_' || r == ' '
+	})
+
+	// the title ends at the first token that is an attribute
+	titleEnd := -1
+	markTitleEnd := func(i int) {
+		if titleEnd == -1 || i < titleEnd {
+			titleEnd = i
+		}
+	}
+
+	lowerName := "." + strings.ToLower(strings.Join(tokens, ".")) + "."
+
+	for _, s := range attributeSources {
+		for _, t := range s.tokens {
+			if strings.Contains(lowerName, "."+t+".") {
+				a.Source = s.name
+				break
+			}
+		}
+		if a.Source != "" {
+			break
+		}
+	}
+
+	for i, token := range tokens {
+		lowerToken := strings.ToLower(token)
+
+		// the first token is always part of the title, even if it looks like a year (e.g. 2012.2009.1080p...)
+		if i > 0 && a.Year == 0 && attributeYearRegExp.MatchString(token) {
+			a.Year, _ = strconv.Atoi(token)
+			markTitleEnd(i)
+			continue
+		}
+
+		if m := attributeEpisodeRegExp.FindStringSubmatch(token); m != nil && i > 0 {
+			a.Season, _ = strconv.Atoi(m[1])
+			if m[3] != "" {
+				a.Episode, _ = strconv.Atoi(m[3])
+			}
+			markTitleEnd(i)
+			continue
+		}
+
+		if attributeResolutionRegExp.MatchString(token) {
+			a.Resolution = lowerToken
+			markTitleEnd(i)
+			continue
+		}
+
+		if c, ok := attributeVideoCodecs[lowerToken]; ok && a.VideoCodec == "" {
+			a.VideoCodec = c
+			markTitleEnd(i)
+			continue
+		}
+
+		if c, ok := attributeAudioCodecs[strings.TrimRight(lowerToken, "0123456789")]; ok && a.AudioCodec == "" {
+			a.AudioCodec = c
+			markTitleEnd(i)
+			continue
+		}
+
+		for _, l := range attributeLanguages {
+			if lowerToken == l {
+				a.Language = strings.ToUpper(l)
+				markTitleEnd(i)
+				break
+			}
+		}
+
+		for _, s := range attributeSources {
+			for _, t := range s.tokens {
+				if lowerToken == t || strings.HasPrefix(t, lowerToken+".") {
+					markTitleEnd(i)
+				}
+			}
+		}
+	}
+
+	if titleEnd == -1 {
+		titleEnd = len(tokens)
+	}
+
+	a.Title = strings.Join(tokens[:titleEnd], " ")
+
+	return a
+
+}
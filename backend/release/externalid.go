@@ -0,0 +1,179 @@
+package release
+
+import (
+	"atus/backend/sqlite"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ExternalIDType is a database a release can be linked to
+type ExternalIDType string
+
+const (
+	ExternalIDTypeIMDb   ExternalIDType = "IMDB"
+	ExternalIDTypeTVmaze ExternalIDType = "TVMAZE"
+	ExternalIDTypeTVDB   ExternalIDType = "TVDB"
+	ExternalIDTypeTMDB   ExternalIDType = "TMDB"
+)
+
+var ExternalIDTypes = []ExternalIDType{
+	ExternalIDTypeIMDb,
+	ExternalIDTypeTVmaze,
+	ExternalIDTypeTVDB,
+	ExternalIDTypeTMDB,
+}
+
+var (
+	imdbURLRegExp   = regexp.MustCompile(`(?i)imdb\.com/(?:[a-z]{2}/)?title/(tt\d{7,9})`)
+	imdbIDRegExp    = regexp.MustCompile(`\b(tt\d{7,9})\b`)
+	tvmazeURLRegExp = regexp.MustCompile(`(?i)tvmaze\.com/shows/(\d+)`)
+	tvdbURLRegExp   = regexp.MustCompile(`(?i)thetvdb\.com/\S*?(?:[?&](?:series)?id=|series/)(\d+)\b`)
+	tmdbURLRegExp   = regexp.MustCompile(`(?i)themoviedb\.org/(movie|tv)/(\d+)`)
+)
+
+// ExternalIDs link a release to movie and tv databases. Empty strings are unknown ids
+type ExternalIDs struct {
+	IMDb     string `json:"imdb,omitempty"`     // e.g. tt0133093
+	TVmaze   string `json:"tvmaze,omitempty"`   // show id
+	TVDB     string `json:"tvdb,omitempty"`     // series id
+	TMDB     string `json:"tmdb,omitempty"`     // movie or tv id, see TMDBType
+	TMDBType string `json:"tmdbType,omitempty"` // movie or tv
+}
+
+// ParseExternalIDType returns an error for unknown types
+func ParseExternalIDType(s string) (ExternalIDType, error) {
+	for _, t := range ExternalIDTypes {
+		if ExternalIDType(strings.ToUpper(s)) == t {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("unknown external id type: %s", s)
+}
+
+// ExtractExternalIDs searches texts (nfos, urls) for links to IMDb, TVmaze, TVDB and TMDB.
+// The first id of every type is used. IMDb ids are also found without link, nfos often list them as "IMDb: tt0133093"
+func ExtractExternalIDs(texts ...string) *ExternalIDs {
+
+	ids := &ExternalIDs{}
+
+	for _, text := range texts {
+		if ids.IMDb == "" {
+			if m := imdbURLRegExp.FindStringSubmatch(text); m != nil {
+				ids.IMDb = m[1]
+			}
+		}
+
+		if ids.TVmaze == "" {
+			if m := tvmazeURLRegExp.FindStringSubmatch(text); m != nil {
+				ids.TVmaze = m[1]
+			}
+		}
+
+		if ids.TVDB == "" {
+			if m := tvdbURLRegExp.FindStringSubmatch(text); m != nil {
+				ids.TVDB = m[1]
+			}
+		}
+
+		if ids.TMDB == "" {
+			if m := tmdbURLRegExp.FindStringSubmatch(text); m != nil {
+				ids.TMDBType = strings.ToLower(m[1])
+				ids.TMDB = m[2]
+			}
+		}
+	}
+
+	// bare ids are only used if no text contains a link
+	if ids.IMDb == "" {
+		for _, text := range texts {
+			if m := imdbIDRegExp.FindStringSubmatch(text); m != nil {
+				ids.IMDb = m[1]
+				break
+			}
+		}
+	}
+
+	return ids
+
+}
+
+// Merge returns a copy of ids with all unknown ids set to the ids of other. ids is not modified,
+// releases share their ids between goroutines. Returns true if an id was added
+func (ids *ExternalIDs) Merge(other *ExternalIDs) (*ExternalIDs, bool) {
+
+	merged := &ExternalIDs{}
+	if ids != nil {
+		*merged = *ids
+	}
+
+	if other == nil {
+		return merged, false
+	}
+
+	changed := false
+	merge := func(id *string, otherID string) {
+		if *id == "" && otherID != "" {
+			*id = otherID
+			changed = true
+		}
+	}
+
+	merge(&merged.IMDb, other.IMDb)
+	merge(&merged.TVmaze, other.TVmaze)
+	merge(&merged.TVDB, other.TVDB)
+
+	if merged.TMDB == "" && other.TMDB != "" {
+		merged.TMDB = other.TMDB
+		merged.TMDBType = other.TMDBType
+		changed = true
+	}
+
+	return merged, changed
+
+}
+
+// Get returns the id of the given type, empty if it is unknown
+func (ids *ExternalIDs) Get(t ExternalIDType) string {
+
+	if ids == nil {
+		return ""
+	}
+
+	switch t {
+	case ExternalIDTypeIMDb:
+		return ids.IMDb
+	case ExternalIDTypeTVmaze:
+		return ids.TVmaze
+	case ExternalIDTypeTVDB:
+		return ids.TVDB
+	case ExternalIDTypeTMDB:
+		return ids.TMDB
+	}
+
+	return ""
+
+}
+
+// ParseExternalIDs parses the external_ids column of a release
+func ParseExternalIDs(raw string) *ExternalIDs {
+	ids := &ExternalIDs{}
+	if raw != "" {
+		json.Unmarshal([]byte(raw), ids)
+	}
+	return ids
+}
+
+// SaveExternalIDs updates the external ids of a saved release
+func SaveExternalIDs(rlsUID string, ids *ExternalIDs) error {
+
+	buf, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+
+	_, err = sqlite.Conn.Exec(`UPDATE releases SET external_ids = ? WHERE uid = ?`, string(buf), rlsUID)
+	return err
+
+}
@@ -0,0 +1,78 @@
+package release
+
+import (
+	"testing"
+)
+
+func TestExtractExternalIDs(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		texts    []string
+		expected ExternalIDs
+	}{
+		{"none", []string{"no links here", "tt123456"}, ExternalIDs{}},
+		{"imdb", []string{"https://www.imdb.com/title/tt0133093/"}, ExternalIDs{IMDb: "tt0133093"}},
+		{"imdb localized", []string{"http://imdb.com/de/title/tt0133093/?ref_=nv_sr_1"}, ExternalIDs{IMDb: "tt0133093"}},
+		{"imdb mobile", []string{"https://m.imdb.com/title/tt10872600"}, ExternalIDs{IMDb: "tt10872600"}},
+		{"imdb in nfo", []string{"  IMDb......: http://www.imdb.com/title/tt0944947/\r\n"}, ExternalIDs{IMDb: "tt0944947"}},
+		{"bare imdb id", []string{"IMDb: tt0944947 (9.2/10)"}, ExternalIDs{IMDb: "tt0944947"}},
+		{"bare imdb id in a word", []string{"xtt0944947", "tt09449470000"}, ExternalIDs{}},
+		{"imdb link wins over bare id", []string{"tt1111111", "imdb.com/title/tt2222222"}, ExternalIDs{IMDb: "tt2222222"}},
+		{"first imdb link wins", []string{"imdb.com/title/tt1111111", "imdb.com/title/tt2222222"}, ExternalIDs{IMDb: "tt1111111"}},
+		{"tvmaze", []string{"https://www.tvmaze.com/shows/82/game-of-thrones"}, ExternalIDs{TVmaze: "82"}},
+		{"tvmaze episode", []string{"https://www.tvmaze.com/episodes/4952/game-of-thrones-1x01"}, ExternalIDs{}},
+		{"tvdb id", []string{"https://thetvdb.com/?tab=series&id=121361"}, ExternalIDs{TVDB: "121361"}},
+		{"tvdb seriesid", []string{"http://www.thetvdb.com/index.php?tab=series&seriesid=121361&lid=7"}, ExternalIDs{TVDB: "121361"}},
+		{"tvdb dereferrer", []string{"https://thetvdb.com/dereferrer/series/121361"}, ExternalIDs{TVDB: "121361"}},
+		{"tvdb slug", []string{"https://thetvdb.com/series/game-of-thrones"}, ExternalIDs{}},
+		{"tmdb movie", []string{"https://www.themoviedb.org/movie/603-the-matrix"}, ExternalIDs{TMDB: "603", TMDBType: "movie"}},
+		{"tmdb tv", []string{"https://www.themoviedb.org/TV/1399?language=de"}, ExternalIDs{TMDB: "1399", TMDBType: "tv"}},
+		{
+			"all",
+			[]string{
+				"https://www.imdb.com/title/tt0944947/",
+				"https://www.tvmaze.com/shows/82 https://thetvdb.com/?tab=series&id=121361",
+				"https://www.themoviedb.org/tv/1399",
+			},
+			ExternalIDs{IMDb: "tt0944947", TVmaze: "82", TVDB: "121361", TMDB: "1399", TMDBType: "tv"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ids := ExtractExternalIDs(tt.texts...); *ids != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, *ids)
+			}
+		})
+	}
+
+}
+
+func TestExternalIDs_Merge(t *testing.T) {
+
+	ids := &ExternalIDs{IMDb: "tt0944947"}
+
+	merged, changed := ids.Merge(&ExternalIDs{IMDb: "tt1111111", TMDB: "1399", TMDBType: "tv"})
+	if !changed {
+		t.Error("expected the tmdb id to be added")
+	}
+
+	if *merged != (ExternalIDs{IMDb: "tt0944947", TMDB: "1399", TMDBType: "tv"}) {
+		t.Errorf("unexpected merged ids %+v", *merged)
+	}
+
+	if *ids != (ExternalIDs{IMDb: "tt0944947"}) {
+		t.Errorf("expected the ids to be unchanged, got %+v", *ids)
+	}
+
+	if _, changed := merged.Merge(&ExternalIDs{IMDb: "tt1111111"}); changed {
+		t.Error("expected known ids to be kept")
+	}
+
+	var unknown *ExternalIDs
+	if merged, changed := unknown.Merge(nil); changed || merged == nil || *merged != (ExternalIDs{}) {
+		t.Errorf("expected empty ids, got %+v", merged)
+	}
+
+}
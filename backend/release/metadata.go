@@ -0,0 +1,53 @@
+package release
+
+import (
+	"atus/backend/sqlite"
+	"encoding/json"
+)
+
+// Metadata is information about the movie or show of a release, found by a metadata provider
+type Metadata struct {
+	Provider string `json:"provider"` // e.g. TMDB
+	ID       string `json:"id"`       // id on the provider
+	URL      string `json:"url"`      // page on the provider
+
+	Title   string   `json:"title"`
+	Year    int      `json:"year"`
+	Plot    string   `json:"plot"`
+	Genres  []string `json:"genres"`
+	Rating  float64  `json:"rating"`  // 0-10, 0 if unrated
+	Votes   int      `json:"votes"`   // 0 if the provider doesn't count votes
+	Runtime int      `json:"runtime"` // in minutes, average episode length of shows
+
+	// full size poster on the provider. Empty if it has none
+	PosterURL string `json:"posterURL"`
+
+	// ids the provider knows for the movie or show
+	ExternalIDs *ExternalIDs `json:"externalIDs"`
+}
+
+// ParseMetadata parses the metadata column of a release. Returns nil if the release has no metadata
+func ParseMetadata(raw string) *Metadata {
+	if raw == "" {
+		return nil
+	}
+
+	m := &Metadata{}
+	if err := json.Unmarshal([]byte(raw), m); err != nil {
+		return nil
+	}
+	return m
+}
+
+// SaveMetadata updates the metadata of a saved release
+func SaveMetadata(rlsUID string, m *Metadata) error {
+
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	_, err = sqlite.Conn.Exec(`UPDATE releases SET metadata = ? WHERE uid = ?`, string(buf), rlsUID)
+	return err
+
+}
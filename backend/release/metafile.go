@@ -15,12 +15,20 @@ type MetaFileType string
 const (
 	MetafileTypeTorrent               MetaFileType = "TORRENT"
 	MetafileTypeNFO                   MetaFileType = "NFO"
+	MetafileTypeNFOImage              MetaFileType = "NFO_IMAGE" // nfo rendered as png
 	MetafileTypeSourceImage           MetaFileType = "SOURCE_IMAGE"
+	MetafileTypePoster                MetaFileType = "POSTER" // downloaded from the metadata provider
 	MetafileTypeImage                 MetaFileType = "IMAGE"
 	MetafileTypeProofImage            MetaFileType = "PROOF_IMAGE"
 	MetafileTypeScreenImage           MetaFileType = "SCREEN_IMAGE"
 	MetafileTypeScreenImageFromSample MetaFileType = "SCREEN_IMAGE__FROM_SAMPLE"
+	MetafileTypeScreenImageFromVideo  MetaFileType = "SCREEN_IMAGE__FROM_VIDEO" // taken from the release video on the fileserver
 	MetafileTypeSampleVideo           MetaFileType = "SAMPLE_VIDEO"
+	MetafileTypeReleaseVideo          MetaFileType = "RELEASE_VIDEO"     // main video of releases without sample. Probed on the fileserver, never downloaded
+	MetafileTypeMediaInfo             MetaFileType = "MEDIAINFO"         // technical report in the text layout of MediaInfo
+	MetafileTypeContactSheet          MetaFileType = "CONTACT_SHEET"     // grid of frames from the sample
+	MetafileTypeValidationReport      MetaFileType = "VALIDATION_REPORT" // json encoded bencode.Report
+	MetafileTypeRecreatedTorrent      MetaFileType = "RECREATED_TORRENT" // torrent created from the data on the fileserver
 )
 
 type MetaFileState string
@@ -6,8 +6,10 @@ import (
 	"atus/backend/predb"
 	"atus/backend/source"
 	"atus/backend/sqlite"
+	"atus/backend/video"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/url"
@@ -21,18 +23,38 @@ import (
 type ReleaseState string
 
 const (
-	StateNew          ReleaseState = "NEW"
-	StateDownloadInit ReleaseState = "DOWNLOAD_INIT"
-	StateDownloading  ReleaseState = "DOWNLOADING"
-	StateDownloaded   ReleaseState = "DOWNLOADED"
-	StateUploaded     ReleaseState = "UPLOADED"
-	StateUploadError  ReleaseState = "UPLOAD_ERROR"
-	StateGeneralError ReleaseState = "GENERAL_ERROR"
+	StateNew              ReleaseState = "NEW"
+	StateDownloadInit     ReleaseState = "DOWNLOAD_INIT"
+	StateDownloading      ReleaseState = "DOWNLOADING"
+	StateDownloaded       ReleaseState = "DOWNLOADED"
+	StateAwaitingApproval ReleaseState = "AWAITING_APPROVAL"
+	StateRejected         ReleaseState = "REJECTED"
+	StateQuarantined      ReleaseState = "QUARANTINED" // the torrent failed validation, see MetafileTypeValidationReport
+	StateUploaded         ReleaseState = "UPLOADED"
+	StateUploadError      ReleaseState = "UPLOAD_ERROR"
+	StateGeneralError     ReleaseState = "GENERAL_ERROR"
 )
 
+// videoExtensions are the extensions of files that are probed if a release has no sample
+var videoExtensions = map[string]bool{
+	".mkv":  true,
+	".mp4":  true,
+	".m4v":  true,
+	".avi":  true,
+	".ts":   true,
+	".m2ts": true,
+	".mov":  true,
+	".wmv":  true,
+	".mpg":  true,
+}
+
 type Release struct {
-	UID       string
-	Hash      string
+	UID  string
+	Hash string
+
+	// SHA-256 infohash of v2 and hybrid torrents (BEP 52). Empty for v1 torrents
+	HashV2 string
+
 	Name      string
 	NameRaw   string
 	Added     time.Time
@@ -40,6 +62,9 @@ type Release struct {
 	Size      int64
 	Source    *source.Source
 	State     ReleaseState
+
+	// found in the feed item, predb and the nfo
+	ExternalIDs *ExternalIDs
 }
 
 func New(ctx context.Context, s *source.Source, nameRaw string, torrentURL, imageURL *url.URL) (*Release, error) {
@@ -50,6 +75,8 @@ func New(ctx context.Context, s *source.Source, nameRaw string, torrentURL, imag
 		Added:   time.Now(),
 		NameRaw: nameRaw,
 		Source:  s,
+
+		ExternalIDs: &ExternalIDs{},
 	}
 
 	// --------------------------------------------
@@ -59,15 +86,14 @@ func New(ctx context.Context, s *source.Source, nameRaw string, torrentURL, imag
 		return nil, err
 	}
 
-	dictRaw, err := bencode.BDecodeRaw(torrentFile)
+	// the infohash is calculated from the original bytes of the info dict
+	metainfo, err := bencode.ParseMetainfo(torrentFile)
 	if err != nil {
 		return nil, err
 	}
 
-	rls.Hash, err = dictRaw.GenHash()
-	if err != nil {
-		return nil, err
-	}
+	rls.Hash = metainfo.InfoHash()
+	rls.HashV2 = metainfo.InfoHashV2()
 
 	// ----------------------
 
@@ -91,6 +117,33 @@ func New(ctx context.Context, s *source.Source, nameRaw string, torrentURL, imag
 		}),
 	}
 
+	// releases without nfo are not stored, whether the torrent is valid or not.
+	// Only the extensions are compared, the paths are not used before the torrent is validated
+	if !hasNFOFile(dict.GetFiles()) {
+		return nil, errors.New("no nfo file in release")
+	}
+
+	// -- validate the torrent before any of its paths are used
+	report := bencode.Validate(dict, bencode.DefaultLimits)
+	if !report.Valid() {
+		if !config.GetBool("FILTERS__QUARANTINE_INVALID_TORRENTS") {
+			return nil, &ValidationError{Report: report}
+		}
+
+		buf, err := json.Marshal(report)
+		if err != nil {
+			return nil, err
+		}
+
+		// quarantined releases keep the torrent and the report, nothing else is extracted
+		rls.State = StateQuarantined
+		rls.MetaFiles = append(metaFiles,
+			NewMetaFile(rls.UID, fmt.Sprintf("validation_%s.json", rls.UID), -1, MetafileTypeValidationReport, MetafileStateProcessed, buf, MetaInfo{}),
+		)
+
+		return rls, nil
+	}
+
 	// -- search torrent file for nfo, images and other metadata
 	hasNFO := false
 	hasSample := false
@@ -140,8 +193,8 @@ func New(ctx context.Context, s *source.Source, nameRaw string, torrentURL, imag
 			break
 		}
 
-		// sample file.
-		if config.GetBool("SAMPLES__ENABLED") {
+		// sample file. Ignored if the dependencies of the packager are missing
+		if config.GetBool("SAMPLES__ENABLED") && video.DependencyError() == nil {
 			if strings.Contains(lowerPath, "sample") && f.Length > config.GetInt64("SAMPLES__MIN_SIZE") && f.Length < config.GetInt64("SAMPLES__MAX_SIZE") {
 				// ignore if we already have one
 				if hasSample {
@@ -156,6 +209,26 @@ func New(ctx context.Context, s *source.Source, nameRaw string, torrentURL, imag
 		}
 	}
 
+	// -- main video, screenshots and technical info are taken from the fileserver if the release has no sample
+	if !hasSample && config.GetBool("SAMPLES__ENABLED") && config.GetBool("SAMPLES__FROM_RELEASE_VIDEO") && video.DependencyError() == nil {
+		index, largest := -1, int64(0)
+		for i, f := range dict.GetFiles() {
+			lowerPath := strings.ToLower(strings.Join(f.Path, "/"))
+			if videoExtensions[filepath.Ext(lowerPath)] && !strings.Contains(lowerPath, "sample") && f.Length > largest {
+				index, largest = i, f.Length
+			}
+		}
+
+		if index != -1 {
+			file := strings.Join(dict.GetFiles()[index].Path, "/")
+			metaFiles = append(metaFiles,
+				NewMetaFile(rls.UID, fmt.Sprintf("%d_%s%s", index, rls.UID, filepath.Ext(strings.ToLower(file))), index, MetafileTypeReleaseVideo, MetafileStateUnknown, nil, MetaInfo{
+					"releasePath": file,
+				}),
+			)
+		}
+	}
+
 	// -- source image
 	if imageURL != nil {
 		if buf, ext, err := s.GetImage(ctx, imageURL.String()); err == nil {
@@ -169,14 +242,27 @@ func New(ctx context.Context, s *source.Source, nameRaw string, torrentURL, imag
 
 	rls.MetaFiles = metaFiles
 
-	// --------------------------------------------
+	return rls, nil
 
-	if !hasNFO {
-		return nil, errors.New("no nfo file in release")
+}
+
+// hasNFOFile returns true if one of the files is an nfo
+func hasNFOFile(files []*bencode.File) bool {
+	for _, f := range files {
+		if len(f.Path) > 0 && strings.EqualFold(filepath.Ext(f.Path[len(f.Path)-1]), ".nfo") {
+			return true
+		}
 	}
+	return false
+}
 
-	return rls, nil
+// ValidationError is returned by New if the torrent failed validation and invalid torrents are not quarantined
+type ValidationError struct {
+	Report *bencode.Report
+}
 
+func (e *ValidationError) Error() string {
+	return "invalid torrent: " + e.Report.String()
 }
 
 func (r *Release) IsKnown() bool {
@@ -195,10 +281,16 @@ func (r *Release) IsKnown() bool {
 // Do NOT call this function directly, use atus.saveNewRelease instead
 func (r *Release) Save(p *predb.Pre) error {
 
-	_, err := sqlite.Conn.Exec(
+	externalIDs, err := json.Marshal(r.ExternalIDs)
+	if err != nil {
+		return err
+	}
+
+	_, err = sqlite.Conn.Exec(
 		`INSERT INTO releases (
 				uid,
 				hash,
+				hash_v2,
 				name,
 				name_raw,
 				state,
@@ -207,11 +299,13 @@ func (r *Release) Save(p *predb.Pre) error {
 				category_raw,
 				size,
 				added,
-				source_uid
+				source_uid,
+				external_ids
 			) 
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		r.UID,
 		r.Hash,
+		r.HashV2,
 		r.Name,
 		r.NameRaw,
 		r.State,
@@ -221,6 +315,7 @@ func (r *Release) Save(p *predb.Pre) error {
 		r.Size,
 		time.Now().Format(time.RFC3339),
 		r.Source.UID,
+		string(externalIDs),
 	)
 
 	if err != nil {
@@ -0,0 +1,209 @@
+package release
+
+import (
+	"atus/backend/sqlite"
+	"database/sql"
+	"time"
+)
+
+type UploadState string
+
+const (
+	UploadStateUploaded    UploadState = "UPLOADED"
+	UploadStateUploadError UploadState = "UPLOAD_ERROR"
+	UploadStateSkipped     UploadState = "SKIPPED"   // the release didn't pass the destinations filters
+	UploadStateDryRun      UploadState = "DRY_RUN"   // the payload was written to disk instead of being sent
+	UploadStateUploading   UploadState = "UPLOADING" // the upload was sent, the trackers response is pending or got lost
+	UploadStateDupe        UploadState = "DUPE"      // the tracker already has a different torrent with the same name
+)
+
+// Upload is the upload state of a release on a single destination
+type Upload struct {
+	ReleaseUID     string      `json:"releaseUID"`
+	DestinationUID string      `json:"destinationUID"`
+	State          UploadState `json:"state"`
+	Message        string      `json:"message"`
+
+	// infohash of the torrent file that is seeded for the destination
+	Hash string `json:"hash"`
+
+	// infohash of the torrent on the source. Together with Hash it maps the original torrent to the destinations torrent
+	OriginalHash string `json:"originalHash"`
+
+	// AttemptKey is sent as idempotency key with the upload.
+	// It is only kept for the retry of an attempt that was sent without getting a response (UploadStateUploading)
+	// and whose payload is unchanged, so the tracker recognizes the retry
+	AttemptKey string `json:"attemptKey"`
+
+	// AttemptPayload is the fingerprint of the payload that was sent with AttemptKey
+	AttemptPayload string `json:"-"`
+
+	// id and url of the torrent on the destination tracker. Empty if the tracker doesn't return them
+	TorrentID  string `json:"torrentID"`
+	TorrentURL string `json:"torrentURL"`
+
+	Updated time.Time `json:"updated"`
+}
+
+func NewUpload(rlsUID, destinationUID string) *Upload {
+	return &Upload{
+		ReleaseUID:     rlsUID,
+		DestinationUID: destinationUID,
+	}
+}
+
+// GetUploads returns the upload states of a release on all destinations
+func GetUploads(rlsUID string) ([]*Upload, error) {
+
+	rows, err := sqlite.Conn.Query(
+		`SELECT
+			destination_uid,
+			state,
+			message,
+			hash,
+			original_hash,
+			attempt_key,
+			attempt_payload,
+			torrent_id,
+			torrent_url,
+			updated
+		FROM release_uploads
+		WHERE release_uid = ?`,
+		rlsUID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var uploads []*Upload
+	for rows.Next() {
+		u := &Upload{
+			ReleaseUID: rlsUID,
+		}
+
+		var updated string
+		if err := rows.Scan(
+			&u.DestinationUID,
+			&u.State,
+			&u.Message,
+			&u.Hash,
+			&u.OriginalHash,
+			&u.AttemptKey,
+			&u.AttemptPayload,
+			&u.TorrentID,
+			&u.TorrentURL,
+			&updated,
+		); err != nil {
+			return nil, err
+		}
+
+		if t, err := time.Parse(time.RFC3339, updated); err == nil {
+			u.Updated = t
+		}
+
+		uploads = append(uploads, u)
+	}
+
+	return uploads, nil
+
+}
+
+// GetUpload returns the upload state of a release on a destination
+// Returns nil if the release was never uploaded to the destination
+func GetUpload(rlsUID, destinationUID string) (*Upload, error) {
+
+	u := NewUpload(rlsUID, destinationUID)
+
+	var updated string
+	err := sqlite.Conn.QueryRow(
+		`SELECT
+			state,
+			message,
+			hash,
+			original_hash,
+			attempt_key,
+			attempt_payload,
+			torrent_id,
+			torrent_url,
+			updated
+		FROM release_uploads
+		WHERE release_uid = ? AND destination_uid = ?`,
+		rlsUID,
+		destinationUID,
+	).Scan(&u.State, &u.Message, &u.Hash, &u.OriginalHash, &u.AttemptKey, &u.AttemptPayload, &u.TorrentID, &u.TorrentURL, &updated)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if t, err := time.Parse(time.RFC3339, updated); err == nil {
+		u.Updated = t
+	}
+
+	return u, nil
+
+}
+
+// Save saves the upload state
+// If there already is a state for the release and destination, it will be updated
+func (u *Upload) Save() error {
+
+	u.Updated = time.Now()
+	updated := u.Updated.Format(time.RFC3339)
+
+	_, err := sqlite.Conn.Exec(
+		`INSERT INTO release_uploads (
+				release_uid,
+				destination_uid,
+				state,
+				message,
+				hash,
+				original_hash,
+				attempt_key,
+				attempt_payload,
+				torrent_id,
+				torrent_url,
+				updated
+			)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(release_uid, destination_uid) DO UPDATE SET
+				state = ?,
+				message = ?,
+				hash = ?,
+				original_hash = ?,
+				attempt_key = ?,
+				attempt_payload = ?,
+				torrent_id = ?,
+				torrent_url = ?,
+				updated = ?`,
+		u.ReleaseUID,
+		u.DestinationUID,
+		u.State,
+		u.Message,
+		u.Hash,
+		u.OriginalHash,
+		u.AttemptKey,
+		u.AttemptPayload,
+		u.TorrentID,
+		u.TorrentURL,
+		updated,
+		u.State,
+		u.Message,
+		u.Hash,
+		u.OriginalHash,
+		u.AttemptKey,
+		u.AttemptPayload,
+		u.TorrentID,
+		u.TorrentURL,
+		updated,
+	)
+
+	return err
+
+}
@@ -51,7 +51,8 @@ func (r *Request) Do() (*http.Response, error) {
 		return nil, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
+	// 206 is returned for range requests
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
 		defer resp.Body.Close()
 
 		buf, err := ioutil.ReadAll(resp.Body)
@@ -59,9 +60,19 @@ func (r *Request) Do() (*http.Response, error) {
 			return nil, err
 		}
 
-		return nil, fmt.Errorf("server returned error %d: %s", resp.StatusCode, buf)
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: buf}
 	}
 
 	return resp, nil
 
 }
+
+// StatusError is returned by Do if the server responds with an unexpected status code
+type StatusError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("server returned error %d: %s", e.StatusCode, e.Body)
+}
@@ -3,11 +3,15 @@ package routes
 import (
 	"atus/backend/atus"
 	"atus/backend/config"
+	"atus/backend/description"
+	"atus/backend/imaging"
 	"atus/backend/release"
 	"atus/backend/sqlite"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"os"
 	"path"
@@ -16,10 +20,19 @@ import (
 	"time"
 
 	"github.com/h2non/filetype"
-	"golang.org/x/text/encoding/charmap"
 )
 
-// ToDo: allow image resizing by query params
+// streaming manifests and segments can't be detected by their content
+var streamingMIMETypes = map[string]string{
+	".mpd":  "application/dash+xml",
+	".m3u8": "application/vnd.apple.mpegurl",
+	".m4s":  "video/iso.segment",
+	".ts":   "video/mp2t",
+	".vtt":  "text/vtt; charset=utf-8",
+}
+
+// API__ServeFile serves files from the data folder.
+// Images are resized if the query params w and/or h are set, see imaging.ParseOptions
 func API__ServeFile(w http.ResponseWriter, r *http.Request) {
 
 	requestType := r.Context().Value(apiAuthTypeContextKey).(apiAuthType)
@@ -29,7 +42,8 @@ func API__ServeFile(w http.ResponseWriter, r *http.Request) {
 
 	// -- get file ----------------------------------------------------------------------------------
 
-	file := path.Join(config.Base.Folders.Data, strings.TrimPrefix(r.URL.Path, "/api/data/"))
+	relPath := path.Clean(strings.TrimPrefix(r.URL.Path, "/api/data/"))
+	file := path.Join(config.Base.Folders.Data, relPath)
 
 	// make sure external users can't access source torrent files
 	// this should be done by the reverse proxy, but just to be sure we check it here as well
@@ -40,6 +54,12 @@ func API__ServeFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// dry runs contain the payload of other destinations
+	if requestType != apiAuthTypeInternal && strings.Contains("/"+relPath+"/", "/dry-run/") {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
 	// check if file is servable
 	stat, err := os.Stat(file)
 	if err != nil || stat.IsDir() {
@@ -47,44 +67,139 @@ func API__ServeFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	buf, err := os.ReadFile(file)
+	// -- resized images ----------------------------------------------------------------------------
+
+	if imaging.IsImage(file) && !isDownload {
+		opts, err := imaging.ParseOptions(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if opts != nil {
+			serveResizedImage(w, r, relPath, stat.ModTime(), opts)
+			return
+		}
+	}
+
+	// -- original file -----------------------------------------------------------------------------
+
+	f, err := os.Open(file)
 	if err != nil {
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
+	defer f.Close()
 
-	kind, err := filetype.Match(buf)
+	mimeType, err := getMIMEType(f)
 	if err != nil {
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
 
-	// register nfo file type
-	if strings.HasSuffix(file, ".nfo") {
-		kind = filetype.NewType("nfo", "text/plain")
-	}
+	// the etag changes whenever the file is replaced, e.g. when a sample is converted again
+	etag := fmt.Sprintf(`"%x-%x"`, stat.ModTime().UnixNano(), stat.Size())
+
+	var content io.ReadSeeker = f
 
-	// convert nfo to utf-8 so it can be displayed in the browser
-	if kind.Extension == "nfo" && !isDownload {
-		buf, err = charmap.CodePage866.NewDecoder().Bytes(buf)
+	// convert nfo to utf-8 so it can be displayed in the browser.
+	// nfo files are small, the converted file is kept in memory
+	if path.Ext(file) == ".nfo" && !isDownload {
+		// the charset query param is used to preview a charset before it is saved
+		charset, err := description.ParseCharset(r.URL.Query().Get("charset"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if charset == description.CharsetAuto {
+			charset = getNFOCharset(relPath)
+		}
+
+		buf, err := io.ReadAll(f)
 		if err != nil {
 			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			return
 		}
+
+		if charset == description.CharsetAuto {
+			charset = description.DetectCharset(buf)
+		}
+
+		content = strings.NewReader(description.DecodeNFO(buf, charset))
+		etag = fmt.Sprintf(`"%x-%x-%s"`, stat.ModTime().UnixNano(), stat.Size(), strings.ToLower(string(charset)))
 	}
 
-	// set headers
-	w.Header().Set("Content-Type", kind.MIME.Value)
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(buf)))
+	// set headers. http.ServeContent sets the content length, answers range requests
+	// and conditional requests (If-None-Match, If-Modified-Since, If-Range)
+	if mimeType != "" {
+		w.Header().Set("Content-Type", mimeType)
+	} else {
+		// let http.ServeContent sniff the type, MiddlewareHeaders sets json
+		w.Header().Del("Content-Type")
+	}
 	w.Header().Set("Cache-Control", "public, max-age=86400")
-	w.Header().Set("Last-Modified", stat.ModTime().Format(http.TimeFormat))
+	w.Header().Set("ETag", etag)
 
 	if isDownload {
 		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", path.Base(file)))
 	}
 
-	// write file
-	w.Write(buf)
+	http.ServeContent(w, r, path.Base(file), stat.ModTime(), content)
+
+}
+
+// getNFOCharset returns the charset override of an nfo meta file, CharsetAuto if there is none
+func getNFOCharset(relPath string) description.Charset {
+
+	metaFiles, err := release.GetMetaFiles(path.Dir(relPath), "", release.MetafileTypeNFO)
+	if err != nil {
+		return description.CharsetAuto
+	}
+
+	for _, m := range metaFiles {
+		if m.FileName == path.Base(relPath) {
+			if charset, err := description.ParseCharset(m.Info["charset"]); err == nil {
+				return charset
+			}
+		}
+	}
+
+	return description.CharsetAuto
+
+}
+
+// getMIMEType returns the mime type of a file by its extension or content.
+// Returns an empty string if the type is unknown
+func getMIMEType(f *os.File) (string, error) {
+
+	ext := strings.ToLower(path.Ext(f.Name()))
+
+	if ext == ".nfo" {
+		return "text/plain; charset=utf-8", nil
+	}
+
+	// streaming manifests and segments can't be detected by their content
+	if t, ok := streamingMIMETypes[ext]; ok {
+		return t, nil
+	}
+
+	// filetype only needs the first 262 bytes
+	head := make([]byte, 262)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	if kind, err := filetype.Match(head[:n]); err == nil && kind != filetype.Unknown {
+		return kind.MIME.Value, nil
+	}
+
+	return mime.TypeByExtension(ext), nil
 
 }
 
@@ -0,0 +1,42 @@
+package routes
+
+import (
+	"atus/backend/imaging"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// serveResizedImage serves a resized copy of an image from the cache.
+// The ETag identifies the cache file, http.ServeContent answers If-None-Match and If-Modified-Since with 304
+func serveResizedImage(w http.ResponseWriter, r *http.Request, relPath string, modTime time.Time, o *imaging.Options) {
+
+	cacheFile, format, err := imaging.Get(r.Context(), relPath, o)
+	if errors.Is(err, imaging.ErrImageTooLarge) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	f, err := os.Open(cacheFile)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", format.MIME())
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Header().Set("ETag", fmt.Sprintf(`"%s"`, strings.TrimSuffix(path.Base(cacheFile), path.Ext(cacheFile))))
+
+	http.ServeContent(w, r, path.Base(cacheFile), modTime, f)
+
+}
@@ -2,11 +2,16 @@ package routes
 
 import (
 	"atus/backend/config"
+	"atus/backend/destination"
 	"atus/backend/helpers"
 	"atus/backend/user"
 	"context"
+	"io"
+	"math"
+	"net"
 	"net/http"
 	"strings"
+	"time"
 )
 
 func MiddlewareHeaders(next http.Handler) http.Handler {
@@ -89,8 +94,8 @@ func MiddlewareAPIAuth(next http.Handler) http.Handler {
 			return
 		}
 
-		if authToken == config.GetString("API__AUTH_TOKEN") {
-			// request is sent from the tracker through the reverse proxy
+		if authToken == config.GetString("API__AUTH_TOKEN") || destination.IsAuthToken(authToken) {
+			// request is sent from a tracker through the reverse proxy
 			isAuthorized = true
 		} else {
 			// request is sent from the webinterface
@@ -113,3 +118,98 @@ func MiddlewareAPIAuth(next http.Handler) http.Handler {
 
 	})
 }
+
+var connContextKey helpers.ContextKey = "conn"
+
+// dataWriteIdleTimeout is the write timeout of the data routes. It is extended with every write,
+// so large files can be streamed to slow clients while stalled connections are still closed
+const dataWriteIdleTimeout = 30 * time.Second
+
+// ConnContext stores the connection in the request context, see MiddlewareDataTimeouts.
+// Set as http.Server.ConnContext
+func ConnContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connContextKey, c)
+}
+
+// dataReadFromChunkSize is the amount of data sent with sendfile before the write deadline is extended
+const dataReadFromChunkSize = 256 * 1024
+
+// MiddlewareDataTimeouts replaces the write timeout of the server with dataWriteIdleTimeout.
+// Only HTTP/1.x, a HTTP/2 connection is shared by all streams and its deadline would affect them too
+func MiddlewareDataTimeouts(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		conn, ok := r.Context().Value(connContextKey).(net.Conn)
+		if !ok || r.ProtoMajor != 1 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// the first write may take a while, e.g. if an image has to be resized
+		conn.SetWriteDeadline(time.Now().Add(dataWriteIdleTimeout))
+
+		next.ServeHTTP(&deadlineResponseWriter{ResponseWriter: w, conn: conn}, r)
+
+	})
+}
+
+type deadlineResponseWriter struct {
+	http.ResponseWriter
+	conn net.Conn
+}
+
+func (w *deadlineResponseWriter) Write(b []byte) (int, error) {
+	w.conn.SetWriteDeadline(time.Now().Add(dataWriteIdleTimeout))
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *deadlineResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// CloseNotify is forwarded for handlers that still use http.CloseNotifier
+func (w *deadlineResponseWriter) CloseNotify() <-chan bool {
+	if cn, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+	return nil
+}
+
+// ReadFrom keeps sendfile of the underlying writer, http.ServeContent copies files with it.
+// The file is sent in chunks to extend the write deadline in between
+func (w *deadlineResponseWriter) ReadFrom(src io.Reader) (int64, error) {
+
+	rf, ok := w.ResponseWriter.(io.ReaderFrom)
+	if !ok {
+		return io.Copy(struct{ io.Writer }{w}, src)
+	}
+
+	// sendfile only unwraps a single io.LimitedReader, so the limit of src is applied to the chunks
+	lr, ok := src.(*io.LimitedReader)
+	if !ok {
+		lr = &io.LimitedReader{R: src, N: math.MaxInt64}
+	}
+
+	var n int64
+	for lr.N > 0 {
+		chunk := int64(dataReadFromChunkSize)
+		if lr.N < chunk {
+			chunk = lr.N
+		}
+
+		w.conn.SetWriteDeadline(time.Now().Add(dataWriteIdleTimeout))
+
+		m, err := rf.ReadFrom(&io.LimitedReader{R: lr.R, N: chunk})
+		n += m
+		lr.N -= m
+
+		if err != nil || m < chunk {
+			return n, err
+		}
+	}
+
+	return n, nil
+
+}
@@ -0,0 +1,63 @@
+package sqlite
+
+import (
+	"fmt"
+)
+
+type column struct {
+	table      string
+	name       string
+	definition string
+}
+
+// columnExists returns true if the given column exists in the given table
+func columnExists(table, name string) (bool, error) {
+
+	rows, err := Conn.Query(fmt.Sprintf("PRAGMA table_info(`%s`)", table))
+	if err != nil {
+		return false, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var colName, colType string
+		var defaultValue interface{}
+
+		if err := rows.Scan(&cid, &colName, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return false, err
+		}
+
+		if colName == name {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+
+}
+
+// addMissingColumns adds columns that were introduced after a table was created.
+// CREATE TABLE IF NOT EXISTS won't touch existing tables, so databases created by older versions
+// would be missing these columns otherwise.
+func addMissingColumns(columns []column) error {
+
+	for _, c := range columns {
+		exists, err := columnExists(c.table, c.name)
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			continue
+		}
+
+		if _, err := Conn.Exec(fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN `%s` %s", c.table, c.name, c.definition)); err != nil {
+			return fmt.Errorf("could not add column %s.%s: %s", c.table, c.name, err)
+		}
+	}
+
+	return nil
+
+}
@@ -19,6 +19,38 @@ func Prepare() error {
 
 	stmts = append(stmts, `CREATE UNIQUE INDEX IF NOT EXISTS "config_name" ON "config" ("name")`)
 
+	// destinations
+	stmts = append(stmts,
+		`CREATE TABLE IF NOT EXISTS "destinations" (
+			"uid"	CHAR(10) NOT NULL DEFAULT NULL,
+			"name"	VARCHAR(100) NOT NULL,
+			"enabled"	INTEGER NOT NULL DEFAULT 1,
+			"type"	TEXT NOT NULL DEFAULT 'ATUS',
+			"api_url"	TEXT NOT NULL,
+			"tracker_api_key"	TEXT NOT NULL DEFAULT '',
+			"anonymous"	INTEGER NOT NULL DEFAULT 0,
+			"api_auth_token"	TEXT NOT NULL,
+			"tracker_announce_url"	TEXT NOT NULL,
+			"user_announce_url"	TEXT NOT NULL,
+			"user_id"	TEXT NOT NULL,
+			"comment"	TEXT NOT NULL DEFAULT '',
+			"created_by"	TEXT NOT NULL DEFAULT '',
+			"category_mapping"	TEXT NOT NULL DEFAULT '{}',
+			"attribute_mapping"	TEXT NOT NULL DEFAULT '{}',
+			"filters"	TEXT NOT NULL DEFAULT '{}',
+			"description_template"	TEXT NOT NULL DEFAULT '',
+			"file_base_url"	TEXT NOT NULL DEFAULT '',
+			"source_tag"	TEXT NOT NULL DEFAULT '',
+			"recreate_torrent"	INTEGER NOT NULL DEFAULT 0,
+			"piece_size_policy"	TEXT NOT NULL DEFAULT '[]',
+			"contact_sheet_field"	TEXT NOT NULL DEFAULT '',
+			"dry_run"	INTEGER NOT NULL DEFAULT 0,
+			"sum_uploads"	INTEGER DEFAULT 0,
+			PRIMARY KEY("uid")
+		)`)
+
+	stmts = append(stmts, `CREATE INDEX IF NOT EXISTS "destinations_api_auth_token" ON "destinations" ("api_auth_token")`)
+
 	// fileservers
 	stmts = append(stmts,
 		`CREATE TABLE IF NOT EXISTS "fileservers" (
@@ -30,11 +62,24 @@ func Prepare() error {
 			"statistics_interval"	INTEGER NOT NULL,
 			"sum_files_downloaded"	INTEGER DEFAULT 0,
 			"min_free_disk_space"	INTEGER NOT NULL,
+			"weight"	INTEGER NOT NULL DEFAULT 1,
+			"max_concurrent_downloads"	INTEGER NOT NULL DEFAULT 0,
+			"max_server_load"	REAL NOT NULL DEFAULT 0,
+			"categories"	TEXT NOT NULL DEFAULT '[]',
 			PRIMARY KEY("uid")
 		)`)
 
 	stmts = append(stmts, `CREATE UNIQUE INDEX IF NOT EXISTS "fileservers_uid" ON "fileservers" ("uid")`)
 
+	// metadata_cache
+	stmts = append(stmts,
+		`CREATE TABLE IF NOT EXISTS "metadata_cache" (
+			"key"	TEXT NOT NULL UNIQUE,
+			"data"	TEXT NOT NULL DEFAULT '',
+			"added"	TEXT NOT NULL,
+			PRIMARY KEY("key")
+		)`)
+
 	// log
 	stmts = append(stmts,
 		`CREATE TABLE IF NOT EXISTS "log" (
@@ -56,6 +101,7 @@ func Prepare() error {
 		`CREATE TABLE IF NOT EXISTS "releases" (
 			"uid"	TEXT NOT NULL UNIQUE,
 			"hash"	TEXT NOT NULL,
+			"hash_v2"	TEXT NOT NULL DEFAULT '',
 			"name"	TEXT NOT NULL UNIQUE,
 			"name_raw"	TEXT NOT NULL,
 			"state"	TEXT NOT NULL,
@@ -67,6 +113,8 @@ func Prepare() error {
 			"uploaded"	TEXT,
 			"source_uid"	TEXT NOT NULL,
 			"fileserver_uid"	TEXT DEFAULT '',
+			"external_ids"	TEXT NOT NULL DEFAULT '{}',
+			"metadata"	TEXT NOT NULL DEFAULT '',
 			PRIMARY KEY("uid")
 		)`)
 
@@ -86,6 +134,25 @@ func Prepare() error {
 
 	stmts = append(stmts, `CREATE INDEX IF NOT EXISTS "release_metafiles_uid" ON "release_metafiles" ("release_uid")`)
 
+	// release_uploads
+	stmts = append(stmts,
+		`CREATE TABLE IF NOT EXISTS "release_uploads" (
+			"release_uid"	TEXT NOT NULL,
+			"destination_uid"	TEXT NOT NULL,
+			"state"	TEXT NOT NULL,
+			"message"	TEXT NOT NULL DEFAULT '',
+			"hash"	TEXT NOT NULL DEFAULT '',
+			"original_hash"	TEXT NOT NULL DEFAULT '',
+			"attempt_key"	TEXT NOT NULL DEFAULT '',
+			"attempt_payload"	TEXT NOT NULL DEFAULT '',
+			"torrent_id"	TEXT NOT NULL DEFAULT '',
+			"torrent_url"	TEXT NOT NULL DEFAULT '',
+			"updated"	TEXT NOT NULL,
+			UNIQUE("release_uid", "destination_uid")
+		)`)
+
+	stmts = append(stmts, `CREATE INDEX IF NOT EXISTS "release_uploads_destination_uid" ON "release_uploads" ("destination_uid")`)
+
 	// sources
 	stmts = append(stmts,
 		`CREATE TABLE IF NOT EXISTS "sources" (
@@ -128,5 +195,30 @@ func Prepare() error {
 		return err
 	}
 
-	return nil
+	// columns added after the initial release
+	return addMissingColumns([]column{
+		{"fileservers", "weight", "INTEGER NOT NULL DEFAULT 1"},
+		{"fileservers", "max_concurrent_downloads", "INTEGER NOT NULL DEFAULT 0"},
+		{"fileservers", "max_server_load", "REAL NOT NULL DEFAULT 0"},
+		{"fileservers", "categories", "TEXT NOT NULL DEFAULT '[]'"},
+		{"destinations", "type", "TEXT NOT NULL DEFAULT 'ATUS'"},
+		{"destinations", "tracker_api_key", "TEXT NOT NULL DEFAULT ''"},
+		{"destinations", "anonymous", "INTEGER NOT NULL DEFAULT 0"},
+		{"destinations", "attribute_mapping", "TEXT NOT NULL DEFAULT '{}'"},
+		{"destinations", "description_template", "TEXT NOT NULL DEFAULT ''"},
+		{"destinations", "file_base_url", "TEXT NOT NULL DEFAULT ''"},
+		{"destinations", "dry_run", "INTEGER NOT NULL DEFAULT 0"},
+		{"release_uploads", "attempt_key", "TEXT NOT NULL DEFAULT ''"},
+		{"release_uploads", "torrent_id", "TEXT NOT NULL DEFAULT ''"},
+		{"release_uploads", "torrent_url", "TEXT NOT NULL DEFAULT ''"},
+		{"destinations", "source_tag", "TEXT NOT NULL DEFAULT ''"},
+		{"release_uploads", "original_hash", "TEXT NOT NULL DEFAULT ''"},
+		{"releases", "hash_v2", "TEXT NOT NULL DEFAULT ''"},
+		{"destinations", "recreate_torrent", "INTEGER NOT NULL DEFAULT 0"},
+		{"destinations", "piece_size_policy", "TEXT NOT NULL DEFAULT '[]'"},
+		{"destinations", "contact_sheet_field", "TEXT NOT NULL DEFAULT ''"},
+		{"releases", "external_ids", "TEXT NOT NULL DEFAULT '{}'"},
+		{"releases", "metadata", "TEXT NOT NULL DEFAULT ''"},
+		{"release_uploads", "attempt_payload", "TEXT NOT NULL DEFAULT ''"},
+	})
 }
@@ -0,0 +1,69 @@
+package video
+
+import (
+	"atus/backend/helpers"
+	"atus/backend/logger"
+	"context"
+	"fmt"
+	"os"
+	"path"
+)
+
+// saveBento4 packages the streams with mp4fragment and mp4dash. DASH and HLS share the same fMP4 segments
+func (pv *PreparedVideo) saveBento4(ctx context.Context, savePath, name string, format OutputFormat) (*Manifests, error) {
+
+	// mp4dash always writes a DASH manifest, it is removed if only HLS is requested
+	mpdName := name + ".mpd"
+	mp4DashArgs := []string{
+		fmt.Sprintf("--mpd-name=%s", mpdName),
+		"-f",
+		"-o", savePath,
+	}
+
+	manifests := &Manifests{}
+	if format.HasDASH() {
+		manifests.DASH = mpdName
+	}
+
+	if format.HasHLS() {
+		manifests.HLS = name + ".m3u8"
+		mp4DashArgs = append(mp4DashArgs, "--hls", fmt.Sprintf("--hls-master-playlist-name=%s", manifests.HLS))
+	}
+
+	for _, s := range pv.PreparedStreams {
+
+		// video and audio streams need to be fragmented
+		if s.Stream.CodecType == "video" || s.Stream.CodecType == "audio" {
+
+			outputFile := path.Join(pv.tempDir, fmt.Sprintf("f-%s", s.FileName))
+			err := Fragment(ctx, path.Join(pv.tempDir, s.FileName), outputFile)
+			if err != nil {
+				return nil, err
+			}
+
+			mp4DashArgs = append(mp4DashArgs, outputFile)
+
+			continue
+		}
+
+		if s.Stream.CodecType == "subtitle" {
+
+			lang := subtitleName(s.Stream)
+			mp4DashArgs = append(mp4DashArgs, fmt.Sprintf("[+format=webvtt,+language=%s]%s", helpers.ReplaceNonAlphanumeric(lang, " "), path.Join(pv.tempDir, s.FileName)))
+			continue
+		}
+	}
+
+	ret, err := _exec(ctx, getMP4Dash(), mp4DashArgs...)
+	logger.Debugf("mp4dash %s", ret)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !format.HasDASH() {
+		os.Remove(path.Join(savePath, mpdName))
+	}
+
+	return manifests, nil
+}
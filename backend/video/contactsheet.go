@@ -0,0 +1,153 @@
+package video
+
+import (
+	"atus/backend/helpers"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	contactSheetThumbWidth = 320
+	contactSheetPadding    = 8
+	contactSheetLineHeight = 18
+)
+
+var (
+	contactSheetBackground = color.RGBA{25, 25, 25, 255}
+	contactSheetText       = color.RGBA{230, 230, 230, 255}
+	contactSheetLabel      = color.RGBA{0, 0, 0, 180}
+)
+
+// ContactSheet saves a grid of columns x rows frames taken at evenly spaced timestamps as jpeg.
+// The header shows the title, resolution, codec, duration and size of the video, every frame its timestamp
+func (v *ProbedVideo) ContactSheet(ctx context.Context, savePath, title string, columns, rows int) error {
+
+	if columns < 1 || rows < 1 {
+		return fmt.Errorf("invalid grid size %dx%d", columns, rows)
+	}
+
+	duration, err := v.Duration()
+	if err != nil {
+		return err
+	}
+
+	if duration <= 0 {
+		return fmt.Errorf("video has no duration")
+	}
+
+	tempDir, err := ioutil.TempDir("", "atus_contact_sheet")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	// +1 so that the first and the last frame are not at the very beginning and the very end of the video
+	sumFrames := columns * rows
+	frames := make([]image.Image, sumFrames)
+	timestamps := make([]time.Duration, sumFrames)
+	for i := range frames {
+
+		timestamps[i] = duration * time.Duration(i+1) / time.Duration(sumFrames+1)
+		frameFile := path.Join(tempDir, fmt.Sprintf("%d.jpg", i))
+
+		// seeking before the input is fast and accurate enough for thumbnails
+		_, err := _exec(ctx, getFFMPEG(), "-y", "-hide_banner", "-loglevel", "warning",
+			"-ss", fmt.Sprintf("%.3f", timestamps[i].Seconds()), "-i", v.File,
+			"-frames:v", "1", "-vf", fmt.Sprintf("scale=%d:-2", contactSheetThumbWidth), "-q:v", "3", frameFile)
+		if err != nil {
+			return fmt.Errorf("error extracting frame at %s: %v", timestamps[i], err)
+		}
+
+		if frames[i], err = decodeJPEG(frameFile); err != nil {
+			return fmt.Errorf("error decoding frame at %s: %v", timestamps[i], err)
+		}
+	}
+
+	thumbHeight := frames[0].Bounds().Dy()
+	headerHeight := contactSheetPadding*2 + contactSheetLineHeight*2
+	width := columns*contactSheetThumbWidth + (columns+1)*contactSheetPadding
+	height := headerHeight + rows*(thumbHeight+contactSheetPadding)
+
+	img := helpers.CreateImage(width, height, contactSheetBackground)
+
+	// header
+	maxChars := (width - contactSheetPadding*2) / 8
+	img.AddText(truncate(title, maxChars), contactSheetText, contactSheetPadding, contactSheetPadding)
+	img.AddText(truncate(v.contactSheetInfo(duration), maxChars), contactSheetText, contactSheetPadding, contactSheetPadding+contactSheetLineHeight)
+
+	// frames
+	for i, frame := range frames {
+		x := contactSheetPadding + (i%columns)*(contactSheetThumbWidth+contactSheetPadding)
+		y := headerHeight + (i/columns)*(thumbHeight+contactSheetPadding)
+
+		draw.Draw(img, image.Rect(x, y, x+contactSheetThumbWidth, y+thumbHeight), frame, frame.Bounds().Min, draw.Src)
+
+		// timestamp in the bottom right corner
+		label := formatTimestamp(timestamps[i])
+		labelRect := image.Rect(x+contactSheetThumbWidth-len(label)*8-8, y+thumbHeight-20, x+contactSheetThumbWidth, y+thumbHeight)
+		draw.Draw(img, labelRect, image.NewUniform(contactSheetLabel), image.Point{}, draw.Over)
+		img.AddText(label, contactSheetText, labelRect.Min.X+4, labelRect.Min.Y+2)
+	}
+
+	return img.Save(savePath)
+
+}
+
+// contactSheetInfo returns the second header line of the contact sheet
+func (v *ProbedVideo) contactSheetInfo(duration time.Duration) string {
+
+	info := []string{}
+	for _, s := range v.ProbeData.Streams {
+		if s.CodecType != "video" || s.Width == 0 {
+			continue
+		}
+
+		info = append(info, fmt.Sprintf("%dx%d", s.Width, s.Height), s.CodecName)
+		break
+	}
+
+	info = append(info, formatTimestamp(duration))
+
+	if fileInfo, err := os.Stat(v.File); err == nil {
+		info = append(info, fmt.Sprintf("%.2f MiB", float64(fileInfo.Size())/float64(helpers.MiB)))
+	}
+
+	return strings.Join(info, " | ")
+
+}
+
+func decodeJPEG(file string) (image.Image, error) {
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return jpeg.Decode(f)
+
+}
+
+// formatTimestamp formats d as hh:mm:ss
+func formatTimestamp(d time.Duration) string {
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
+
+// truncate shortens s to max characters
+func truncate(s string, max int) string {
+	r := []rune(s)
+	if max < 4 || len(r) <= max {
+		return s
+	}
+	return string(r[:max-3]) + "..."
+}
@@ -2,10 +2,30 @@ package video
 
 import (
 	"atus/backend/config"
+	"errors"
 	"fmt"
 	"os/exec"
+	"sync"
 )
 
+// Packager writes the streaming manifests and segments of a prepared video
+type Packager string
+
+const (
+	PackagerFFMPEG Packager = "FFMPEG" // ffmpeg's dash and hls muxers
+	PackagerBento4 Packager = "BENTO4" // mp4fragment and mp4dash
+)
+
+// ParsePackager returns an error for unknown packagers
+func ParsePackager(s string) (Packager, error) {
+	switch p := Packager(s); p {
+	case PackagerFFMPEG, PackagerBento4:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown packager: %s", s)
+	}
+}
+
 func getFFMPEG() string {
 	if config.Base.Dependencies.FFMPEG != "" {
 		return config.Base.Dependencies.FFMPEG
@@ -39,7 +59,29 @@ func isInstalled(name string) bool {
 	return err == nil
 }
 
-func CheckDependencies() error {
+var (
+	dependencyMutex sync.RWMutex
+	dependencyErr   = errors.New("dependencies have not been checked")
+)
+
+// CheckDependencies checks that the programs required by the packager are installed.
+// ffmpeg and ffprobe are always required. The result is returned by DependencyError.
+func CheckDependencies(p Packager) error {
+
+	err := checkDependencies(p)
+	SetDependencyError(err)
+
+	return err
+}
+
+// SetDependencyError sets the error returned by DependencyError, e.g. if the packager can't be checked
+func SetDependencyError(err error) {
+	dependencyMutex.Lock()
+	dependencyErr = err
+	dependencyMutex.Unlock()
+}
+
+func checkDependencies(p Packager) error {
 	if !isInstalled(getFFMPEG()) {
 		return fmt.Errorf("ffmpeg is not installed")
 	}
@@ -48,6 +90,10 @@ func CheckDependencies() error {
 		return fmt.Errorf("ffprobe is not installed")
 	}
 
+	if p != PackagerBento4 {
+		return nil
+	}
+
 	if !isInstalled(getMP4Fragment()) {
 		return fmt.Errorf("mp4fragment is not installed")
 	}
@@ -58,3 +104,10 @@ func CheckDependencies() error {
 
 	return nil
 }
+
+// DependencyError returns the result of the last CheckDependencies. Samples can't be processed if it is not nil
+func DependencyError() error {
+	dependencyMutex.RLock()
+	defer dependencyMutex.RUnlock()
+	return dependencyErr
+}
@@ -1,14 +1,19 @@
 package video
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"os/exec"
+	"strconv"
+	"strings"
+	"time"
 )
 
-func _exec(name string, args ...string) ([]byte, error) {
+func _exec(ctx context.Context, name string, args ...string) ([]byte, error) {
 
-	cmd := exec.Command(name, args...)
+	cmd := exec.CommandContext(ctx, name, args...)
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stderr = &stderr
@@ -16,8 +21,68 @@ func _exec(name string, args ...string) ([]byte, error) {
 
 	err := cmd.Run()
 	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
 		return nil, fmt.Errorf("error: %s; stdout: %s, stderr: %s", err, stdout.String(), stderr.String())
 	}
 
 	return stdout.Bytes(), nil
 }
+
+// _execFFMPEG runs ffmpeg and calls onProgress with the duration of the output that has been written so far.
+// ffmpeg writes its progress as key=value lines to stdout (-progress pipe:1)
+func _execFFMPEG(ctx context.Context, onProgress func(time.Duration), args ...string) ([]byte, error) {
+
+	if onProgress == nil {
+		return _exec(ctx, getFFMPEG(), args...)
+	}
+
+	cmd := exec.CommandContext(ctx, getFFMPEG(), append([]string{"-progress", "pipe:1", "-nostats"}, args...)...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		if d, ok := parseProgress(scanner.Text()); ok {
+			onProgress(d)
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, fmt.Errorf("error: %s; stderr: %s", err, stderr.String())
+	}
+
+	return stderr.Bytes(), nil
+}
+
+// parseProgress parses the out_time_us line of ffmpegs progress output.
+// out_time_ms is also in microseconds, older versions of ffmpeg only write this key
+func parseProgress(line string) (time.Duration, bool) {
+
+	key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+	if !ok || (key != "out_time_us" && key != "out_time_ms") {
+		return 0, false
+	}
+
+	// N/A before the first frame is written
+	us, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || us < 0 {
+		return 0, false
+	}
+
+	return time.Duration(us) * time.Microsecond, true
+}
@@ -1,11 +1,22 @@
 package video
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"time"
 )
 
-func (v *ProbedVideo) Extract(s *Stream, newFile string) ([]byte, error) {
+// Extract writes a single stream to newFile, transcoded as set by the profile.
+// onProgress receives the duration that has been written so far and may be nil
+func (v *ProbedVideo) Extract(ctx context.Context, s *Stream, newFile string, profile *Profile, onProgress func(time.Duration)) ([]byte, error) {
+
+	args := []string{"-y", "-hide_banner", "-loglevel", "warning", "-i", v.File}
+
+	// cap the length of the clip
+	if profile.MaxDuration > 0 {
+		args = append(args, "-t", fmt.Sprintf("%d", profile.MaxDuration))
+	}
 
 	switch s.CodecType {
 	case "video":
@@ -17,24 +28,31 @@ func (v *ProbedVideo) Extract(s *Stream, newFile string) ([]byte, error) {
 			return nil, fmt.Errorf("stream %s not supported", s.CodecLongName)
 		}
 
-		// extract video and convert to x264
-		return _exec("ffmpeg", "-y", "-hide_banner", "-loglevel", "warning", "-i", v.File, "-c:v", "libx264", "-vsync", "2", "-an", "-map", fmt.Sprintf("0:%d", s.Index), newFile)
+		// extract video, convert to x264 if it can't be copied
+		args = append(args, profile.videoArgs(s)...)
+		args = append(args, "-an")
 
 	case "audio":
-		// extract audio and convert to aac (ch2)
-		return _exec("ffmpeg", "-y", "-hide_banner", "-loglevel", "warning", "-i", v.File, "-c:a", "aac", "-ac", "2", "-vn", "-map", fmt.Sprintf("0:%d", s.Index), newFile)
+		// extract audio, convert to aac (ch2) if it can't be copied
+		args = append(args, profile.audioArgs(s)...)
+		args = append(args, "-vn")
 
 	case "subtitle":
 		// extract subtitle
-		return _exec("ffmpeg", "-y", "-hide_banner", "-loglevel", "warning", "-i", v.File, "-vn", "-an", "-map", fmt.Sprintf("0:%d", s.Index), newFile)
+		args = append(args, "-vn", "-an")
 
 	default:
 		return nil, fmt.Errorf("unsupported codec type: %s", s.CodecType)
 
 	}
+
+	args = append(args, "-map", fmt.Sprintf("0:%d", s.Index), newFile)
+
+	return _execFFMPEG(ctx, onProgress, args...)
 }
 
-func (v *ProbedVideo) ExtractFrame(timestamp, newFile string) error {
-	_, err := _exec("ffmpeg", "-y", "-hide_banner", "-loglevel", "warning", "-i", v.File, "-vsync", "2", "-ss", timestamp, "-frames:v", "1", "-update", "1", "-q:v", "2", newFile)
+func (v *ProbedVideo) ExtractFrame(ctx context.Context, timestamp, newFile string) error {
+	// seeking before the input only reads the data around the timestamp, which is required for remote files
+	_, err := _exec(ctx, getFFMPEG(), "-y", "-hide_banner", "-loglevel", "warning", "-ss", timestamp, "-i", v.File, "-vsync", "2", "-frames:v", "1", "-update", "1", "-q:v", "2", newFile)
 	return err
 }
@@ -0,0 +1,243 @@
+package video
+
+import (
+	"atus/backend/logger"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// segment length in seconds
+const segmentDuration = "4"
+
+// saveFFMPEG packages the streams with ffmpeg's dash and hls muxers.
+// The dash muxer doesn't support subtitles, they are added to the DASH manifest as WebVTT side files
+func (pv *PreparedVideo) saveFFMPEG(ctx context.Context, savePath, name string, format OutputFormat) (*Manifests, error) {
+
+	manifests := &Manifests{}
+
+	if format.HasDASH() {
+		manifests.DASH = name + ".mpd"
+		if err := pv.saveDASH(ctx, path.Join(savePath, manifests.DASH), name+"-dash"); err != nil {
+			return nil, err
+		}
+
+		if err := pv.addDASHSubtitles(savePath, manifests.DASH, name+"-dash"); err != nil {
+			return nil, err
+		}
+	}
+
+	if format.HasHLS() {
+		manifests.HLS = name + ".m3u8"
+		if err := pv.saveHLS(ctx, savePath, manifests.HLS, name+"-hls"); err != nil {
+			return nil, err
+		}
+	}
+
+	return manifests, nil
+}
+
+// saveDASH writes a DASH manifest with all video and audio streams. Segments are prefixed to keep samples apart
+func (pv *PreparedVideo) saveDASH(ctx context.Context, mpdFile, prefix string) error {
+
+	args := []string{"-y", "-hide_banner", "-loglevel", "warning"}
+
+	var maps []string
+	for _, s := range pv.PreparedStreams {
+		if s.Stream.CodecType != "video" && s.Stream.CodecType != "audio" {
+			continue
+		}
+
+		args = append(args, "-i", path.Join(pv.tempDir, s.FileName))
+		maps = append(maps, "-map", fmt.Sprintf("%d", len(maps)/2))
+	}
+
+	args = append(args, maps...)
+	args = append(args,
+		"-c", "copy",
+		"-f", "dash",
+		"-seg_duration", segmentDuration,
+		"-use_template", "1",
+		"-use_timeline", "1",
+		"-init_seg_name", prefix+"-init-$RepresentationID$.m4s",
+		"-media_seg_name", prefix+"-$RepresentationID$-$Number%05d$.m4s",
+		mpdFile,
+	)
+
+	ret, err := _exec(ctx, getFFMPEG(), args...)
+	logger.Debugf("ffmpeg dash %s", ret)
+
+	return err
+}
+
+// dashSubtitle is a WebVTT side file of a DASH manifest
+type dashSubtitle struct {
+	id       string
+	fileName string // relative to the manifest
+	language string
+	label    string
+}
+
+// addDASHSubtitles copies the WebVTT files of the subtitle streams next to the manifest and adds them to it
+func (pv *PreparedVideo) addDASHSubtitles(savePath, mpdName, prefix string) error {
+
+	var subtitles []*dashSubtitle
+	for _, s := range pv.PreparedStreams {
+		if s.Stream.CodecType != "subtitle" {
+			continue
+		}
+
+		buf, err := os.ReadFile(path.Join(pv.tempDir, s.FileName))
+		if err != nil {
+			return err
+		}
+
+		sub := &dashSubtitle{
+			id:       fmt.Sprintf("sub-%d", s.Stream.Index),
+			fileName: fmt.Sprintf("%s-sub-%d.vtt", prefix, s.Stream.Index),
+			label:    subtitleName(s.Stream),
+		}
+
+		if s.Stream.Tag != nil {
+			sub.language = s.Stream.Tag.Language
+		}
+
+		if err := os.WriteFile(path.Join(savePath, sub.fileName), buf, 0644); err != nil {
+			return err
+		}
+
+		subtitles = append(subtitles, sub)
+	}
+
+	if len(subtitles) == 0 {
+		return nil
+	}
+
+	mpdFile := path.Join(savePath, mpdName)
+	mpd, err := os.ReadFile(mpdFile)
+	if err != nil {
+		return err
+	}
+
+	mpd, err = insertDASHSubtitles(mpd, subtitles)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(mpdFile, mpd, 0644)
+}
+
+// insertDASHSubtitles adds an adaptation set for every subtitle to the last period of the manifest
+func insertDASHSubtitles(mpd []byte, subtitles []*dashSubtitle) ([]byte, error) {
+
+	end := bytes.LastIndex(mpd, []byte("</Period>"))
+	if end < 0 {
+		return nil, errors.New("manifest has no period")
+	}
+
+	escape := func(s string) string {
+		var buf bytes.Buffer
+		xml.EscapeText(&buf, []byte(s))
+		return buf.String()
+	}
+
+	var sets bytes.Buffer
+	for _, sub := range subtitles {
+		lang := ""
+		if sub.language != "" {
+			lang = fmt.Sprintf(` lang="%s"`, escape(sub.language))
+		}
+
+		fmt.Fprintf(&sets, "\t\t<AdaptationSet contentType=\"text\" mimeType=\"text/vtt\"%s>\n", lang)
+		sets.WriteString("\t\t\t<Role schemeIdUri=\"urn:mpeg:dash:role:2011\" value=\"subtitle\"/>\n")
+		fmt.Fprintf(&sets, "\t\t\t<Label>%s</Label>\n", escape(sub.label))
+		fmt.Fprintf(&sets, "\t\t\t<Representation id=\"%s\" bandwidth=\"256\">\n", escape(sub.id))
+		fmt.Fprintf(&sets, "\t\t\t\t<BaseURL>%s</BaseURL>\n", escape(sub.fileName))
+		sets.WriteString("\t\t\t</Representation>\n")
+		sets.WriteString("\t\t</AdaptationSet>\n")
+	}
+
+	// the closing tag is indented by the muxer
+	lineStart := bytes.LastIndexByte(mpd[:end], '\n') + 1
+
+	out := make([]byte, 0, len(mpd)+sets.Len())
+	out = append(out, mpd[:lineStart]...)
+	out = append(out, sets.Bytes()...)
+	out = append(out, mpd[lineStart:]...)
+
+	return out, nil
+}
+
+// hlsStreamTypes maps codec types to the stream types of the var_stream_map
+var hlsStreamTypes = map[string]string{
+	"video":    "v",
+	"audio":    "a",
+	"subtitle": "s",
+}
+
+// saveHLS writes a HLS master playlist with fMP4 segments and WebVTT subtitles.
+// A variant can only contain one stream of each type, additional audio and subtitle streams are skipped.
+func (pv *PreparedVideo) saveHLS(ctx context.Context, savePath, masterName, prefix string) error {
+
+	args := []string{"-y", "-hide_banner", "-loglevel", "warning"}
+
+	var maps, metadata []string
+	variant := map[string]string{}
+	for _, s := range pv.PreparedStreams {
+		// data and attachment streams can't be part of a variant, ffprobe reports no codec type for some of them
+		t, ok := hlsStreamTypes[s.Stream.CodecType]
+		if !ok {
+			continue
+		}
+
+		if _, ok := variant[t]; ok {
+			continue
+		}
+
+		input := len(maps) / 2
+		args = append(args, "-i", path.Join(pv.tempDir, s.FileName))
+		maps = append(maps, "-map", fmt.Sprintf("%d", input))
+		variant[t] = fmt.Sprintf("%s:0", t)
+
+		if t == "s" {
+			variant[t] += ",sgroup:subs"
+			if s.Stream.Tag != nil && s.Stream.Tag.Language != "" {
+				metadata = append(metadata, "-metadata:s:s:0", "language="+s.Stream.Tag.Language)
+			}
+		}
+	}
+
+	var streamMap []string
+	for _, t := range []string{"v", "a", "s"} {
+		if v, ok := variant[t]; ok {
+			streamMap = append(streamMap, v)
+		}
+	}
+
+	args = append(args, maps...)
+	args = append(args, metadata...)
+	args = append(args,
+		"-c:v", "copy",
+		"-c:a", "copy",
+		"-c:s", "webvtt",
+		"-f", "hls",
+		"-hls_time", segmentDuration,
+		"-hls_playlist_type", "vod",
+		"-hls_segment_type", "fmp4",
+		"-hls_fmp4_init_filename", prefix+"-init.mp4",
+		"-hls_segment_filename", path.Join(savePath, prefix+"-%05d.m4s"),
+		"-master_pl_name", masterName,
+		"-var_stream_map", strings.Join(streamMap, ","),
+		path.Join(savePath, prefix+".m3u8"),
+	)
+
+	ret, err := _exec(ctx, getFFMPEG(), args...)
+	logger.Debugf("ffmpeg hls %s", ret)
+
+	return err
+}
@@ -0,0 +1,67 @@
+package video
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+const testMPD = `<?xml version="1.0" encoding="utf-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="static">
+	<Period id="0" start="PT0.0S">
+		<AdaptationSet id="0" contentType="video">
+		</AdaptationSet>
+	</Period>
+</MPD>
+`
+
+func TestInsertDASHSubtitles(t *testing.T) {
+
+	mpd, err := insertDASHSubtitles([]byte(testMPD), []*dashSubtitle{
+		{id: "sub-2", fileName: "sample-dash-sub-2.vtt", language: "eng", label: "English"},
+		{id: "sub-3", fileName: "sample-dash-sub-3.vtt", label: "Forced & <SDH>"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var parsed struct {
+		Periods []struct {
+			AdaptationSets []struct {
+				ContentType     string `xml:"contentType,attr"`
+				MimeType        string `xml:"mimeType,attr"`
+				Lang            string `xml:"lang,attr"`
+				Label           string `xml:"Label"`
+				Representations []struct {
+					ID      string `xml:"id,attr"`
+					BaseURL string `xml:"BaseURL"`
+				} `xml:"Representation"`
+			} `xml:"AdaptationSet"`
+		} `xml:"Period"`
+	}
+
+	if err := xml.Unmarshal(mpd, &parsed); err != nil {
+		t.Fatalf("invalid manifest: %s\n%s", err, mpd)
+	}
+
+	if len(parsed.Periods) != 1 || len(parsed.Periods[0].AdaptationSets) != 3 {
+		t.Fatalf("expected 3 adaptation sets in 1 period, got %s", mpd)
+	}
+
+	sets := parsed.Periods[0].AdaptationSets
+	if sets[0].ContentType != "video" {
+		t.Errorf("expected the video adaptation set to come first, got %+v", sets[0])
+	}
+
+	if s := sets[1]; s.MimeType != "text/vtt" || s.Lang != "eng" || s.Label != "English" || s.Representations[0].BaseURL != "sample-dash-sub-2.vtt" {
+		t.Errorf("unexpected subtitle adaptation set %+v", s)
+	}
+
+	if s := sets[2]; s.Lang != "" || s.Label != "Forced & <SDH>" || s.Representations[0].ID != "sub-3" {
+		t.Errorf("unexpected subtitle adaptation set %+v", s)
+	}
+
+	if _, err := insertDASHSubtitles([]byte("<MPD></MPD>"), nil); err == nil {
+		t.Error("expected an error for a manifest without period")
+	}
+
+}
@@ -1,6 +1,8 @@
 package video
 
-func Fragment(sourceFile, outputFile string) error {
-	_, err := _exec("mp4fragment", sourceFile, outputFile)
+import "context"
+
+func Fragment(ctx context.Context, sourceFile, outputFile string) error {
+	_, err := _exec(ctx, getMP4Fragment(), sourceFile, outputFile)
 	return err
 }
@@ -0,0 +1,271 @@
+package video
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"math/bits"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// frames darker or brighter than this mean luma are black or white frames (fades, intros, credits)
+	minFrameLuma = 20
+	maxFrameLuma = 235
+
+	// frames with a lower laplacian variance are blurry (motion, scene transitions)
+	minFrameSharpness = 30
+
+	// frames with a hash distance up to this value are considered identical
+	maxDuplicateDistance = 6
+
+	// frames are scored at this width so the sharpness doesn't depend on the resolution
+	scoreWidth = 640
+)
+
+// ErrNoUsableFrame is returned by BestFrame if no candidate could be extracted or all candidates are duplicates
+var ErrNoUsableFrame = errors.New("no usable frame found")
+
+// FrameScore describes the quality of a screenshot
+type FrameScore struct {
+	Luma      float64 // mean luma, 0-255
+	Sharpness float64 // variance of the laplacian of the luma, higher is sharper
+	Hash      uint64  // perceptual difference hash
+}
+
+// Usable returns false for black, white and blurry frames
+func (s *FrameScore) Usable() bool {
+	return s.Luma >= minFrameLuma && s.Luma <= maxFrameLuma && s.Sharpness >= minFrameSharpness
+}
+
+// IsDuplicate returns true if the frame looks like one of the given hashes
+func (s *FrameScore) IsDuplicate(hashes []uint64) bool {
+	for _, h := range hashes {
+		if bits.OnesCount64(s.Hash^h) <= maxDuplicateDistance {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatHash returns the hash as it is stored in meta file infos
+func (s *FrameScore) FormatHash() string {
+	return fmt.Sprintf("%016x", s.Hash)
+}
+
+// ParseHash parses a hash returned by FormatHash
+func ParseHash(s string) (uint64, error) {
+	return strconv.ParseUint(s, 16, 64)
+}
+
+// ScoreFrame scores a jpeg screenshot
+func ScoreFrame(file string) (*FrameScore, error) {
+
+	img, err := decodeJPEG(file)
+	if err != nil {
+		return nil, err
+	}
+
+	gray := toGray(img, scoreWidth)
+	if len(gray) < 3 || len(gray[0]) < 3 {
+		return nil, fmt.Errorf("frame too small")
+	}
+
+	return &FrameScore{
+		Luma:      meanLuma(gray),
+		Sharpness: laplacianVariance(gray),
+		Hash:      differenceHash(gray),
+	}, nil
+
+}
+
+// BestFrame extracts a frame at every timestamp and saves the sharpest usable one as newFile.
+// Frames that look like one of the excluded hashes are skipped. If no frame is usable, the best of the
+// remaining frames is saved, check Usable of the returned score. Returns ErrNoUsableFrame if no frame could be
+// extracted or all frames are duplicates
+func (v *ProbedVideo) BestFrame(ctx context.Context, timestamps []time.Duration, newFile string, exclude []uint64) (*FrameScore, time.Duration, error) {
+
+	var best, fallback *FrameScore
+	var bestTimestamp, fallbackTimestamp time.Duration
+	var bestFile, fallbackFile string
+	var lastErr error
+
+	for i, ts := range timestamps {
+
+		candidateFile := fmt.Sprintf("%s.candidate-%d.jpg", newFile, i)
+		defer os.Remove(candidateFile)
+
+		// a single broken frame doesn't discard the other candidates
+		if err := v.ExtractFrame(ctx, fmt.Sprintf("%.3f", ts.Seconds()), candidateFile); err != nil {
+			if ctx.Err() != nil {
+				return nil, 0, ctx.Err()
+			}
+			lastErr = err
+			continue
+		}
+
+		// ffmpeg sometimes creates a 0 byte file, those can't be decoded and are skipped
+		score, err := ScoreFrame(candidateFile)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if score.IsDuplicate(exclude) {
+			continue
+		}
+
+		if !score.Usable() {
+			if fallback == nil || score.betterFallback(fallback) {
+				fallback = score
+				fallbackTimestamp = ts
+				fallbackFile = candidateFile
+			}
+			continue
+		}
+
+		if best == nil || score.Sharpness > best.Sharpness {
+			best = score
+			bestTimestamp = ts
+			bestFile = candidateFile
+		}
+	}
+
+	if best == nil {
+		best, bestTimestamp, bestFile = fallback, fallbackTimestamp, fallbackFile
+	}
+
+	if best == nil {
+		if lastErr != nil {
+			return nil, 0, fmt.Errorf("%w: %v", ErrNoUsableFrame, lastErr)
+		}
+		return nil, 0, ErrNoUsableFrame
+	}
+
+	if err := os.Rename(bestFile, newFile); err != nil {
+		return nil, 0, err
+	}
+
+	return best, bestTimestamp, nil
+
+}
+
+// betterFallback returns true if the unusable frame s is a better screenshot than the unusable frame other.
+// Frames with a usable brightness win over black and white frames, then the sharper frame wins
+func (s *FrameScore) betterFallback(other *FrameScore) bool {
+	lumaOK := s.Luma >= minFrameLuma && s.Luma <= maxFrameLuma
+	otherLumaOK := other.Luma >= minFrameLuma && other.Luma <= maxFrameLuma
+	if lumaOK != otherLumaOK {
+		return lumaOK
+	}
+	return s.Sharpness > other.Sharpness
+}
+
+// toGray converts img to luma values, downscaled to at most maxWidth
+func toGray(img image.Image, maxWidth int) [][]float64 {
+
+	b := img.Bounds()
+	step := 1
+	if b.Dx() > maxWidth {
+		step = int(math.Ceil(float64(b.Dx()) / float64(maxWidth)))
+	}
+
+	var gray [][]float64
+	for y := b.Min.Y; y < b.Max.Y; y += step {
+		row := make([]float64, 0, b.Dx()/step+1)
+		for x := b.Min.X; x < b.Max.X; x += step {
+			r, g, b, _ := img.At(x, y).RGBA()
+			row = append(row, (0.299*float64(r)+0.587*float64(g)+0.114*float64(b))/257)
+		}
+		gray = append(gray, row)
+	}
+
+	return gray
+
+}
+
+func meanLuma(gray [][]float64) float64 {
+
+	var sum float64
+	var n int
+	for _, row := range gray {
+		for _, v := range row {
+			sum += v
+			n++
+		}
+	}
+
+	return sum / float64(n)
+
+}
+
+// laplacianVariance returns the variance of the 4-neighbour laplacian. Blurry frames have few edges and a low variance
+func laplacianVariance(gray [][]float64) float64 {
+
+	var sum, sumSq float64
+	var n int
+	for y := 1; y < len(gray)-1; y++ {
+		for x := 1; x < len(gray[y])-1; x++ {
+			l := 4*gray[y][x] - gray[y-1][x] - gray[y+1][x] - gray[y][x-1] - gray[y][x+1]
+			sum += l
+			sumSq += l * l
+			n++
+		}
+	}
+
+	mean := sum / float64(n)
+	return sumSq/float64(n) - mean*mean
+
+}
+
+// differenceHash scales the frame down to 9x8 and sets a bit for every pixel that is brighter than its right neighbour
+func differenceHash(gray [][]float64) uint64 {
+
+	const w, h = 9, 8
+
+	var cells [h][w]float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			cells[y][x] = meanArea(gray, x*len(gray[0])/w, y*len(gray)/h, (x+1)*len(gray[0])/w, (y+1)*len(gray)/h)
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w-1; x++ {
+			hash <<= 1
+			if cells[y][x] > cells[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash
+
+}
+
+// meanArea returns the mean luma of the area from x0, y0 to x1, y1 (exclusive)
+func meanArea(gray [][]float64, x0, y0, x1, y1 int) float64 {
+
+	if x1 <= x0 {
+		x1 = x0 + 1
+	}
+
+	if y1 <= y0 {
+		y1 = y0 + 1
+	}
+
+	var sum float64
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			sum += gray[y][x]
+		}
+	}
+
+	return sum / float64((x1-x0)*(y1-y0))
+
+}
@@ -0,0 +1,103 @@
+package video
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// newTestImage creates a grayscale image with the luma of every pixel returned by f
+func newTestImage(w, h int, f func(x, y int) uint8) image.Image {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetGray(x, y, color.Gray{Y: f(x, y)})
+		}
+	}
+	return img
+}
+
+func TestFrameScore(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		img       image.Image
+		luma      float64
+		sharpness float64
+		hash      uint64
+	}{
+		{
+			"black",
+			newTestImage(90, 80, func(x, y int) uint8 { return 0 }),
+			0, 0, 0,
+		},
+		{
+			"uniform gray",
+			newTestImage(90, 80, func(x, y int) uint8 { return 128 }),
+			128, 0, 0,
+		},
+		{
+			"checkerboard",
+			newTestImage(90, 80, func(x, y int) uint8 { return uint8((x + y) % 2 * 255) }),
+			127.5, 1020 * 1020, 0,
+		},
+		{
+			"darker to the right",
+			newTestImage(90, 80, func(x, y int) uint8 { return uint8(255 - 2*x) }),
+			166, 0, math.MaxUint64,
+		},
+		{
+			"brighter to the right",
+			newTestImage(90, 80, func(x, y int) uint8 { return uint8(2 * x) }),
+			89, 0, 0,
+		},
+		{
+			"bright left half",
+			newTestImage(90, 80, func(x, y int) uint8 {
+				if x < 45 {
+					return 255
+				}
+				return 0
+			}),
+			// only the two columns at the edge have a laplacian of -255 and 255, 2 of 88 columns.
+			// The middle cell of the hash is half bright, darker than its left and brighter than its right neighbour
+			127.5, 255 * 255 * 2 / 88.0, 0x1818181818181818,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gray := toGray(tt.img, scoreWidth)
+
+			if luma := meanLuma(gray); math.Abs(luma-tt.luma) > 0.01 {
+				t.Errorf("expected mean luma %.2f, got %.2f", tt.luma, luma)
+			}
+
+			if sharpness := laplacianVariance(gray); math.Abs(sharpness-tt.sharpness) > 0.01 {
+				t.Errorf("expected laplacian variance %.2f, got %.2f", tt.sharpness, sharpness)
+			}
+
+			if hash := differenceHash(gray); hash != tt.hash {
+				t.Errorf("expected hash %016x, got %016x", tt.hash, hash)
+			}
+		})
+	}
+
+}
+
+func TestFrameScore_betterFallback(t *testing.T) {
+
+	black := &FrameScore{Luma: 5, Sharpness: 500}
+	blurry := &FrameScore{Luma: 100, Sharpness: 10}
+	blurrier := &FrameScore{Luma: 100, Sharpness: 5}
+
+	if !blurry.betterFallback(black) || black.betterFallback(blurry) {
+		t.Error("expected a blurry frame to win over a black frame")
+	}
+
+	if !blurry.betterFallback(blurrier) || blurrier.betterFallback(blurry) {
+		t.Error("expected the sharper frame to win")
+	}
+
+}
@@ -0,0 +1,44 @@
+package video
+
+import "fmt"
+
+// OutputFormat selects the streaming manifests written by PreparedVideo.Save
+type OutputFormat string
+
+const (
+	OutputFormatDASH    OutputFormat = "DASH"
+	OutputFormatHLS     OutputFormat = "HLS" // Safari and most embedded players only support HLS
+	OutputFormatDASHHLS OutputFormat = "DASH_HLS"
+)
+
+// ParseOutputFormat returns an error for unknown formats
+func ParseOutputFormat(s string) (OutputFormat, error) {
+	switch f := OutputFormat(s); f {
+	case OutputFormatDASH, OutputFormatHLS, OutputFormatDASHHLS:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown output format: %s", s)
+	}
+}
+
+func (f OutputFormat) HasDASH() bool {
+	return f == OutputFormatDASH || f == OutputFormatDASHHLS
+}
+
+func (f OutputFormat) HasHLS() bool {
+	return f == OutputFormatHLS || f == OutputFormatDASHHLS
+}
+
+// Manifests are the file names of the written manifests, relative to the save path. Empty if not written
+type Manifests struct {
+	DASH string // .mpd
+	HLS  string // .m3u8 master playlist
+}
+
+// Main returns the DASH manifest if it was written, the HLS playlist otherwise
+func (m *Manifests) Main() string {
+	if m.DASH != "" {
+		return m.DASH
+	}
+	return m.HLS
+}
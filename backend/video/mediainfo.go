@@ -0,0 +1,453 @@
+package video
+
+import (
+	"atus/backend/helpers"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// formats as they are named by MediaInfo
+var (
+	mediaInfoContainers = map[string]string{
+		"matroska,webm":           "Matroska",
+		"mov,mp4,m4a,3gp,3g2,mj2": "MPEG-4",
+		"mpegts":                  "MPEG-TS",
+		"avi":                     "AVI",
+		"mpeg":                    "MPEG-PS",
+		"asf":                     "Windows Media",
+	}
+
+	mediaInfoFormats = map[string]string{
+		"h264":              "AVC",
+		"hevc":              "HEVC",
+		"av1":               "AV1",
+		"vp9":               "VP9",
+		"mpeg2video":        "MPEG Video",
+		"mpeg4":             "MPEG-4 Visual",
+		"vc1":               "VC-1",
+		"aac":               "AAC",
+		"ac3":               "AC-3",
+		"eac3":              "E-AC-3",
+		"dts":               "DTS",
+		"truehd":            "MLP FBA",
+		"flac":              "FLAC",
+		"opus":              "Opus",
+		"vorbis":            "Vorbis",
+		"mp2":               "MPEG Audio",
+		"mp3":               "MPEG Audio",
+		"pcm_s16le":         "PCM",
+		"pcm_s24le":         "PCM",
+		"subrip":            "UTF-8",
+		"ass":               "ASS",
+		"ssa":               "SSA",
+		"webvtt":            "WebVTT",
+		"mov_text":          "Timed Text",
+		"hdmv_pgs_subtitle": "PGS",
+		"dvd_subtitle":      "VobSub",
+		"dvb_subtitle":      "DVB Subtitle",
+	}
+)
+
+// mediaInfoSection is a section of a MediaInfo report, e.g. "General" or "Audio #2"
+type mediaInfoSection struct {
+	name   string
+	fields [][2]string
+}
+
+func (s *mediaInfoSection) add(label, value string) {
+	if value != "" {
+		s.fields = append(s.fields, [2]string{label, value})
+	}
+}
+
+// MediaInfo returns a technical report of all streams in the text layout of MediaInfo.
+// name is written as "Complete name", the file name is used if it is empty
+func (v *ProbedVideo) MediaInfo(name string) string {
+
+	if name == "" {
+		name = path.Base(v.File)
+	}
+
+	sections := []*mediaInfoSection{v.mediaInfoGeneral(name)}
+
+	counts := map[string]int{}
+	for _, s := range v.ProbeData.Streams {
+		counts[s.CodecType]++
+	}
+
+	numbers := map[string]int{}
+	for _, s := range v.ProbeData.Streams {
+
+		var section *mediaInfoSection
+		switch s.CodecType {
+		case "video":
+			section = mediaInfoVideo(s)
+		case "audio":
+			section = mediaInfoAudio(s)
+		case "subtitle":
+			section = mediaInfoText(s)
+		default:
+			continue
+		}
+
+		// like MediaInfo, the sections are only numbered if there is more than one of a kind
+		numbers[s.CodecType]++
+		if counts[s.CodecType] > 1 {
+			section.name = fmt.Sprintf("%s #%d", section.name, numbers[s.CodecType])
+		}
+
+		sections = append(sections, section)
+	}
+
+	var b strings.Builder
+	for i, section := range sections {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+
+		b.WriteString(section.name + "\n")
+		for _, f := range section.fields {
+			b.WriteString(fmt.Sprintf("%-41s: %s\n", f[0], f[1]))
+		}
+	}
+
+	return b.String()
+
+}
+
+func (v *ProbedVideo) mediaInfoGeneral(name string) *mediaInfoSection {
+
+	section := &mediaInfoSection{name: "General"}
+	section.add("Complete name", name)
+
+	f := v.ProbeData.Format
+	if f == nil {
+		return section
+	}
+
+	format := mediaInfoContainers[f.FormatName]
+	if format == "" {
+		format = f.FormatLongName
+	}
+	section.add("Format", format)
+
+	size, _ := strconv.ParseInt(f.Size, 10, 64)
+	if size > 0 {
+		section.add("File size", formatMediaInfoSize(size))
+	}
+
+	if duration, err := v.Duration(); err == nil {
+		section.add("Duration", formatMediaInfoDuration(duration))
+	}
+
+	bitRate, _ := strconv.ParseInt(f.BitRate, 10, 64)
+	section.add("Overall bit rate", formatMediaInfoBitRate(bitRate))
+
+	if f.Tag != nil {
+		section.add("Movie name", f.Tag.Title)
+	}
+
+	return section
+
+}
+
+func mediaInfoVideo(s *Stream) *mediaInfoSection {
+
+	section := &mediaInfoSection{name: "Video"}
+	section.add("ID", strconv.Itoa(s.Index+1))
+	section.add("Format", mediaInfoFormat(s))
+	section.add("Format profile", mediaInfoProfile(s))
+	section.add("Codec ID", s.CodecName)
+	section.add("Bit rate", formatMediaInfoBitRate(s.bitRate()))
+
+	if s.Width > 0 && s.Height > 0 {
+		section.add("Width", formatMediaInfoNumber(int64(s.Width))+" pixels")
+		section.add("Height", formatMediaInfoNumber(int64(s.Height))+" pixels")
+	}
+
+	section.add("Display aspect ratio", s.DisplayAspectRatio)
+
+	frameRate := parseFrameRate(s.AvgFrameRate)
+	if frameRate == 0 {
+		frameRate = parseFrameRate(s.RFrameRate)
+	}
+	if frameRate > 0 {
+		section.add("Frame rate", fmt.Sprintf("%.3f FPS", frameRate))
+	}
+
+	if strings.HasPrefix(s.PixFmt, "yuv") {
+		section.add("Color space", "YUV")
+	}
+	section.add("Chroma subsampling", chromaSubsampling(s.PixFmt))
+
+	if depth := bitDepth(s); depth > 0 {
+		section.add("Bit depth", fmt.Sprintf("%d bits", depth))
+	}
+
+	if s.FieldOrder != "" && s.FieldOrder != "unknown" {
+		if s.FieldOrder == "progressive" {
+			section.add("Scan type", "Progressive")
+		} else {
+			section.add("Scan type", "Interlaced")
+		}
+	}
+
+	section.add("HDR format", hdrFormat(s))
+	section.add("Color range", map[string]string{"tv": "Limited", "pc": "Full"}[s.ColorRange])
+	section.add("Color primaries", mediaInfoColor(s.ColorPrimaries))
+	section.add("Transfer characteristics", mediaInfoColor(s.ColorTransfer))
+	section.add("Matrix coefficients", mediaInfoColor(s.ColorSpace))
+	addMediaInfoTags(section, s)
+
+	return section
+
+}
+
+func mediaInfoAudio(s *Stream) *mediaInfoSection {
+
+	section := &mediaInfoSection{name: "Audio"}
+	section.add("ID", strconv.Itoa(s.Index+1))
+	section.add("Format", mediaInfoFormat(s))
+	section.add("Format profile", mediaInfoProfile(s))
+	section.add("Codec ID", s.CodecName)
+	section.add("Bit rate", formatMediaInfoBitRate(s.bitRate()))
+
+	if s.Channels == 1 {
+		section.add("Channel(s)", "1 channel")
+	} else if s.Channels > 1 {
+		section.add("Channel(s)", fmt.Sprintf("%d channels", s.Channels))
+	}
+
+	section.add("Channel layout", s.ChannelLayout)
+
+	if sampleRate, _ := strconv.ParseFloat(s.SampleRate, 64); sampleRate > 0 {
+		section.add("Sampling rate", fmt.Sprintf("%.1f kHz", sampleRate/1000))
+	}
+
+	if depth := bitDepth(s); depth > 0 {
+		section.add("Bit depth", fmt.Sprintf("%d bits", depth))
+	}
+
+	addMediaInfoTags(section, s)
+
+	return section
+
+}
+
+func mediaInfoText(s *Stream) *mediaInfoSection {
+
+	section := &mediaInfoSection{name: "Text"}
+	section.add("ID", strconv.Itoa(s.Index+1))
+	section.add("Format", mediaInfoFormat(s))
+	section.add("Codec ID", s.CodecName)
+	addMediaInfoTags(section, s)
+
+	return section
+
+}
+
+// addMediaInfoTags adds the title, language and dispositions of a stream
+func addMediaInfoTags(section *mediaInfoSection, s *Stream) {
+
+	if s.Tag != nil {
+		section.add("Title", s.Tag.Title)
+		section.add("Language", s.Tag.Language)
+	}
+
+	if s.Disposition != nil {
+		section.add("Default", yesNo(s.Disposition.Default == 1))
+		section.add("Forced", yesNo(s.Disposition.Forced == 1))
+	}
+
+}
+
+func mediaInfoFormat(s *Stream) string {
+	if f, ok := mediaInfoFormats[s.CodecName]; ok {
+		return f
+	}
+	return strings.ToUpper(s.CodecName)
+}
+
+// mediaInfoProfile returns the profile with the level of video streams (e.g. High@L4.1)
+func mediaInfoProfile(s *Stream) string {
+
+	if s.Profile == "" || s.Level <= 0 || s.CodecType != "video" {
+		return s.Profile
+	}
+
+	switch s.CodecName {
+	case "h264":
+		return fmt.Sprintf("%s@L%g", s.Profile, float64(s.Level)/10)
+	case "hevc":
+		return fmt.Sprintf("%s@L%g", s.Profile, float64(s.Level)/30)
+	}
+
+	return s.Profile
+
+}
+
+// hdrFormat returns the HDR formats found in the side data and the transfer characteristics of a stream
+func hdrFormat(s *Stream) string {
+
+	var formats []string
+	for _, sd := range s.SideDataList {
+		switch sd.SideDataType {
+		case "DOVI configuration record":
+			formats = append(formats, "Dolby Vision")
+		case "Mastering display metadata":
+			formats = append(formats, "SMPTE ST 2086")
+		case "HDR Dynamic Metadata SMPTE2094-40 (HDR10+)":
+			formats = append(formats, "SMPTE ST 2094 App 4")
+		}
+	}
+
+	switch s.ColorTransfer {
+	case "smpte2084":
+		formats = append(formats, "HDR10 compatible")
+	case "arib-std-b67":
+		formats = append(formats, "HLG")
+	}
+
+	return strings.Join(formats, ", ")
+
+}
+
+// mediaInfoColor converts ffmpeg color names (e.g. bt2020nc, smpte2084) to the names used by MediaInfo
+func mediaInfoColor(c string) string {
+	switch c {
+	case "", "unknown", "reserved":
+		return ""
+	case "bt709":
+		return "BT.709"
+	case "bt2020":
+		return "BT.2020"
+	case "bt2020nc":
+		return "BT.2020 non-constant"
+	case "bt2020c":
+		return "BT.2020 constant"
+	case "smpte2084":
+		return "PQ"
+	case "arib-std-b67":
+		return "HLG"
+	case "bt470bg":
+		return "BT.601 PAL"
+	case "smpte170m":
+		return "BT.601 NTSC"
+	}
+	return c
+}
+
+func chromaSubsampling(pixFmt string) string {
+	switch {
+	case strings.HasPrefix(pixFmt, "yuv420"), strings.HasPrefix(pixFmt, "yuvj420"):
+		return "4:2:0"
+	case strings.HasPrefix(pixFmt, "yuv422"), strings.HasPrefix(pixFmt, "yuvj422"):
+		return "4:2:2"
+	case strings.HasPrefix(pixFmt, "yuv444"), strings.HasPrefix(pixFmt, "yuvj444"):
+		return "4:4:4"
+	}
+	return ""
+}
+
+// bitDepth returns the bit depth of a stream, 0 if unknown
+func bitDepth(s *Stream) int {
+
+	if depth, err := strconv.Atoi(s.BitsPerRawSample); err == nil && depth > 0 {
+		return depth
+	}
+
+	switch {
+	case s.CodecType != "video" || s.PixFmt == "":
+		return 0
+	case strings.Contains(s.PixFmt, "12le"), strings.Contains(s.PixFmt, "12be"):
+		return 12
+	case strings.Contains(s.PixFmt, "10le"), strings.Contains(s.PixFmt, "10be"):
+		return 10
+	}
+
+	return 8
+
+}
+
+// parseFrameRate parses a rational frame rate (e.g. 24000/1001)
+func parseFrameRate(s string) float64 {
+
+	num, den, ok := strings.Cut(s, "/")
+	if !ok {
+		f, _ := strconv.ParseFloat(s, 64)
+		return f
+	}
+
+	n, _ := strconv.ParseFloat(num, 64)
+	d, _ := strconv.ParseFloat(den, 64)
+	if d == 0 {
+		return 0
+	}
+
+	return n / d
+
+}
+
+// formatMediaInfoNumber formats n with spaces as thousands separator (e.g. 1 920)
+func formatMediaInfoNumber(n int64) string {
+
+	s := strconv.FormatInt(n, 10)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + " " + s[i:]
+	}
+
+	return s
+
+}
+
+func formatMediaInfoSize(size int64) string {
+	switch {
+	case size >= helpers.GiB:
+		return fmt.Sprintf("%.2f GiB", float64(size)/float64(helpers.GiB))
+	case size >= helpers.MiB:
+		return fmt.Sprintf("%.1f MiB", float64(size)/float64(helpers.MiB))
+	case size >= helpers.KiB:
+		return fmt.Sprintf("%.1f KiB", float64(size)/float64(helpers.KiB))
+	}
+	return fmt.Sprintf("%d Bytes", size)
+}
+
+func formatMediaInfoBitRate(bitRate int64) string {
+	switch {
+	case bitRate <= 0:
+		return ""
+	case bitRate >= 10_000_000:
+		return fmt.Sprintf("%.0f Mb/s", float64(bitRate)/1_000_000)
+	case bitRate >= 1_000_000:
+		return fmt.Sprintf("%.1f Mb/s", float64(bitRate)/1_000_000)
+	}
+	return formatMediaInfoNumber(bitRate/1000) + " kb/s"
+}
+
+// formatMediaInfoDuration formats d like MediaInfo (e.g. 1 h 32 min, 1 min 30 s)
+func formatMediaInfoDuration(d time.Duration) string {
+
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	s := int(d.Seconds()) % 60
+	ms := int(d.Milliseconds()) % 1000
+
+	switch {
+	case h > 0:
+		return fmt.Sprintf("%d h %d min", h, m)
+	case m > 0:
+		return fmt.Sprintf("%d min %d s", m, s)
+	}
+	return fmt.Sprintf("%d s %d ms", s, ms)
+
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "Yes"
+	}
+	return "No"
+}
@@ -1,11 +1,15 @@
 package video
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
 )
 
 type ProbedVideo struct {
-	File      string
+	File      string // path or url of the video, ffmpeg reads urls with range requests
 	ProbeData *ProbeData
 }
 
@@ -15,12 +19,28 @@ type ProbeData struct {
 }
 
 type Format struct {
-	Duration string `json:"duration"`
+	FormatName     string `json:"format_name"`
+	FormatLongName string `json:"format_long_name"`
+	Duration       string `json:"duration"`
+	Size           string `json:"size"`
+	BitRate        string `json:"bit_rate"`
+	Tag            *Tag   `json:"tags"`
 }
 
 type Tag struct {
 	Language string `json:"language"`
 	Title    string `json:"title"`
+	BPS      string `json:"BPS"` // bitrate written by mkvmerge, matroska streams have no bit_rate
+}
+
+type Disposition struct {
+	Default int `json:"default"`
+	Forced  int `json:"forced"`
+}
+
+// SideData contains HDR metadata, e.g. "Mastering display metadata" or "DOVI configuration record"
+type SideData struct {
+	SideDataType string `json:"side_data_type"`
 }
 
 type Stream struct {
@@ -31,12 +51,40 @@ type Stream struct {
 	Width              int    `json:"width"`
 	Height             int    `json:"height"`
 	DisplayAspectRatio string `json:"display_aspect_ratio"`
+	Profile            string `json:"profile"` // e.g. High for H.264, LC for AAC
+	PixFmt             string `json:"pix_fmt"`
+	Channels           int    `json:"channels"`
+	BitRate            string `json:"bit_rate"`
 	Tag                *Tag   `json:"tags"`
+
+	Level            int    `json:"level"`
+	RFrameRate       string `json:"r_frame_rate"`
+	AvgFrameRate     string `json:"avg_frame_rate"`
+	BitsPerRawSample string `json:"bits_per_raw_sample"`
+	ColorRange       string `json:"color_range"`
+	ColorSpace       string `json:"color_space"`
+	ColorTransfer    string `json:"color_transfer"`
+	ColorPrimaries   string `json:"color_primaries"`
+	FieldOrder       string `json:"field_order"`
+	SampleRate       string `json:"sample_rate"`
+	ChannelLayout    string `json:"channel_layout"`
+
+	Disposition  *Disposition `json:"disposition"`
+	SideDataList []*SideData  `json:"side_data_list"`
 }
 
-func Probe(file string) (*ProbedVideo, error) {
+// bitRate returns the bitrate in bit/s, 0 if unknown
+func (s *Stream) bitRate() int64 {
+	b, _ := strconv.ParseInt(s.BitRate, 10, 64)
+	if b == 0 && s.Tag != nil {
+		b, _ = strconv.ParseInt(s.Tag.BPS, 10, 64)
+	}
+	return b
+}
+
+func Probe(ctx context.Context, file string) (*ProbedVideo, error) {
 
-	out, err := _exec("ffprobe", "-hide_banner", "-print_format", "json", "-show_format", "-show_streams", file)
+	out, err := _exec(ctx, getFFProbe(), "-hide_banner", "-print_format", "json", "-show_format", "-show_streams", file)
 	if err != nil {
 		return nil, err
 	}
@@ -51,3 +99,11 @@ func Probe(file string) (*ProbedVideo, error) {
 		ProbeData: p,
 	}, nil
 }
+
+// Duration returns the duration of the container
+func (v *ProbedVideo) Duration() (time.Duration, error) {
+	if v.ProbeData.Format == nil {
+		return 0, fmt.Errorf("no format information")
+	}
+	return time.ParseDuration(fmt.Sprintf("%ss", v.ProbeData.Format.Duration))
+}
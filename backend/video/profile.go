@@ -0,0 +1,161 @@
+package video
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Profile controls how samples are transcoded
+type Profile struct {
+	CRF          int64  `json:"crf"`          // libx264 constant rate factor, lower is better
+	Preset       string `json:"preset"`       // libx264 preset
+	MaxHeight    int64  `json:"maxHeight"`    // larger videos are downscaled, 0 keeps the resolution
+	MaxBitrate   int64  `json:"maxBitrate"`   // video bitrate cap in kbit/s, 0 disables the cap
+	AudioBitrate int64  `json:"audioBitrate"` // AAC bitrate in kbit/s
+	MaxDuration  int64  `json:"maxDuration"`  // clip length in seconds, 0 keeps the full length
+	StreamCopy   bool   `json:"streamCopy"`   // copy streams that browsers can play without transcoding
+}
+
+// Profiles are the predefined profiles offered in the web interface
+var Profiles = map[string]*Profile{
+	"FAST":     {CRF: 28, Preset: "veryfast", MaxHeight: 720, MaxBitrate: 2500, AudioBitrate: 96, MaxDuration: 60, StreamCopy: true},
+	"BALANCED": {CRF: 23, Preset: "medium", MaxHeight: 1080, MaxBitrate: 6000, AudioBitrate: 128, StreamCopy: true},
+	"QUALITY":  {CRF: 20, Preset: "slow", AudioBitrate: 192, StreamCopy: true},
+}
+
+// DefaultProfile is used if no profile is configured. Transcoded streams use the ffmpeg defaults for libx264 and AAC
+var DefaultProfile = &Profile{CRF: 23, Preset: "medium", AudioBitrate: 128, StreamCopy: true}
+
+var x264Presets = []string{"ultrafast", "superfast", "veryfast", "faster", "fast", "medium", "slow", "slower", "veryslow"}
+
+// ParseProfile decodes a json encoded profile. An empty string returns DefaultProfile
+func ParseProfile(s string) (*Profile, error) {
+
+	if strings.TrimSpace(s) == "" {
+		return DefaultProfile, nil
+	}
+
+	var p *Profile
+	if err := json.Unmarshal([]byte(s), &p); err != nil || p == nil {
+		return nil, fmt.Errorf("invalid transcoding profile: %v", err)
+	}
+
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+
+}
+
+// Validate checks that ffmpeg accepts the values of the profile
+func (p *Profile) Validate() error {
+
+	if p.CRF < 0 || p.CRF > 51 {
+		return fmt.Errorf("crf must be between 0 and 51")
+	}
+
+	validPreset := false
+	for _, preset := range x264Presets {
+		validPreset = validPreset || preset == p.Preset
+	}
+
+	if !validPreset {
+		return fmt.Errorf("unknown preset: %s", p.Preset)
+	}
+
+	if p.MaxHeight < 0 || p.MaxBitrate < 0 || p.MaxDuration < 0 {
+		return fmt.Errorf("max height, max bitrate and max duration must not be negative")
+	}
+
+	if p.AudioBitrate < 32 || p.AudioBitrate > 512 {
+		return fmt.Errorf("audio bitrate must be between 32 and 512 kbit/s")
+	}
+
+	return nil
+
+}
+
+// Duration returns the length of the clip for a video of the given length
+func (p *Profile) Duration(d time.Duration) time.Duration {
+	if max := time.Duration(p.MaxDuration) * time.Second; max > 0 && d > max {
+		return max
+	}
+	return d
+}
+
+// browser compatible H.264 profiles, High 10 and 4:2:2 profiles can't be decoded by most browsers
+var copyableH264Profiles = map[string]bool{
+	"Baseline":             true,
+	"Constrained Baseline": true,
+	"Main":                 true,
+	"High":                 true,
+}
+
+// canCopy returns true if the stream can be played by browsers without transcoding and is within the limits of the profile
+func (p *Profile) canCopy(s *Stream) bool {
+
+	if !p.StreamCopy {
+		return false
+	}
+
+	switch s.CodecType {
+	case "video":
+		if s.CodecName != "h264" || !copyableH264Profiles[s.Profile] || s.PixFmt != "yuv420p" {
+			return false
+		}
+
+		if p.MaxHeight > 0 && int64(s.Height) > p.MaxHeight {
+			return false
+		}
+
+		// streams without a bitrate (e.g. in mkv) are copied, the cap is a limit for transcoding
+		if p.MaxBitrate > 0 && s.bitRate() > p.MaxBitrate*1000 {
+			return false
+		}
+
+		return true
+
+	case "audio":
+		return s.CodecName == "aac" && s.Profile == "LC" && s.Channels > 0 && s.Channels <= 2
+
+	default:
+		return false
+	}
+
+}
+
+// videoArgs returns the ffmpeg encoding arguments for a video stream
+func (p *Profile) videoArgs(s *Stream) []string {
+
+	if p.canCopy(s) {
+		return []string{"-c:v", "copy"}
+	}
+
+	args := []string{"-c:v", "libx264", "-preset", p.Preset, "-crf", fmt.Sprintf("%d", p.CRF), "-pix_fmt", "yuv420p", "-vsync", "2"}
+
+	if p.MaxBitrate > 0 {
+		args = append(args, "-maxrate", fmt.Sprintf("%dk", p.MaxBitrate), "-bufsize", fmt.Sprintf("%dk", p.MaxBitrate*2))
+	}
+
+	// keep the aspect ratio, the width has to be divisible by 2
+	if p.MaxHeight > 0 && int64(s.Height) > p.MaxHeight {
+		args = append(args, "-vf", fmt.Sprintf("scale=-2:%d", p.MaxHeight))
+	}
+
+	return args
+
+}
+
+// audioArgs returns the ffmpeg encoding arguments for an audio stream
+func (p *Profile) audioArgs(s *Stream) []string {
+
+	if p.canCopy(s) {
+		return []string{"-c:a", "copy"}
+	}
+
+	return []string{"-c:a", "aac", "-b:a", fmt.Sprintf("%dk", p.AudioBitrate), "-ac", "2"}
+
+}
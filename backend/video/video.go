@@ -1,16 +1,17 @@
 package video
 
 import (
-	"atus/backend/helpers"
 	"atus/backend/logger"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
+	"time"
 )
 
-func New(file string) (*ProbedVideo, error) {
-	v, err := Probe(file)
+func New(ctx context.Context, file string) (*ProbedVideo, error) {
+	v, err := Probe(ctx, file)
 	if err != nil {
 		return nil, err
 	}
@@ -28,7 +29,9 @@ type PreparedVideo struct {
 	PreparedStreams []*PreparedStream
 }
 
-func (v *ProbedVideo) Prepare() (*PreparedVideo, error) {
+// Prepare extracts all streams that can be converted, transcoded as set by the profile.
+// onProgress receives the overall progress in percent and may be nil
+func (v *ProbedVideo) Prepare(ctx context.Context, profile *Profile, onProgress func(percent float64)) (*PreparedVideo, error) {
 
 	// make temp folder
 	tempDir, err := ioutil.TempDir("", "atus_video_conv")
@@ -40,21 +43,49 @@ func (v *ProbedVideo) Prepare() (*PreparedVideo, error) {
 		tempDir: tempDir,
 	}
 
+	var streams []*Stream
+	for _, stream := range v.ProbeData.Streams {
+		if stream.CodecType == "video" || stream.CodecType == "audio" || stream.CodecType == "subtitle" {
+			streams = append(streams, stream)
+		}
+	}
+
+	// every stream is one step of the overall progress
+	duration, _ := v.Duration()
+	duration = profile.Duration(duration)
+	progress := func(step int) func(time.Duration) {
+		if onProgress == nil || duration <= 0 {
+			return nil
+		}
+
+		return func(d time.Duration) {
+			done := float64(d) / float64(duration)
+			if done > 1 {
+				done = 1
+			}
+			onProgress((float64(step) + done) / float64(len(streams)) * 100)
+		}
+	}
+
 	videoStreamExtracted := false
 	audioStreamExtracted := false
-	for _, stream := range v.ProbeData.Streams {
+	for i, stream := range streams {
 
 		var newFileName string
 		if stream.CodecType == "video" || stream.CodecType == "audio" {
 			newFileName = fmt.Sprintf("idx_%d.mp4", stream.Index)
-		} else if stream.CodecType == "subtitle" {
-			newFileName = fmt.Sprintf("idx_%d.vtt", stream.Index)
 		} else {
-			continue
+			newFileName = fmt.Sprintf("idx_%d.vtt", stream.Index)
 		}
 
-		res, err := v.Extract(stream, path.Join(tempDir, newFileName))
-		logger.Debugf("Extracting stream %d(%s), file: %s; %s", stream.Index, stream.CodecType, newFileName, res)
+		res, err := v.Extract(ctx, stream, path.Join(tempDir, newFileName), profile, progress(i))
+		logger.Debugf("Extracting stream %d(%s, copy: %v), file: %s; %s", stream.Index, stream.CodecType, profile.canCopy(stream), newFileName, res)
+
+		// a timeout or cancellation aborts all remaining streams
+		if ctx.Err() != nil {
+			os.RemoveAll(tempDir)
+			return nil, ctx.Err()
+		}
 
 		// we ignore errors here and check later if we have at least one video and one audio stream
 		// !! this means it is possible that we have missing streams in the output !!
@@ -80,62 +111,37 @@ func (v *ProbedVideo) Prepare() (*PreparedVideo, error) {
 
 	// make sure we have at least one video and one audio stream
 	if !videoStreamExtracted {
+		os.RemoveAll(tempDir)
 		return nil, fmt.Errorf("no valid video stream found")
 	}
 
 	if !audioStreamExtracted {
+		os.RemoveAll(tempDir)
 		return nil, fmt.Errorf("no valid audio stream found")
 	}
 
 	return p, nil
 }
 
-func (pv *PreparedVideo) Save(savePath, mpdName string) error {
-
-	mp4DashArgs := []string{
-		fmt.Sprintf("--mpd-name=%s", mpdName),
-		"-f",
-		"-o", savePath,
+// Save packages the prepared streams into savePath. name is the file name of the manifests without extension
+func (pv *PreparedVideo) Save(ctx context.Context, savePath, name string, format OutputFormat, packager Packager) (*Manifests, error) {
+	if packager == PackagerBento4 {
+		return pv.saveBento4(ctx, savePath, name, format)
 	}
+	return pv.saveFFMPEG(ctx, savePath, name, format)
+}
 
-	for _, s := range pv.PreparedStreams {
-
-		// video and audio streams need to be fragmented
-		if s.Stream.CodecType == "video" || s.Stream.CodecType == "audio" {
-
-			outputFile := path.Join(pv.tempDir, fmt.Sprintf("f-%s", s.FileName))
-			err := Fragment(path.Join(pv.tempDir, s.FileName), outputFile)
-			if err != nil {
-				return err
-			}
-
-			mp4DashArgs = append(mp4DashArgs, outputFile)
-
-			continue
-		}
-
-		if s.Stream.CodecType == "subtitle" {
-
-			// it is possible that a video file has multiple subtitle streams with the same language
-			// thats why we try to use the streams title as the name
-			lang := fmt.Sprintf("lang-%d", s.Stream.Index)
-			if s.Stream.Tag != nil {
-				if s.Stream.Tag.Title != "" {
-					lang = s.Stream.Tag.Title
-				} else if s.Stream.Tag.Language != "" {
-					lang = s.Stream.Tag.Language
-				}
-			}
-
-			mp4DashArgs = append(mp4DashArgs, fmt.Sprintf("[+format=webvtt,+language=%s]%s", helpers.ReplaceNonAlphanumeric(lang, " "), path.Join(pv.tempDir, s.FileName)))
-			continue
+// subtitleName returns the title of a subtitle stream, the language if there is no title.
+// It is possible that a video file has multiple subtitle streams with the same language
+func subtitleName(s *Stream) string {
+	if s.Tag != nil {
+		if s.Tag.Title != "" {
+			return s.Tag.Title
+		} else if s.Tag.Language != "" {
+			return s.Tag.Language
 		}
 	}
-
-	ret, err := _exec("mp4dash", mp4DashArgs...)
-	logger.Debugf("mp4dash %s", ret)
-
-	return err
+	return fmt.Sprintf("lang-%d", s.Index)
 }
 
 func (pv *PreparedVideo) RemoveTempDir() error {
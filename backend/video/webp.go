@@ -0,0 +1,94 @@
+package video
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// set to 1 by CheckWebP if ffmpeg has the libwebp encoder
+var webpSupported int32
+
+// CheckWebP checks once at startup that ffmpeg can encode webp, the result is returned by WebPSupported.
+// ffmpeg builds without libwebp are common, the encoders are listed by ffmpeg -encoders
+func CheckWebP() error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	out, err := _exec(ctx, getFFMPEG(), "-hide_banner", "-encoders")
+	if err != nil {
+		atomic.StoreInt32(&webpSupported, 0)
+		return err
+	}
+
+	if !hasEncoder(out, "libwebp") {
+		atomic.StoreInt32(&webpSupported, 0)
+		return errors.New("ffmpeg was built without the libwebp encoder")
+	}
+
+	atomic.StoreInt32(&webpSupported, 1)
+	return nil
+
+}
+
+// WebPSupported returns true if the last CheckWebP found the libwebp encoder
+func WebPSupported() bool {
+	return atomic.LoadInt32(&webpSupported) == 1
+}
+
+// hasEncoder returns true if the output of ffmpeg -encoders lists the encoder.
+// Encoders are listed as " V....D libwebp              libwebp WebP image (codec webp)"
+func hasEncoder(out []byte, name string) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) >= 2 && fields[1] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// EncodeWebP encodes img as lossy webp with the given quality (0-100).
+// The standard library can't encode webp, so the image is piped through ffmpeg as png
+func EncodeWebP(ctx context.Context, img image.Image, quality int) ([]byte, error) {
+
+	var in bytes.Buffer
+	if err := (&png.Encoder{CompressionLevel: png.NoCompression}).Encode(&in, img); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, getFFMPEG(),
+		"-loglevel", "error",
+		"-f", "png_pipe",
+		"-i", "pipe:0",
+		"-frames:v", "1",
+		"-c:v", "libwebp",
+		"-quality", fmt.Sprintf("%d", quality),
+		"-f", "webp",
+		"pipe:1",
+	)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = &in
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, fmt.Errorf("error: %s; stderr: %s", err, stderr.String())
+	}
+
+	return stdout.Bytes(), nil
+
+}
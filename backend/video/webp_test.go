@@ -0,0 +1,25 @@
+package video
+
+import (
+	"testing"
+)
+
+func TestHasEncoder(t *testing.T) {
+
+	encoders := []byte(`Encoders:
+ V..... = Video
+ A..... = Audio
+ ------
+ V....D libwebp_anim         libwebp WebP image (codec webp)
+ V....D mjpeg                MJPEG (Motion JPEG)
+`)
+
+	if hasEncoder(encoders, "libwebp") {
+		t.Error("expected libwebp_anim not to match libwebp")
+	}
+
+	if !hasEncoder(append(encoders, " V....D libwebp              libwebp WebP image (codec webp)\n"...), "libwebp") {
+		t.Error("expected the libwebp encoder")
+	}
+
+}
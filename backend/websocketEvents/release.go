@@ -3,6 +3,8 @@ package websocketEvents
 import (
 	"atus/backend/atus"
 	"atus/backend/bencode"
+	"atus/backend/category"
+	"atus/backend/description"
 	"atus/backend/release"
 	"atus/backend/sqlite"
 	"atus/backend/websocket"
@@ -32,6 +34,7 @@ func Release__GetDetails(r *websocket.Request) {
 		`SELECT 	
 			uid, 
 			hash,
+			hash_v2,
 			name, 
 			name_raw,
 			pre, 
@@ -42,18 +45,20 @@ func Release__GetDetails(r *websocket.Request) {
 			source_uid,
 			fileserver_uid,
 			state,
-			uploaded
+			uploaded,
+			external_ids,
+			metadata
 		FROM releases 
 		WHERE uid = ?
 		LIMIT 1`,
 		req.UID,
 	)
 
-	var uid, hash, name, nameRaw, pre, category, categoryRaw, addedRaw, sourceUID, fileserverUID, state string
+	var uid, hash, hashV2, name, nameRaw, pre, category, categoryRaw, addedRaw, sourceUID, fileserverUID, state, externalIDs, metadata string
 	var uploaded sql.NullString
 	var size int64
 
-	err := releaseRow.Scan(&uid, &hash, &name, &nameRaw, &pre, &category, &categoryRaw, &size, &addedRaw, &sourceUID, &fileserverUID, &state, &uploaded)
+	err := releaseRow.Scan(&uid, &hash, &hashV2, &name, &nameRaw, &pre, &category, &categoryRaw, &size, &addedRaw, &sourceUID, &fileserverUID, &state, &uploaded, &externalIDs, &metadata)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			r.SetResponseCode(http.StatusNotFound)
@@ -85,8 +90,43 @@ func Release__GetDetails(r *websocket.Request) {
 	downloadState, _ := a.GetDownloadState(fileserverUID, hash)
 	metaFiles, _ := release.GetMetaFiles(uid, "")
 
+	// quarantined releases have the validation report of their torrent attached
+	var validationReport *bencode.Report
+	for _, mf := range metaFiles {
+		if mf.Type != release.MetafileTypeValidationReport {
+			continue
+		}
+		if buf, err := mf.GetFile(); err == nil {
+			json.Unmarshal(buf, &validationReport)
+		}
+	}
+
+	uploads := []map[string]interface{}{}
+	if rlsUploads, err := release.GetUploads(uid); err == nil {
+		for _, u := range rlsUploads {
+			destinationName := ""
+			if d := a.GetDestinationByUID(u.DestinationUID); d != nil {
+				destinationName = d.Name
+			}
+
+			uploads = append(uploads, map[string]interface{}{
+				"destinationUID":  u.DestinationUID,
+				"destinationName": destinationName,
+				"state":           u.State,
+				"message":         u.Message,
+				"hash":            u.Hash,
+				"originalHash":    u.OriginalHash,
+				"torrentID":       u.TorrentID,
+				"torrentURL":      u.TorrentURL,
+				"updated":         u.Updated,
+			})
+		}
+	}
+
 	r.MarshalAndSendResponse(map[string]interface{}{
 		"uid":            uid,
+		"hash":           hash,
+		"hashV2":         hashV2,
 		"name":           name,
 		"nameRaw":        nameRaw,
 		"pre":            pre,
@@ -98,6 +138,12 @@ func Release__GetDetails(r *websocket.Request) {
 		"sourceName":     sourceName,
 		"downloadState":  downloadState,
 		"metaFiles":      metaFiles,
+		"uploads":        uploads,
+
+		"validationReport": validationReport,
+		"externalIDs":      release.ParseExternalIDs(externalIDs),
+		"metadata":         release.ParseMetadata(metadata),
+
 		"state": map[string]interface{}{
 			"state":      state,
 			"uploadDate": uploaded.String,
@@ -220,6 +266,12 @@ func Release__Upload(r *websocket.Request) {
 
 	var req struct {
 		UID string
+
+		// optional, uploads to all enabled destinations if empty
+		DestinationUIDs []string
+
+		// uploads even if a tracker couldn't be searched for existing torrents
+		IgnoreDupeCheckErrors bool
 	}
 
 	if err := json.Unmarshal(r.Payload, &req); err != nil {
@@ -241,9 +293,9 @@ func Release__Upload(r *websocket.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
-	if err := a.UploadRelease(ctx, rls); err != nil {
+	if err := a.UploadRelease(ctx, rls, req.IgnoreDupeCheckErrors, req.DestinationUIDs...); err != nil {
 		r.SetResponseCode(http.StatusInternalServerError)
 		r.MarshalAndSendResponse(err.Error())
 		return
@@ -252,3 +304,154 @@ func Release__Upload(r *websocket.Request) {
 	r.MarshalAndSendResponse(true)
 
 }
+
+// getApprovalRelease returns the pending release, so that state changes are seen by the pending releases task
+func getApprovalRelease(a *atus.ATUS, uid string) *atus.Release {
+	if rls := a.GetPendingReleaseByUID(uid); rls != nil {
+		return rls
+	}
+	return a.GetReleaseByUID(uid)
+}
+
+// Release__GetUploadPreview returns the payloads that would be sent to the destinations
+func Release__GetUploadPreview(r *websocket.Request) {
+
+	a := r.Hub.Ctx.Value(atus.ContextKey).(*atus.ATUS)
+
+	var req struct {
+		UID string
+	}
+
+	if err := json.Unmarshal(r.Payload, &req); err != nil {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	rls := getApprovalRelease(a, req.UID)
+	if rls == nil {
+		r.SetResponseCode(http.StatusNotFound)
+		r.MarshalAndSendResponse("release not found")
+		return
+	}
+
+	// re-created torrents are prepared before approval, the timeout only applies if one is missing
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	previews, err := a.GetUploadPreviews(ctx, rls)
+	if err != nil {
+		r.SetResponseCode(http.StatusInternalServerError)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	r.MarshalAndSendResponse(previews)
+
+}
+
+func Release__Approve(r *websocket.Request) {
+
+	a := r.Hub.Ctx.Value(atus.ContextKey).(*atus.ATUS)
+
+	var req struct {
+		UID      string
+		Name     string
+		Category category.Name
+	}
+
+	if err := json.Unmarshal(r.Payload, &req); err != nil {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	rls := getApprovalRelease(a, req.UID)
+	if rls == nil {
+		r.SetResponseCode(http.StatusNotFound)
+		r.MarshalAndSendResponse("release not found")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := a.ApproveRelease(ctx, rls, req.Name, req.Category); err != nil {
+		r.SetResponseCode(http.StatusInternalServerError)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	r.MarshalAndSendResponse(true)
+
+}
+
+func Release__Reject(r *websocket.Request) {
+
+	a := r.Hub.Ctx.Value(atus.ContextKey).(*atus.ATUS)
+
+	var req struct {
+		UID string
+	}
+
+	if err := json.Unmarshal(r.Payload, &req); err != nil {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	rls := getApprovalRelease(a, req.UID)
+	if rls == nil {
+		r.SetResponseCode(http.StatusNotFound)
+		r.MarshalAndSendResponse("release not found")
+		return
+	}
+
+	if err := a.RejectRelease(rls); err != nil {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	r.MarshalAndSendResponse(true)
+
+}
+
+// Release__SetNFOCharset overrides the detected charset of the nfo, an empty charset enables detection
+func Release__SetNFOCharset(r *websocket.Request) {
+
+	a := r.Hub.Ctx.Value(atus.ContextKey).(*atus.ATUS)
+
+	var req struct {
+		UID     string
+		Charset string
+	}
+
+	if err := json.Unmarshal(r.Payload, &req); err != nil {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	charset, err := description.ParseCharset(req.Charset)
+	if err != nil {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	rls := getApprovalRelease(a, req.UID)
+	if rls == nil {
+		r.SetResponseCode(http.StatusNotFound)
+		r.MarshalAndSendResponse("release not found")
+		return
+	}
+
+	if err := a.SetNFOCharset(rls, charset); err != nil {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	r.MarshalAndSendResponse(true)
+
+}
@@ -0,0 +1,457 @@
+package websocketEvents
+
+import (
+	"atus/backend/atus"
+	"atus/backend/category"
+	"atus/backend/config"
+	"atus/backend/description"
+	"atus/backend/destination"
+	"atus/backend/helpers"
+	"atus/backend/release"
+	"atus/backend/websocket"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/color"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+type settingsDestinationRequest struct {
+	UID                 string
+	Name                string
+	Type                destination.Type
+	APIURL              string
+	TrackerAPIKey       string
+	Anonymous           bool
+	TrackerAnnounceURL  string
+	UserAnnounceURL     string
+	UserID              string
+	Comment             string
+	CreatedBy           string
+	CategoryMapping     map[category.Name]string
+	AttributeMapping    *destination.AttributeMapping
+	DescriptionTemplate string
+	FileBaseURL         string
+	SourceTag           string
+	RecreateTorrent     bool
+	PieceSizePolicy     []*destination.PieceSize // sizes in bytes, a MaxSize of 0 matches all sizes
+	ContactSheetField   string
+	DryRun              bool
+	Filters             struct {
+		Categories []category.Name
+		Includes   []string
+		Excludes   []string
+		MaxSize    int64 // GiB
+
+		ExternalIDs []string
+	}
+}
+
+// apply copies the request to the destination
+func (req *settingsDestinationRequest) apply(d *destination.Destination) error {
+
+	if _, ok := helpers.ValidateURL(req.APIURL); !ok {
+		return fmt.Errorf("invalid upload URL: %s", req.APIURL)
+	}
+
+	switch req.Type {
+	case destination.TypeATUS, destination.TypeUNIT3D, destination.TypeGazelle:
+	default:
+		return fmt.Errorf("unknown destination type: %s", req.Type)
+	}
+
+	if _, err := description.Parse(req.DescriptionTemplate); err != nil {
+		return fmt.Errorf("invalid description template: %s", err)
+	}
+
+	if req.FileBaseURL != "" {
+		if _, ok := helpers.ValidateURL(req.FileBaseURL); !ok {
+			return fmt.Errorf("invalid file base URL: %s", req.FileBaseURL)
+		}
+	}
+
+	for _, rule := range req.PieceSizePolicy {
+		if rule.MaxSize < 0 {
+			return fmt.Errorf("invalid max size in piece size policy: %d", rule.MaxSize)
+		}
+
+		// piece lengths of v2 torrents must be a power of two, clients expect it for v1 too
+		if rule.PieceLength < 16*helpers.KiB || rule.PieceLength&(rule.PieceLength-1) != 0 {
+			return fmt.Errorf("invalid piece length in piece size policy: %d. Must be a power of two and at least 16 KiB", rule.PieceLength)
+		}
+	}
+
+	d.Name = strings.TrimSpace(req.Name)
+	if d.Name == "" {
+		u, _ := url.Parse(req.APIURL)
+		d.Name = u.Hostname()
+	}
+
+	d.Type = req.Type
+	d.APIURL = req.APIURL
+	d.TrackerAPIKey = req.TrackerAPIKey
+	d.Anonymous = req.Anonymous
+	d.TrackerAnnounceURL = req.TrackerAnnounceURL
+	d.UserAnnounceURL = req.UserAnnounceURL
+	d.UserID = req.UserID
+	d.Comment = req.Comment
+	d.CreatedBy = req.CreatedBy
+	d.CategoryMapping = req.CategoryMapping
+	d.AttributeMapping = req.AttributeMapping
+	d.DescriptionTemplate = req.DescriptionTemplate
+	d.FileBaseURL = strings.TrimSpace(req.FileBaseURL)
+	d.SourceTag = strings.TrimSpace(req.SourceTag)
+	d.RecreateTorrent = req.RecreateTorrent
+	d.PieceSizePolicy = req.PieceSizePolicy
+	d.ContactSheetField = strings.TrimSpace(req.ContactSheetField)
+	d.DryRun = req.DryRun
+	d.Filters = &destination.Filters{
+		Categories: req.Filters.Categories,
+		Includes:   req.Filters.Includes,
+		Excludes:   req.Filters.Excludes,
+	}
+
+	if req.Filters.MaxSize > 0 {
+		d.Filters.MaxSize = req.Filters.MaxSize * helpers.GiB
+	}
+
+	for _, s := range req.Filters.ExternalIDs {
+		t, err := release.ParseExternalIDType(s)
+		if err != nil {
+			return err
+		}
+		d.Filters.ExternalIDs = append(d.Filters.ExternalIDs, t)
+	}
+
+	return nil
+
+}
+
+func Settings__DestinationsEdit_Get(r *websocket.Request) {
+
+	a := r.Hub.Ctx.Value(atus.ContextKey).(*atus.ATUS)
+
+	var req struct {
+		UID string
+	}
+
+	if err := json.Unmarshal(r.Payload, &req); err != nil {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	d := a.GetDestinationByUID(req.UID)
+	if d == nil {
+		r.SetResponseCode(http.StatusNotFound)
+		r.MarshalAndSendResponse(fmt.Sprintf("destination with UID %s not found", req.UID))
+		return
+	}
+
+	pieceSizePolicy := make([]map[string]int64, 0, len(d.PieceSizePolicy))
+	for _, rule := range d.PieceSizePolicy {
+		pieceSizePolicy = append(pieceSizePolicy, map[string]int64{
+			"maxSize":     rule.MaxSize,
+			"pieceLength": rule.PieceLength,
+		})
+	}
+
+	r.MarshalAndSendResponse(map[string]interface{}{
+		"uid":                d.UID,
+		"name":               d.Name,
+		"enabled":            d.Enabled,
+		"type":               d.Type,
+		"apiURL":             d.APIURL,
+		"trackerAPIKey":      d.TrackerAPIKey,
+		"anonymous":          d.Anonymous,
+		"apiAuthToken":       d.APIAuthToken,
+		"trackerAnnounceURL": d.TrackerAnnounceURL,
+		"userAnnounceURL":    d.UserAnnounceURL,
+		"userID":             d.UserID,
+		"comment":            d.Comment,
+		"createdBy":          d.CreatedBy,
+		"categoryMapping":    d.CategoryMapping,
+		"attributeMapping": map[string]interface{}{
+			"types":       d.AttributeMapping.Types,
+			"resolutions": d.AttributeMapping.Resolutions,
+		},
+		"descriptionTemplate": d.DescriptionTemplate,
+		"fileBaseURL":         d.FileBaseURL,
+		"sourceTag":           d.SourceTag,
+		"recreateTorrent":     d.RecreateTorrent,
+		"pieceSizePolicy":     pieceSizePolicy,
+		"contactSheetField":   d.ContactSheetField,
+		"dryRun":              d.DryRun,
+		"filters": map[string]interface{}{
+			"categories": d.Filters.Categories,
+			"includes":   d.Filters.Includes,
+			"excludes":   d.Filters.Excludes,
+			"maxSize":    d.Filters.MaxSize / helpers.GiB,
+
+			"externalIDs": d.Filters.ExternalIDs,
+		},
+	})
+
+}
+
+// Settings__DestinationsEdit_Save adds a new destination if no uid is set, otherwise the destination is updated
+func Settings__DestinationsEdit_Save(r *websocket.Request) {
+
+	a := r.Hub.Ctx.Value(atus.ContextKey).(*atus.ATUS)
+
+	var req settingsDestinationRequest
+
+	if err := json.Unmarshal(r.Payload, &req); err != nil {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	// -- add new destination ---------------------
+	if req.UID == "" {
+		d := destination.New()
+		d.Enabled = true
+
+		if err := req.apply(d); err != nil {
+			r.SetResponseCode(http.StatusBadRequest)
+			r.MarshalAndSendResponse(err.Error())
+			return
+		}
+
+		if err := a.AddNewDestination(d); err != nil {
+			r.SetResponseCode(http.StatusInternalServerError)
+			r.MarshalAndSendResponse(err.Error())
+			return
+		}
+
+		atus.SetSetupStepDone(r.Hub, atus.SetupStepUploadConfigured)
+
+		r.MarshalAndSendResponse(d.UID)
+		return
+	}
+
+	// -- update existing destination -------------
+	d := a.GetDestinationByUID(req.UID)
+	if d == nil {
+		r.SetResponseCode(http.StatusNotFound)
+		r.MarshalAndSendResponse(fmt.Sprintf("destination with UID %s not found", req.UID))
+		return
+	}
+
+	if err := req.apply(d.Destination); err != nil {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	if err := d.Save(); err != nil {
+		r.SetResponseCode(http.StatusInternalServerError)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	atus.SetSetupStepDone(r.Hub, atus.SetupStepUploadConfigured)
+
+	r.MarshalAndSendResponse(d.UID)
+
+}
+
+// Settings__DestinationsEdit_UploadTestTorrent uploads a test release using the unsaved settings of the form
+func Settings__DestinationsEdit_UploadTestTorrent(r *websocket.Request) {
+
+	a := r.Hub.Ctx.Value(atus.ContextKey).(*atus.ATUS)
+
+	var req settingsDestinationRequest
+
+	if err := json.Unmarshal(r.Payload, &req); err != nil {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	// the tracker accesses the test files with the token that is sent with the upload.
+	// destinations that are not saved yet don't have a valid token, the global api token is used instead
+	d := destination.New()
+	d.APIAuthToken = config.GetString("API__AUTH_TOKEN")
+	if existing := a.GetDestinationByUID(req.UID); existing != nil {
+		d.UID = existing.UID
+		d.APIAuthToken = existing.APIAuthToken
+	}
+
+	if err := req.apply(d); err != nil {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	uid := "TESTFILE"
+
+	// check if folder exists
+	if _, err := os.Stat(path.Join(config.Base.Folders.Data, uid)); os.IsNotExist(err) {
+		err := os.MkdirAll(path.Join(config.Base.Folders.Data, uid), 0755)
+		if err != nil {
+			r.SetResponseCode(http.StatusInternalServerError)
+			r.MarshalAndSendResponse(err.Error())
+			return
+		}
+	}
+
+	// create test images
+	images := []release.MetaFileType{
+		release.MetafileTypeImage,
+		release.MetafileTypeProofImage,
+		release.MetafileTypeScreenImage,
+		release.MetafileTypeSourceImage,
+		release.MetafileTypeContactSheet,
+	}
+
+	sumSampleImages := config.GetInt64("SAMPLES__SUM_SCREENSHOTS")
+	if sumSampleImages == 0 {
+		sumSampleImages = 3
+	}
+	for i := int64(1); i <= sumSampleImages; i++ {
+		images = append(images, release.MetafileTypeScreenImageFromSample)
+	}
+
+	var metaFiles []*release.MetaFile
+
+	for i, imageType := range images {
+		filename := fmt.Sprintf("%s_%d.jpg", imageType, i)
+		savePath := path.Join(config.Base.Folders.Data, uid, filename)
+		if _, err := os.Stat(savePath); errors.Is(err, os.ErrNotExist) {
+
+			img := helpers.CreateSquareImage(400)
+			img.AddLabel("ATUS", color.RGBA{25, 25, 25, 255}, 0)
+			img.AddLabel("TEST "+string(imageType), color.RGBA{100, 25, 25, 180}, 50)
+
+			if err := img.Save(savePath); err != nil {
+				r.SetResponseCode(http.StatusInternalServerError)
+				r.MarshalAndSendResponse(err.Error())
+				return
+			}
+		}
+
+		metaFiles = append(metaFiles, &release.MetaFile{
+			ReleaseUID: uid,
+			FileName:   filename,
+			Type:       imageType,
+			State:      release.MetafileStateProcessed,
+			Info: release.MetaInfo{
+				"type": string(imageType),
+			},
+		})
+	}
+
+	// create info file
+	// this file serves no purpose but to inform the user that this is a test release
+	if _, err := os.Stat(path.Join(config.Base.Folders.Data, uid, "info.txt")); errors.Is(err, os.ErrNotExist) {
+		f, err := os.Create(path.Join(config.Base.Folders.Data, uid, "info.txt"))
+		if err != nil {
+			r.SetResponseCode(http.StatusInternalServerError)
+			r.MarshalAndSendResponse(err.Error())
+			return
+		}
+		f.WriteString("Files in this folder are used to test upload functionality.")
+		f.WriteString("\n")
+		f.WriteString("You can safely delete this folder.\n")
+		f.Close()
+	}
+
+	torrent := []byte(
+		"d10:created by13:uTorrent/160013:creation datei1662738966e8:encoding5:UTF-84:" +
+			"infod5:filesld6:lengthi1e4:pathl14:testfile-1.txteed6:lengthi1e4:pathl14:testfile-2.txteee4:name4" +
+			":test12:piece lengthi65536e6:pieces20:\xdd\xfe\x163E\xd38\x19:½\xc1\x83\xf8\xe9\xdc\xff\x90KCee",
+	)
+
+	nfo := []string{
+		"             _______ _________          _______ ",
+		"            (  ___  )\\__   __/|\\     /|(  ____ \\",
+		"            | (   ) |   ) (   | )   ( || (    \\/",
+		"            | (___) |   | |   | |   | || (_____ ",
+		"            |  ___  |   | |   | |   | |(_____  )",
+		"            | (   ) |   | |   | |   | |      ) |",
+		"            | )   ( |   | |   | (___) |/\\____) |",
+		"            |/     \\|   )_(   (_______)\\_______)",
+		"              Automatic Torrent Upload Script",
+		"",
+		"            https://github.com/SteffenLoges/atus",
+		"",
+		"",
+		"",
+		"Check if the torrent is valid by downloading it from the tracker",
+		"and adding it to a torrent client.",
+		"",
+		"If something is wrong, delete the torrent and try again.",
+		"",
+		"Once everything is setup to your liking, you can proceed to the",
+		"next step.",
+	}
+
+	rls := &atus.Release{
+		UID:       uid,
+		Name:      "ATUS Test Release",
+		Pre:       time.Now(),
+		MetaFiles: metaFiles,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	// the test upload is started by the user, trackers without search support can still be tested
+	opts := &atus.UploadOptions{IgnoreDupeCheckErrors: true}
+	_, err := (&atus.Destination{Destination: d}).UploadRelease(ctx, rls, torrent, []byte(strings.Join(nfo, "\n")), opts)
+	if err != nil {
+		r.SetResponseCode(http.StatusInternalServerError)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	r.MarshalAndSendResponse(true)
+
+}
+
+// Settings__DestinationsEdit_PreviewDescription renders the unsaved description template of the form for an existing release
+func Settings__DestinationsEdit_PreviewDescription(r *websocket.Request) {
+
+	a := r.Hub.Ctx.Value(atus.ContextKey).(*atus.ATUS)
+
+	var req struct {
+		ReleaseUID          string
+		DescriptionTemplate string
+		FileBaseURL         string
+		CategoryMapping     map[category.Name]string
+	}
+
+	if err := json.Unmarshal(r.Payload, &req); err != nil {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	rls := a.GetReleaseByUID(strings.TrimSpace(req.ReleaseUID))
+	if rls == nil {
+		r.SetResponseCode(http.StatusNotFound)
+		r.MarshalAndSendResponse(fmt.Sprintf("release with UID %s not found", req.ReleaseUID))
+		return
+	}
+
+	d := destination.New()
+	d.DescriptionTemplate = req.DescriptionTemplate
+	d.FileBaseURL = strings.TrimSpace(req.FileBaseURL)
+	d.CategoryMapping = req.CategoryMapping
+
+	preview, err := (&atus.Destination{Destination: d}).PreviewDescription(rls)
+	if err != nil {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	r.MarshalAndSendResponse(preview)
+
+}
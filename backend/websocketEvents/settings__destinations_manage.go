@@ -0,0 +1,94 @@
+package websocketEvents
+
+import (
+	"atus/backend/atus"
+	"atus/backend/websocket"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+func Settings__DestinationsManage_GetAll(r *websocket.Request) {
+
+	a := r.Hub.Ctx.Value(atus.ContextKey).(*atus.ATUS)
+
+	ret := []map[string]interface{}{}
+	for _, d := range a.GetAllDestinations() {
+		ret = append(ret, map[string]interface{}{
+			"uid":        d.UID,
+			"name":       d.Name,
+			"enabled":    d.Enabled,
+			"type":       d.Type,
+			"apiURL":     d.APIURL,
+			"dryRun":     d.DryRun,
+			"sumUploads": d.SumUploads,
+		})
+	}
+
+	r.MarshalAndSendResponse(ret)
+
+}
+
+func Settings__DestinationsManage_Delete(r *websocket.Request) {
+
+	a := r.Hub.Ctx.Value(atus.ContextKey).(*atus.ATUS)
+
+	var req struct {
+		UID string
+	}
+
+	if err := json.Unmarshal(r.Payload, &req); err != nil {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	d := a.GetDestinationByUID(req.UID)
+	if d == nil {
+		r.SetResponseCode(http.StatusNotFound)
+		r.MarshalAndSendResponse(fmt.Sprintf("destination with UID %s not found", req.UID))
+		return
+	}
+
+	if err := a.DeleteDestination(d); err != nil {
+		r.SetResponseCode(http.StatusInternalServerError)
+		r.MarshalAndSendResponse(fmt.Sprintf("error deleting destination: %s", err.Error()))
+		return
+	}
+
+	r.MarshalAndSendResponse(true)
+
+}
+
+func Settings__DestinationsManage_Toggle(r *websocket.Request) {
+
+	a := r.Hub.Ctx.Value(atus.ContextKey).(*atus.ATUS)
+
+	var req struct {
+		UID   string
+		Start bool
+	}
+
+	if err := json.Unmarshal(r.Payload, &req); err != nil {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	d := a.GetDestinationByUID(req.UID)
+	if d == nil {
+		r.SetResponseCode(http.StatusNotFound)
+		r.MarshalAndSendResponse(fmt.Sprintf("destination with UID %s not found", req.UID))
+		return
+	}
+
+	d.Enabled = req.Start
+	if err := d.Save(); err != nil {
+		r.SetResponseCode(http.StatusInternalServerError)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	r.MarshalAndSendResponse(true)
+
+}
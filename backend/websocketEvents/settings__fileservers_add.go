@@ -2,6 +2,7 @@ package websocketEvents
 
 import (
 	"atus/backend/atus"
+	"atus/backend/category"
 	"atus/backend/fileserver"
 	"atus/backend/helpers"
 	"atus/backend/websocket"
@@ -73,14 +74,18 @@ func Settings__FileserversAdd_SetURL(r *websocket.Request) {
 	settingsFileserversAddCache.Store(fs.UID, fs)
 
 	r.MarshalAndSendResponse(map[string]interface{}{
-		"uid":                fs.UID,
-		"name":               fs.Name,
-		"listInterval":       fs.ListInterval / time.Second,
-		"minFreeDiskSpace":   fs.MinFreeDiskSpace / helpers.GiB,
-		"statisticsInterval": fs.StatisticsInterval / time.Second,
-		"diskFreeSpace":      stats.DiskFreeSpace,
-		"diskTotalSpace":     stats.DiskTotalSpace,
-		"serverLoad":         stats.ServerLoad,
+		"uid":                    fs.UID,
+		"name":                   fs.Name,
+		"listInterval":           fs.ListInterval / time.Second,
+		"minFreeDiskSpace":       fs.MinFreeDiskSpace / helpers.GiB,
+		"statisticsInterval":     fs.StatisticsInterval / time.Second,
+		"diskFreeSpace":          stats.DiskFreeSpace,
+		"diskTotalSpace":         stats.DiskTotalSpace,
+		"serverLoad":             stats.ServerLoad,
+		"weight":                 fs.Weight,
+		"maxConcurrentDownloads": fs.MaxConcurrentDownloads,
+		"maxServerLoad":          fs.MaxServerLoad,
+		"categories":             fs.Categories,
 	})
 }
 
@@ -89,11 +94,15 @@ func Settings__FileserversAdd_SetSettings(r *websocket.Request) {
 	a := r.Hub.Ctx.Value(atus.ContextKey).(*atus.ATUS)
 
 	var req struct {
-		UID                string
-		Name               string
-		ListInterval       int64
-		StatisticsInterval int64
-		MinFreeDiskSpace   int64
+		UID                    string
+		Name                   string
+		ListInterval           int64
+		StatisticsInterval     int64
+		MinFreeDiskSpace       int64
+		Weight                 int64
+		MaxConcurrentDownloads int64
+		MaxServerLoad          float64
+		Categories             []category.Name
 	}
 
 	if err := json.Unmarshal(r.Payload, &req); err != nil {
@@ -128,6 +137,22 @@ func Settings__FileserversAdd_SetSettings(r *websocket.Request) {
 		fs.MinFreeDiskSpace = helpers.GiB * req.MinFreeDiskSpace
 	}
 
+	if req.Weight > 0 {
+		fs.Weight = req.Weight
+	}
+
+	if req.MaxConcurrentDownloads > 0 {
+		fs.MaxConcurrentDownloads = req.MaxConcurrentDownloads
+	}
+
+	if req.MaxServerLoad > 0 {
+		fs.MaxServerLoad = req.MaxServerLoad
+	}
+
+	if req.Categories != nil {
+		fs.Categories = req.Categories
+	}
+
 	if err := a.AddNewFileserver(fs); err != nil {
 		r.SetResponseCode(http.StatusBadRequest)
 		r.MarshalAndSendResponse(fmt.Sprintf("could not save settings: %s", err.Error()))
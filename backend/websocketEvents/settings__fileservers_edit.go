@@ -2,6 +2,7 @@ package websocketEvents
 
 import (
 	"atus/backend/atus"
+	"atus/backend/category"
 	"atus/backend/helpers"
 	"atus/backend/websocket"
 	"encoding/json"
@@ -33,12 +34,16 @@ func Settings__FileserversEdit_Get(r *websocket.Request) {
 	}
 
 	ret := map[string]interface{}{
-		"uid":                s.Fileserver.UID,
-		"name":               s.Fileserver.Name,
-		"listInterval":       s.Fileserver.ListInterval / time.Second,
-		"minFreeDiskSpace":   s.Fileserver.MinFreeDiskSpace / helpers.GiB,
-		"statisticsInterval": s.Fileserver.StatisticsInterval / time.Second,
-		"url":                s.Fileserver.URL.String(),
+		"uid":                    s.Fileserver.UID,
+		"name":                   s.Fileserver.Name,
+		"listInterval":           s.Fileserver.ListInterval / time.Second,
+		"minFreeDiskSpace":       s.Fileserver.MinFreeDiskSpace / helpers.GiB,
+		"statisticsInterval":     s.Fileserver.StatisticsInterval / time.Second,
+		"url":                    s.Fileserver.URL.String(),
+		"weight":                 s.Fileserver.Weight,
+		"maxConcurrentDownloads": s.Fileserver.MaxConcurrentDownloads,
+		"maxServerLoad":          s.Fileserver.MaxServerLoad,
+		"categories":             s.Fileserver.Categories,
 	}
 
 	if s.Fileserver.Statistics != nil {
@@ -55,12 +60,16 @@ func Settings__FileserversEdit_Save(r *websocket.Request) {
 	a := r.Hub.Ctx.Value(atus.ContextKey).(*atus.ATUS)
 
 	var req struct {
-		UID                string
-		Name               string
-		URL                string
-		ListInterval       int64
-		StatisticsInterval int64
-		MinFreeDiskSpace   int64
+		UID                    string
+		Name                   string
+		URL                    string
+		ListInterval           int64
+		StatisticsInterval     int64
+		MinFreeDiskSpace       int64
+		Weight                 int64
+		MaxConcurrentDownloads int64
+		MaxServerLoad          float64
+		Categories             []category.Name
 	}
 
 	if err := json.Unmarshal(r.Payload, &req); err != nil {
@@ -97,6 +106,21 @@ func Settings__FileserversEdit_Save(r *websocket.Request) {
 		s.Fileserver.MinFreeDiskSpace = helpers.GiB * req.MinFreeDiskSpace
 	}
 
+	s.Fileserver.Weight = req.Weight
+	if s.Fileserver.Weight < 1 {
+		s.Fileserver.Weight = 1
+	}
+
+	if req.MaxConcurrentDownloads >= 0 {
+		s.Fileserver.MaxConcurrentDownloads = req.MaxConcurrentDownloads
+	}
+
+	if req.MaxServerLoad >= 0 {
+		s.Fileserver.MaxServerLoad = req.MaxServerLoad
+	}
+
+	s.Fileserver.Categories = req.Categories
+
 	s.Fileserver.Name = req.Name
 	if s.Fileserver.Name == "" {
 		s.Fileserver.Name = urlParsed.Host
@@ -12,6 +12,7 @@ func Settings__FileserversSettings_Get(r *websocket.Request) {
 		"allocationMethod": config.GetString("FILESERVER__ALLOCATION_METHOD"),
 		"downloadLabel":    config.GetString("FILESERVER__DOWNLOAD_LABEL"),
 		"uploadLabel":      config.GetString("FILESERVER__UPLOAD_LABEL"),
+		"downloadTimeout":  config.GetInt64("FILESERVER__DOWNLOAD_TIMEOUT"),
 	})
 }
 
@@ -21,6 +22,7 @@ func Settings__FileserversSettings_Save(r *websocket.Request) {
 		AllocationMethod string
 		DownloadLabel    string
 		UploadLabel      string
+		DownloadTimeout  int64
 	}
 
 	if err := json.Unmarshal(r.Payload, &req); err != nil {
@@ -33,6 +35,10 @@ func Settings__FileserversSettings_Save(r *websocket.Request) {
 	config.Set("FILESERVER__DOWNLOAD_LABEL", req.DownloadLabel)
 	config.Set("FILESERVER__UPLOAD_LABEL", req.UploadLabel)
 
+	if req.DownloadTimeout > 0 {
+		config.Set("FILESERVER__DOWNLOAD_TIMEOUT", req.DownloadTimeout)
+	}
+
 	r.MarshalAndSendResponse(true)
 
 }
@@ -13,14 +13,20 @@ import (
 
 func Settings__FiltersMisc_GetAll(r *websocket.Request) {
 	r.MarshalAndSendResponse(map[string]interface{}{
-		"maxAge": config.GetInt64("FILTERS__MAX_AGE"),
+		"maxAge":           config.GetInt64("FILTERS__MAX_AGE"),
+		"approvalRequired": config.GetBool("UPLOAD__APPROVAL_REQUIRED"),
+
+		"quarantineInvalidTorrents": config.GetBool("FILTERS__QUARANTINE_INVALID_TORRENTS"),
 	})
 }
 
 func Settings__FiltersMisc_Save(r *websocket.Request) {
 
 	var req struct {
-		MaxAge int64 `json:"maxAge"`
+		MaxAge           int64 `json:"maxAge"`
+		ApprovalRequired bool  `json:"approvalRequired"`
+
+		QuarantineInvalidTorrents bool `json:"quarantineInvalidTorrents"`
 	}
 
 	if err := json.Unmarshal(r.Payload, &req); err != nil {
@@ -30,6 +36,8 @@ func Settings__FiltersMisc_Save(r *websocket.Request) {
 	}
 
 	config.Set("FILTERS__MAX_AGE", req.MaxAge)
+	config.Set("UPLOAD__APPROVAL_REQUIRED", req.ApprovalRequired)
+	config.Set("FILTERS__QUARANTINE_INVALID_TORRENTS", req.QuarantineInvalidTorrents)
 
 	r.MarshalAndSendResponse(true)
 
@@ -47,6 +55,8 @@ func Settings__FiltersCategories_GetAll(r *websocket.Request) {
 			"includes": c.Includes,
 			"excludes": c.Excludes,
 			"maxSize":  c.MaxSize / helpers.GiB,
+
+			"approvalRequired": c.ApprovalRequired,
 		})
 	}
 
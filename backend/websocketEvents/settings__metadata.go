@@ -0,0 +1,62 @@
+package websocketEvents
+
+import (
+	"atus/backend/config"
+	"atus/backend/websocket"
+	"encoding/json"
+	"net/http"
+	"regexp"
+)
+
+var languageRegExp = regexp.MustCompile(`^[a-z]{2}(-[A-Z]{2})?$`)
+
+func Settings__MetadataManage_GetAll(r *websocket.Request) {
+	r.MarshalAndSendResponse(map[string]interface{}{
+		"enabled":        config.GetBool("METADATA__ENABLED"),
+		"tvmazeEnabled":  config.GetBool("METADATA__TVMAZE_ENABLED"),
+		"tmdbAPIKey":     config.GetString("METADATA__TMDB_API_KEY"),
+		"language":       config.GetString("METADATA__LANGUAGE"),
+		"cacheTTL":       config.GetInt64("METADATA__CACHE_TTL"),
+		"downloadPoster": config.GetBool("METADATA__DOWNLOAD_POSTER"),
+	})
+}
+
+func Settings__MetadataManage_Save(r *websocket.Request) {
+
+	var req struct {
+		Enabled        bool
+		TVmazeEnabled  bool
+		TMDBAPIKey     string
+		Language       string
+		CacheTTL       int64 // hours
+		DownloadPoster bool
+	}
+
+	if err := json.Unmarshal(r.Payload, &req); err != nil {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	if req.CacheTTL < 0 {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse("cache ttl must not be negative")
+		return
+	}
+
+	if req.Language != "" && !languageRegExp.MatchString(req.Language) {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse("language must be a language code like en or en-US")
+		return
+	}
+
+	config.Set("METADATA__ENABLED", req.Enabled)
+	config.Set("METADATA__TVMAZE_ENABLED", req.TVmazeEnabled)
+	config.Set("METADATA__TMDB_API_KEY", req.TMDBAPIKey)
+	config.Set("METADATA__LANGUAGE", req.Language)
+	config.Set("METADATA__CACHE_TTL", req.CacheTTL)
+	config.Set("METADATA__DOWNLOAD_POSTER", req.DownloadPoster)
+
+	r.MarshalAndSendResponse(true)
+
+}
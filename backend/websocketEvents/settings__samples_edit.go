@@ -1,29 +1,69 @@
 package websocketEvents
 
 import (
+	"atus/backend/atus"
 	"atus/backend/config"
 	"atus/backend/helpers"
+	"atus/backend/video"
 	"atus/backend/websocket"
 	"encoding/json"
 	"net/http"
 )
 
 func Settings__SamplesManage_GetAll(r *websocket.Request) {
+
+	// samples are not processed if the dependencies of the packager are missing
+	var dependencyError string
+	if err := video.DependencyError(); err != nil {
+		dependencyError = err.Error()
+	}
+
+	// an invalid stored profile is replaced by the default so it can be fixed in the web interface
+	profile, err := video.ParseProfile(config.GetString("SAMPLES__PROFILE"))
+	if err != nil {
+		profile = video.DefaultProfile
+	}
+
 	r.MarshalAndSendResponse(map[string]interface{}{
-		"enabled":        config.GetBool("SAMPLES__ENABLED"),
-		"sumScreenshots": config.GetInt64("SAMPLES__SUM_SCREENSHOTS"),
-		"minSize":        config.GetInt64("SAMPLES__MIN_SIZE") / helpers.MiB,
-		"maxSize":        config.GetInt64("SAMPLES__MAX_SIZE") / helpers.MiB,
+		"enabled":              config.GetBool("SAMPLES__ENABLED"),
+		"fromReleaseVideo":     config.GetBool("SAMPLES__FROM_RELEASE_VIDEO"),
+		"sumScreenshots":       config.GetInt64("SAMPLES__SUM_SCREENSHOTS"),
+		"screenshotCandidates": config.GetInt64("SAMPLES__SCREENSHOT_CANDIDATES"),
+		"minSize":              config.GetInt64("SAMPLES__MIN_SIZE") / helpers.MiB,
+		"maxSize":              config.GetInt64("SAMPLES__MAX_SIZE") / helpers.MiB,
+		"workers":              config.GetInt64("SAMPLES__WORKERS"),
+		"timeout":              config.GetInt64("SAMPLES__TIMEOUT") / 60,
+		"outputFormat":         config.GetString("SAMPLES__OUTPUT_FORMAT"),
+		"packager":             config.GetString("SAMPLES__PACKAGER"),
+		"profile":              profile,
+		"profiles":             video.Profiles,
+		"dependencyError":      dependencyError,
+		"contactSheet": map[string]interface{}{
+			"columns": config.GetInt64("SAMPLES__CONTACT_SHEET_COLUMNS"),
+			"rows":    config.GetInt64("SAMPLES__CONTACT_SHEET_ROWS"),
+		},
 	})
+
 }
 
 func Settings__SamplesManage_Save(r *websocket.Request) {
 
 	var req struct {
-		Enabled        bool
-		SumScreenshots int64
-		MinSize        int64
-		MaxSize        int64
+		Enabled              bool
+		FromReleaseVideo     bool
+		SumScreenshots       int64
+		ScreenshotCandidates int64
+		MinSize              int64
+		MaxSize              int64
+		Workers              int64
+		Timeout              int64 // minutes
+		OutputFormat         string
+		Packager             string
+		Profile              *video.Profile
+		ContactSheet         struct {
+			Columns int64
+			Rows    int64
+		}
 	}
 
 	if err := json.Unmarshal(r.Payload, &req); err != nil {
@@ -32,10 +72,132 @@ func Settings__SamplesManage_Save(r *websocket.Request) {
 		return
 	}
 
+	if req.Workers < 1 || req.Timeout < 1 {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse("workers and timeout must be at least 1")
+		return
+	}
+
+	if req.ScreenshotCandidates < 1 || req.ScreenshotCandidates > 10 {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse("screenshot candidates must be between 1 and 10")
+		return
+	}
+
+	if req.ContactSheet.Columns < 0 || req.ContactSheet.Columns > 10 || req.ContactSheet.Rows < 0 || req.ContactSheet.Rows > 10 {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse("contact sheet columns and rows must be between 0 and 10")
+		return
+	}
+
+	outputFormat, err := video.ParseOutputFormat(req.OutputFormat)
+	if err != nil {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	packager, err := video.ParsePackager(req.Packager)
+	if err != nil {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	if req.Profile == nil {
+		req.Profile = video.DefaultProfile
+	}
+
+	if err := req.Profile.Validate(); err != nil {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	profile, err := json.Marshal(req.Profile)
+	if err != nil {
+		r.SetResponseCode(http.StatusInternalServerError)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
 	config.Set("SAMPLES__ENABLED", req.Enabled)
+	config.Set("SAMPLES__FROM_RELEASE_VIDEO", req.FromReleaseVideo)
 	config.Set("SAMPLES__SUM_SCREENSHOTS", req.SumScreenshots)
+	config.Set("SAMPLES__SCREENSHOT_CANDIDATES", req.ScreenshotCandidates)
+	config.Set("SAMPLES__CONTACT_SHEET_COLUMNS", req.ContactSheet.Columns)
+	config.Set("SAMPLES__CONTACT_SHEET_ROWS", req.ContactSheet.Rows)
 	config.Set("SAMPLES__MIN_SIZE", req.MinSize*helpers.MiB)
 	config.Set("SAMPLES__MAX_SIZE", req.MaxSize*helpers.MiB)
+	config.Set("SAMPLES__WORKERS", req.Workers)
+	config.Set("SAMPLES__TIMEOUT", req.Timeout*60)
+	config.Set("SAMPLES__OUTPUT_FORMAT", string(outputFormat))
+	config.Set("SAMPLES__PACKAGER", string(packager))
+	config.Set("SAMPLES__PROFILE", string(profile))
+
+	// samples are disabled until the dependencies of the new packager are installed
+	var dependencyError string
+	if err := video.CheckDependencies(packager); err != nil {
+		dependencyError = err.Error()
+	}
+
+	r.MarshalAndSendResponse(map[string]interface{}{
+		"dependencyError": dependencyError,
+	})
+
+}
+
+func Settings__SamplesQueue_GetAll(r *websocket.Request) {
+
+	a := r.Hub.Ctx.Value(atus.ContextKey).(*atus.ATUS)
+
+	r.MarshalAndSendResponse(a.GetSampleJobs())
+
+}
+
+func Settings__SamplesQueue_Retry(r *websocket.Request) {
+
+	a := r.Hub.Ctx.Value(atus.ContextKey).(*atus.ATUS)
+
+	var req struct {
+		ReleaseUID string
+	}
+
+	if err := json.Unmarshal(r.Payload, &req); err != nil {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	if err := a.RetrySampleJob(req.ReleaseUID); err != nil {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	r.MarshalAndSendResponse(true)
+
+}
+
+func Settings__SamplesQueue_Cancel(r *websocket.Request) {
+
+	a := r.Hub.Ctx.Value(atus.ContextKey).(*atus.ATUS)
+
+	var req struct {
+		ReleaseUID string
+	}
+
+	if err := json.Unmarshal(r.Payload, &req); err != nil {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
+
+	if err := a.CancelSampleJob(req.ReleaseUID); err != nil {
+		r.SetResponseCode(http.StatusBadRequest)
+		r.MarshalAndSendResponse(err.Error())
+		return
+	}
 
 	r.MarshalAndSendResponse(true)
 
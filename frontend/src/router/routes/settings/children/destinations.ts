@@ -0,0 +1,45 @@
+import { RouteRecordRaw } from "vue-router";
+
+export default <RouteRecordRaw>{
+  name: "settings_destinations",
+  path: "destinations",
+  meta: {
+    title: "Destinations",
+  },
+  children: [
+    {
+      name: "settings_destinations_manage",
+      path: "manage",
+      alias: "",
+      meta: {
+        title: "Manage Destinations",
+      },
+      component: () =>
+        import(
+          /* webpackChunkName: "settings_destinations_manage" */ "@/views/Settings/children/Destinations/Manage/Index.vue"
+        ),
+    },
+    {
+      name: "settings_destinations_add",
+      path: "add",
+      meta: {
+        title: "Add Destination",
+      },
+      component: () =>
+        import(
+          /* webpackChunkName: "settings_destinations_edit" */ "@/views/Settings/children/Destinations/Edit.vue"
+        ),
+    },
+    {
+      name: "settings_destinations_edit",
+      path: "edit/:uid",
+      meta: {
+        title: "Edit Destination",
+      },
+      component: () =>
+        import(
+          /* webpackChunkName: "settings_destinations_edit" */ "@/views/Settings/children/Destinations/Edit.vue"
+        ),
+    },
+  ],
+};
@@ -3,6 +3,7 @@ import sources from "./children/sources";
 import fileservers from "./children/fileservers";
 import users from "./children/users";
 import filters from "./children/filters";
+import destinations from "./children/destinations";
 
 export default <RouteRecordRaw>{
   path: "/settings",
@@ -19,26 +20,27 @@ export default <RouteRecordRaw>{
     fileservers,
     users,
     filters,
+    destinations,
     {
-      name: "settings_destinations",
-      path: "destinations",
+      name: "settings_samples",
+      path: "samples",
       meta: {
-        title: "Upload Settings",
+        title: "Sample Settings",
       },
       component: () =>
         import(
-          /* webpackChunkName: "settings_destinations" */ "@/views/Settings/children/Destinations/Index.vue"
+          /* webpackChunkName: "settings_samples" */ "@/views/Settings/children/Samples/Index.vue"
         ),
     },
     {
-      name: "settings_samples",
-      path: "samples",
+      name: "settings_metadata",
+      path: "metadata",
       meta: {
-        title: "Sample Settings",
+        title: "Metadata Settings",
       },
       component: () =>
         import(
-          /* webpackChunkName: "settings_samples" */ "@/views/Settings/children/Samples/Index.vue"
+          /* webpackChunkName: "settings_metadata" */ "@/views/Settings/children/Metadata/Index.vue"
         ),
     },
   ],
@@ -28,6 +28,30 @@ export const getFileURL = (
   return url;
 };
 
+export interface IImageSize {
+  width?: number;
+  height?: number;
+  fit?: "contain" | "cover" | "fill";
+}
+
+// resized copy of an image in the data folder, see imaging.ParseOptions in the backend
+export const getImageURL = (
+  path: string,
+  { width, height, fit = "contain" }: IImageSize
+) => {
+  let url = getFileURL(path) + `&fit=${fit}&format=webp`;
+
+  if (width) {
+    url += `&w=${Math.round(width * window.devicePixelRatio)}`;
+  }
+
+  if (height) {
+    url += `&h=${Math.round(height * window.devicePixelRatio)}`;
+  }
+
+  return url;
+};
+
 export const dereferURL = (url: string, noSplash = false) =>
   "https://" +
   (noSplash ? "nosplash." : "") +
@@ -1,4 +1,4 @@
-import { getFileURL } from "@/utils/url";
+import { getFileURL, getImageURL, IImageSize } from "@/utils/url";
 
 export const IMAGE_TYPES: IMetaFileType[] = [
   "IMAGE",
@@ -6,30 +6,43 @@ export const IMAGE_TYPES: IMetaFileType[] = [
   "SOURCE_IMAGE",
   "SCREEN_IMAGE",
   "SCREEN_IMAGE__FROM_SAMPLE",
+  "SCREEN_IMAGE__FROM_VIDEO",
+  "CONTACT_SHEET",
 ];
 
 export const nameMap: { [key in IMetaFileType]: string } = {
   TORRENT: "Torrent",
   NFO: "NFO",
+  NFO_IMAGE: "NFO Image",
   SOURCE_IMAGE: "Source Image",
+  POSTER: "Poster",
   IMAGE: "Release Images",
   PROOF_IMAGE: "Proof Images",
   SCREEN_IMAGE: "Screenshots",
   SCREEN_IMAGE__FROM_SAMPLE: "Sample Screenshots",
+  SCREEN_IMAGE__FROM_VIDEO: "Release Video Screenshots",
   SAMPLE_VIDEO: "Sample Video",
+  RELEASE_VIDEO: "Release Video",
+  CONTACT_SHEET: "Contact Sheet",
+  MEDIAINFO: "MediaInfo",
+  VALIDATION_REPORT: "Validation Report",
+  RECREATED_TORRENT: "Re-created Torrent",
 };
 
 export default () => {
   const getName = (type: IMetaFileType) =>
     nameMap[type] || "Unknown";
 
-  const getCoverImage = (metaFiles: IMetaFile[]) => {
+  // the original image is returned if no size is given
+  const getCoverImage = (metaFiles: IMetaFile[], size?: IImageSize) => {
     const typePriority: IMetaFileType[] = [
+      "POSTER",
       "SOURCE_IMAGE",
       "IMAGE",
       "PROOF_IMAGE",
       "SCREEN_IMAGE",
       "SCREEN_IMAGE__FROM_SAMPLE",
+      "SCREEN_IMAGE__FROM_VIDEO",
     ];
 
     for (const t of typePriority) {
@@ -39,9 +52,8 @@ export default () => {
       );
 
       if (image) {
-        return getFileURL(
-          `${image.releaseUID}/${image.fileName}`
-        );
+        const path = `${image.releaseUID}/${image.fileName}`;
+        return size ? getImageURL(path, size) : getFileURL(path);
       }
     }
 
@@ -24,7 +24,7 @@ export default (
 
   const progress = computed(() => {
     if (
-      ["UPLOADED", "DOWNLOADED"].includes(state.value.state)
+      ["UPLOADED", "DOWNLOADED", "AWAITING_APPROVAL", "REJECTED"].includes(state.value.state)
     ) {
       return 100;
     }
@@ -32,8 +32,9 @@ export default (
     return downloadState.value?.done || 0;
   });
 
+  // the browse page only needs small thumbnails
   const coverURL = computed(() =>
-    getCoverImage(metaFiles.value)
+    getCoverImage(metaFiles.value, { width: 400 })
   );
 
   const backgroundImage = computed(
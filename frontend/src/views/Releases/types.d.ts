@@ -1,12 +1,20 @@
 type IMetaFileType =
   | "TORRENT"
   | "NFO"
+  | "NFO_IMAGE"
   | "SOURCE_IMAGE"
+  | "POSTER"
   | "IMAGE"
   | "PROOF_IMAGE"
   | "SCREEN_IMAGE"
   | "SCREEN_IMAGE__FROM_SAMPLE"
-  | "SAMPLE_VIDEO";
+  | "SCREEN_IMAGE__FROM_VIDEO"
+  | "SAMPLE_VIDEO"
+  | "RELEASE_VIDEO"
+  | "CONTACT_SHEET"
+  | "MEDIAINFO"
+  | "VALIDATION_REPORT"
+  | "RECREATED_TORRENT";
 
 type IMetaFileState =
   | "UNKNOWN"
@@ -22,8 +30,33 @@ interface IMetaFile {
   info: any[] | null;
 }
 
+interface IExternalIDs {
+  imdb?: string;
+  tvmaze?: string;
+  tvdb?: string;
+  tmdb?: string;
+  tmdbType?: "movie" | "tv";
+}
+
+interface IMetadata {
+  provider: string;
+  id: string;
+  url: string;
+  title: string;
+  year: number; // 0 if unknown
+  plot: string;
+  genres: string[];
+  rating: number; // 0-10, 0 if unrated
+  votes: number;
+  runtime: number; // minutes
+  posterURL: string;
+  externalIDs: IExternalIDs | null;
+}
+
 interface IRelease {
   uid: string;
+  hash: string;
+  hashV2: string;
   name: string;
   nameRaw: string;
   pre: string;
@@ -34,6 +67,10 @@ interface IRelease {
   fileserverName: string;
   sourceName: string;
   metaFiles: IMetaFile[];
+  uploads: IReleaseUpload[];
+  validationReport: IValidationReport | null;
+  externalIDs?: IExternalIDs;
+  metadata?: IMetadata | null;
   state: IReleaseState;
   downloadState?: IDownloadState;
 }
@@ -58,8 +95,58 @@ interface IReleaseState {
     | "DOWNLOAD_INIT"
     | "DOWNLOADING"
     | "DOWNLOADED"
+    | "AWAITING_APPROVAL"
+    | "REJECTED"
+    | "QUARANTINED"
     | "UPLOADED"
     | "GENERAL_ERROR"
     | "UPLOAD_ERROR";
   uploadDate: string;
 }
+
+interface IReleaseUpload {
+  destinationUID: string;
+  destinationName: string;
+  state: "UPLOADED" | "UPLOAD_ERROR" | "SKIPPED" | "DRY_RUN" | "UPLOADING" | "DUPE";
+  message: string;
+  hash: string;
+  originalHash: string;
+  torrentID: string;
+  torrentURL: string;
+  updated: string;
+}
+
+interface IUploadFormFile {
+  field: string;
+  fileName: string;
+  size: number;
+}
+
+interface IUploadPreview {
+  destinationUID: string;
+  destinationName: string;
+  dryRun: boolean;
+  error: string;
+  hash: string;
+  hashV2: string;
+  announce: string;
+  source: string;
+  category: string;
+  form: {
+    fields: Record<string, string>;
+    files: IUploadFormFile[];
+  } | null;
+}
+
+interface IValidationIssue {
+  severity: "ERROR" | "WARNING";
+  code: string;
+  message: string;
+}
+
+interface IValidationReport {
+  issues: IValidationIssue[];
+  files: number;
+  pieces: number;
+  size: number;
+}
@@ -1,8 +1,58 @@
-interface IDestinationSettings {
-  uploadUserID: string;
-  uploadUserAnnounceURL: string;
-  uploadTrackerAnnounceURL: string;
-  uploadAPIURL: string;
-  uploadCreatedBy: string;
-  uploadComment: string;
+interface IDestinationFilters {
+  categories: string[];
+  includes: string[];
+  excludes: string[];
+  maxSize: number;
+  externalIDs: IExternalIDType[];
+}
+
+type IExternalIDType = "IMDB" | "TVMAZE" | "TVDB" | "TMDB";
+
+type IDestinationType = "ATUS" | "UNIT3D" | "GAZELLE";
+
+interface IDestinationAttributeMapping {
+  types: Record<string, string>;
+  resolutions: Record<string, string>;
+}
+
+// sizes in bytes, a maxSize of 0 matches all sizes
+interface IDestinationPieceSize {
+  maxSize: number;
+  pieceLength: number;
+}
+
+interface IDestination {
+  uid: string;
+  name: string;
+  enabled: boolean;
+  type: IDestinationType;
+  apiURL: string;
+  trackerAPIKey: string;
+  anonymous: boolean;
+  apiAuthToken: string;
+  trackerAnnounceURL: string;
+  userAnnounceURL: string;
+  userID: string;
+  comment: string;
+  createdBy: string;
+  categoryMapping: Record<string, string>;
+  attributeMapping: IDestinationAttributeMapping;
+  descriptionTemplate: string;
+  fileBaseURL: string;
+  sourceTag: string;
+  recreateTorrent: boolean;
+  pieceSizePolicy: IDestinationPieceSize[];
+  contactSheetField: string; // empty doesn't attach the contact sheet
+  dryRun: boolean;
+  filters: IDestinationFilters;
+}
+
+interface IDestinationListItem {
+  uid: string;
+  name: string;
+  enabled: boolean;
+  type: IDestinationType;
+  apiURL: string;
+  dryRun: boolean;
+  sumUploads: number;
 }
@@ -10,10 +10,15 @@ interface IFileserver {
   serverLoad?: number[];
   diskFreeSpace?: number;
   diskTotalSpace?: number;
+  weight: number;
+  maxConcurrentDownloads: number;
+  maxServerLoad: number;
+  categories: string[];
 }
 
 interface IFileserverSettings {
-  allocationMethod: "RANDOM" | "FILL" | "MOST_FREE";
+  allocationMethod: "RANDOM" | "FILL" | "MOST_FREE" | "WEIGHTED" | "ROUND_ROBIN";
   downloadLabel: string;
   uploadLabel: string;
+  downloadTimeout: number;
 }
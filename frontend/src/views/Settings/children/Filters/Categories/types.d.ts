@@ -4,4 +4,5 @@ interface ICategory {
   includes: string[];
   excludes: string[];
   maxSize: number;
+  approvalRequired: boolean;
 }
@@ -1,3 +1,5 @@
 interface IFiltersMisc {
   maxAge: number;
+  approvalRequired: boolean;
+  quarantineInvalidTorrents: boolean;
 }
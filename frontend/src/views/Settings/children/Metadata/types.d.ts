@@ -0,0 +1,8 @@
+interface IMetadataSettings {
+  enabled: boolean;
+  tvmazeEnabled: boolean;
+  tmdbAPIKey: string; // TMDB is disabled if empty
+  language: string;
+  cacheTTL: number; // hours, 0 disables the cache
+  downloadPoster: boolean;
+}
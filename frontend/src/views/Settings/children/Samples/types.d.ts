@@ -1,6 +1,45 @@
+type ISampleOutputFormat = "DASH" | "HLS" | "DASH_HLS";
+
+type ISamplePackager = "FFMPEG" | "BENTO4";
+
+interface ISampleProfile {
+  crf: number;
+  preset: string;
+  maxHeight: number; // 0 keeps the resolution
+  maxBitrate: number; // kbit/s, 0 disables the cap
+  audioBitrate: number; // kbit/s
+  maxDuration: number; // seconds, 0 keeps the full length
+  streamCopy: boolean;
+}
+
 interface ISampleSettings {
   enabled: boolean;
+  fromReleaseVideo: boolean;
   sumScreenshots: number;
+  screenshotCandidates: number;
+  contactSheet: {
+    columns: number; // 0 disables the contact sheet
+    rows: number;
+  };
   minSize: number;
   maxSize: number;
+  workers: number;
+  timeout: number; // minutes
+  outputFormat: ISampleOutputFormat;
+  packager: ISamplePackager;
+  profile: ISampleProfile;
+  profiles: { [name: string]: ISampleProfile };
+  dependencyError: string; // empty if samples can be processed
+}
+
+type ISampleJobState = "PENDING" | "RUNNING" | "FAILED" | "DONE";
+
+interface ISampleJob {
+  releaseUID: string;
+  releaseName: string;
+  state: ISampleJobState;
+  progress: number;
+  error: string;
+  added: string;
+  started: string;
 }